@@ -11,7 +11,23 @@ import (
 var version = "dev"
 
 func main() {
-	if err := app.Run(version); err != nil {
+	var err error
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doctor":
+			// Diagnose the mail setup
+			err = app.Doctor(os.Stdout)
+		case "send-queued":
+			// Send scheduled messages that are due
+			err = app.SendQueued(os.Stdout)
+		default:
+			err = fmt.Errorf("unknown command %q; available: doctor, send-queued", os.Args[1])
+		}
+	} else {
+		err = app.Run(version)
+	}
+
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
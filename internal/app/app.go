@@ -1,14 +1,23 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/romaintb/mel/internal/config"
+	"github.com/romaintb/mel/internal/doctor"
 	"github.com/romaintb/mel/internal/email"
 	"github.com/romaintb/mel/internal/icons"
+	"github.com/romaintb/mel/internal/maildir"
+	"github.com/romaintb/mel/internal/outbox"
+	"github.com/romaintb/mel/internal/schedule"
 	"github.com/romaintb/mel/internal/search"
 	"github.com/romaintb/mel/internal/ui"
 )
@@ -17,7 +26,7 @@ import (
 type App struct {
 	ui            *ui.UI
 	config        *config.Config
-	emailManager  *email.Manager
+	accounts      []*ui.Account
 	searchService *search.SearchService
 	iconService   *icons.Service
 }
@@ -41,23 +50,41 @@ func New(version string) (*App, error) {
 	}
 	iconService := icons.NewService(iconMode)
 
-	// Initialize email manager with external tool paths
+	// Initialize the mail backend with external tool paths
 	if cfg.Email.Maildir == "" {
 		return nil, fmt.Errorf("email.maildir is required")
 	}
 
-	emailManager := email.NewManager(
-		cfg.Email.Maildir,
-		cfg.ExternalTools.Notmuch,
-		cfg.ExternalTools.Mbsync,
-		cfg.ExternalTools.Msmtp,
-	)
+	accounts, err := newAccounts(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Default the sender to the notmuch user settings
+	for _, account := range accounts {
+		manager, ok := account.Backend.(*email.Manager)
+		if !ok || len(account.Config.Identities) > 0 {
+			continue
+		}
+		if from, aliases, err := manager.UserIdentity(context.Background()); err == nil && from != "" {
+			account.Config.Identities = append([]string{from}, aliases...)
+		}
+	}
+
+	// Hooks describe the account with its identity known
+	for _, account := range accounts {
+		account.Syncer.SetHooks(syncHooks(account.Config))
+	}
 
-	// Initialize search service
-	searchService := search.NewSearchService(emailManager)
+	// Initialize search service over every account
+	backends := make([]email.Backend, 0, len(accounts))
+	for _, account := range accounts {
+		backends = append(backends, account.Backend)
+	}
+	searchService := search.NewSearchService(backends...)
 
 	// Initialize UI with services
-	ui, err := ui.New(cfg, emailManager, searchService, iconService)
+	ui, err := ui.New(cfg, accounts, outbox.New(cfg.Email.OutboxDir), schedule.New(cfg.Email.ScheduleDir), searchService, iconService)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize UI: %w", err)
 	}
@@ -65,12 +92,204 @@ func New(version string) (*App, error) {
 	return &App{
 		ui:            ui,
 		config:        cfg,
-		emailManager:  emailManager,
+		accounts:      accounts,
 		searchService: searchService,
 		iconService:   iconService,
 	}, nil
 }
 
+// newAccounts creates the configured accounts, each with its own backend
+// and sender
+func newAccounts(cfg *config.Config) ([]*ui.Account, error) {
+	configs, err := cfg.AccountList()
+	if err != nil {
+		return nil, fmt.Errorf("invalid accounts: %w", err)
+	}
+
+	found := cfg.Email.DefaultAccount == ""
+	accounts := make([]*ui.Account, 0, len(configs))
+	for _, accountConfig := range configs {
+		sender := newSender(cfg, accountConfig)
+		driver, err := newSyncDriver(cfg, accountConfig)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", accountConfig.Name, err)
+		}
+		backend, err := newBackend(cfg, accountConfig, sender, driver)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", accountConfig.Name, err)
+		}
+		accounts = append(accounts, &ui.Account{
+			Config:  accountConfig,
+			Backend: backend,
+			Sender:  sender,
+			Syncer:  newSyncer(backend, driver),
+		})
+		found = found || accountConfig.Name == cfg.Email.DefaultAccount
+	}
+	if !found {
+		return nil, fmt.Errorf("email.default_account %q is not a configured account", cfg.Email.DefaultAccount)
+	}
+	return accounts, nil
+}
+
+// newBackend creates the mail backend selected in the configuration for an
+// account
+func newBackend(cfg *config.Config, account config.AccountConfig, sender *email.Sender, driver email.SyncDriver) (email.Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Email.Backend)) {
+	case "", "auto":
+		// Prefer notmuch for search and threading, fall back to plain maildir
+		if _, err := exec.LookPath(cfg.ExternalTools.Notmuch); err != nil {
+			return maildir.New(account.Maildir), nil
+		}
+	case "maildir":
+		return maildir.New(account.Maildir), nil
+	case "notmuch":
+	default:
+		return nil, fmt.Errorf("invalid email.backend %q; allowed: auto, notmuch, maildir", cfg.Email.Backend)
+	}
+
+	manager := email.NewManager(
+		account.Maildir,
+		cfg.ExternalTools.Notmuch,
+		cfg.ExternalTools.Mbsync,
+		cfg.ExternalTools.Msmtp,
+	)
+	// notmuch indexes the whole mail directory, accounts included
+	if account.Maildir != cfg.Email.Maildir {
+		if err := manager.SetDatabasePath(cfg.Email.Maildir); err != nil {
+			return nil, err
+		}
+	}
+	manager.SetSyncDriver(driver)
+	manager.SetSender(sender)
+	manager.SetDraftsFolder(account.Folders.Drafts)
+	return manager, nil
+}
+
+// newSyncer returns the syncer fetching an account's mail. The notmuch
+// backend indexes what was synced; the maildir backend reads it directly.
+func newSyncer(backend email.Backend, driver email.SyncDriver) *email.Syncer {
+	if manager, ok := backend.(*email.Manager); ok {
+		return manager.Syncer()
+	}
+	return email.NewSyncer(driver, nil)
+}
+
+// syncHooks returns the hooks run around an account's syncs, with
+// environment variables describing the account
+func syncHooks(account config.AccountConfig) email.SyncHooks {
+	return email.SyncHooks{
+		PreSync: account.PreSyncHook,
+		PostNew: account.PostNewHook,
+		Env: []string{
+			"MEL_ACCOUNT=" + account.Name,
+			"MEL_MAILDIR=" + account.Maildir,
+			"MEL_FROM=" + account.From(),
+			"MEL_SYNC_DRIVER=" + account.SyncDriver,
+		},
+	}
+}
+
+// newSyncDriver creates the driver of the sync tool an account uses
+func newSyncDriver(cfg *config.Config, account config.AccountConfig) (email.SyncDriver, error) {
+	switch account.SyncDriver {
+	case "offlineimap":
+		return email.OfflineimapDriver{Path: cfg.ExternalTools.Offlineimap, Account: account.OfflineimapAccount}, nil
+	case "command":
+		return email.NewCommandDriver(account.SyncCommand, account.Name, account.Maildir)
+	default:
+		return email.MbsyncDriver{Path: cfg.ExternalTools.Mbsync, Channel: account.MbsyncChannel}, nil
+	}
+}
+
+// newSender creates the sender for outgoing mail selected in the
+// configuration for an account
+func newSender(cfg *config.Config, account config.AccountConfig) *email.Sender {
+	return email.NewSender(cfg.ExternalTools.Msmtp, account.Sendmail)
+}
+
+// Doctor diagnoses the mail setup and prints actionable fixes
+func Doctor(w io.Writer) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	configPath, err := config.Path()
+	if err != nil {
+		return err
+	}
+
+	checks := doctor.New(cfg, configPath).Run(context.Background())
+	if failures := doctor.Print(w, checks); failures > 0 {
+		return fmt.Errorf("%d check(s) failed", failures)
+	}
+	return nil
+}
+
+// SendQueued sends the scheduled messages that are due, for running from a
+// systemd timer or cron while mel is closed. Messages the transfer agent
+// fails to send go to the outbox, which mel retries when it runs.
+func SendQueued(w io.Writer) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	accounts, err := newAccounts(cfg)
+	if err != nil {
+		return err
+	}
+	queue := outbox.New(cfg.Email.OutboxDir)
+
+	ctx := context.Background()
+	queued := 0
+	send := func(ctx context.Context, message []byte) error {
+		account := ui.AccountFor(accounts, accounts[0], message)
+		if err := account.Send(ctx, message); err != nil {
+			var sendErr *email.SendError
+			if !errors.As(err, &sendErr) {
+				return err
+			}
+			if _, err := queue.Enqueue(message, err); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Queued in the outbox: %v\n", err)
+			queued++
+			return nil
+		}
+		if sent := account.Config.Folders.Sent; sent != "" {
+			if err := account.Backend.StoreMessage(ctx, sent, message, []string{"sent"}); err != nil {
+				fmt.Fprintf(w, "Sent, but not saved to %s: %v\n", sent, err)
+			}
+		}
+		return nil
+	}
+
+	store := schedule.New(cfg.Email.ScheduleDir)
+	now := time.Now()
+	sent, err := store.Dispatch(ctx, send, now)
+	fmt.Fprintf(w, "Sent %d scheduled message(s)\n", sent-queued)
+	if err != nil {
+		return fmt.Errorf("failed to send scheduled messages: %w", err)
+	}
+
+	// Messages that cannot be sent wait to be edited in mel
+	entries, err := store.List()
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, entry := range entries {
+		if entry.LastError != "" && !entry.SendAt.After(now) {
+			fmt.Fprintf(w, "Not sent: %q: %s\n", entry.Subject, entry.LastError)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d scheduled message(s) could not be sent", failed)
+	}
+	return nil
+}
+
 // Run starts the application
 func Run(version string) error {
 	app, err := New(version)
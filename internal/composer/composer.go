@@ -0,0 +1,387 @@
+// Package composer builds outgoing messages: it turns headers, a text body
+// and attachments into RFC 5322/MIME output ready to hand to a sender.
+package composer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxHeaderLine is the line length headers are folded to when possible
+const maxHeaderLine = 78
+
+// Attachment is a file attached to an outgoing message
+type Attachment struct {
+	Filename    string
+	ContentType string // Guessed from the filename when empty
+	Data        []byte
+	Inline      bool   // Shown in the body rather than offered as a download
+	ContentID   string // Referenced as cid: from the HTML body, if any
+}
+
+// Message is an outgoing message before encoding
+type Message struct {
+	From       string
+	To         []string
+	Cc         []string
+	Bcc        []string // Kept in the output; the transfer agent strips it
+	ReplyTo    string
+	Subject    string
+	InReplyTo  string   // Message-ID of the parent, without angle brackets
+	References []string // Message-IDs of the ancestors, oldest first
+	Headers    map[string]string
+
+	TextBody    string
+	HTMLBody    string
+	Attachments []Attachment
+
+	// Date and MessageID are generated by Compose when empty
+	Date      time.Time
+	MessageID string
+}
+
+// Compose encodes a message as RFC 5322/MIME with CRLF line endings.
+// Missing Date and MessageID fields are generated and stored on msg.
+func Compose(msg *Message) ([]byte, error) {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid From address %q: %w", msg.From, err)
+	}
+
+	if msg.Date.IsZero() {
+		msg.Date = time.Now()
+	}
+	if msg.MessageID == "" {
+		msg.MessageID = GenerateMessageID(from.Address)
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "Date", msg.Date.Format(time.RFC1123Z))
+	writeHeader(&buf, "From", formatAddress(from))
+	if msg.ReplyTo != "" {
+		if err := writeAddressHeader(&buf, "Reply-To", []string{msg.ReplyTo}); err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range []struct {
+		name  string
+		value []string
+	}{{"To", msg.To}, {"Cc", msg.Cc}, {"Bcc", msg.Bcc}} {
+		if err := writeAddressHeader(&buf, field.name, field.value); err != nil {
+			return nil, err
+		}
+	}
+	writeHeader(&buf, "Subject", encodeHeader(msg.Subject))
+	writeHeader(&buf, "Message-ID", "<"+msg.MessageID+">")
+	if msg.InReplyTo != "" {
+		writeHeader(&buf, "In-Reply-To", "<"+msg.InReplyTo+">")
+	}
+	if len(msg.References) > 0 {
+		writeHeader(&buf, "References", "<"+strings.Join(msg.References, "> <")+">")
+	}
+
+	// Extra headers in a stable order
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeHeader(&buf, textproto.CanonicalMIMEHeaderKey(name), encodeHeader(msg.Headers[name]))
+	}
+
+	header, body, err := render(buildTree(msg))
+	if err != nil {
+		return nil, err
+	}
+	writeHeader(&buf, "MIME-Version", "1.0")
+	writePartHeader(&buf, header)
+	buf.WriteString("\r\n")
+	buf.Write(body)
+
+	return buf.Bytes(), nil
+}
+
+// GenerateMessageID returns a new globally unique Message-ID, without angle
+// brackets, using the domain of the sender address
+func GenerateMessageID(address string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(address, "@"); at >= 0 && at < len(address)-1 {
+		domain = address[at+1:]
+	} else if host, err := os.Hostname(); err == nil && host != "" {
+		domain = host
+	}
+
+	random := make([]byte, 8)
+	rand.Read(random)
+	return fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), hex.EncodeToString(random), domain)
+}
+
+// node is an element of the MIME tree being built: a container when
+// subtype is set, a leaf otherwise
+type node struct {
+	subtype  string // multipart subtype: mixed or alternative
+	children []*node
+	header   textproto.MIMEHeader
+	body     []byte // Encoded body of a leaf
+}
+
+// buildTree arranges the bodies and attachments into a MIME tree:
+// text and HTML become multipart/alternative, attachments multipart/mixed
+func buildTree(msg *Message) *node {
+	var body *node
+	switch {
+	case msg.TextBody != "" && msg.HTMLBody != "":
+		body = &node{subtype: "alternative", children: []*node{
+			textPart("text/plain", msg.TextBody),
+			textPart("text/html", msg.HTMLBody),
+		}}
+	case msg.HTMLBody != "":
+		body = textPart("text/html", msg.HTMLBody)
+	default:
+		body = textPart("text/plain", msg.TextBody)
+	}
+
+	if len(msg.Attachments) == 0 {
+		return body
+	}
+
+	mixed := &node{subtype: "mixed", children: []*node{body}}
+	for _, attachment := range msg.Attachments {
+		mixed.children = append(mixed.children, attachmentPart(attachment))
+	}
+	return mixed
+}
+
+// textPart builds a UTF-8 text leaf with the lightest suitable encoding
+func textPart(contentType, text string) *node {
+	data := []byte(normalizeNewlines(text))
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType(contentType, map[string]string{"charset": "utf-8"}))
+
+	encoding, body := encodeBody(data)
+	header.Set("Content-Transfer-Encoding", encoding)
+	return &node{header: header, body: body}
+}
+
+// attachmentPart builds a leaf for an attachment
+func attachmentPart(attachment Attachment) *node {
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(attachment.Filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+
+	header := textproto.MIMEHeader{}
+	disposition := "attachment"
+	if attachment.Inline {
+		disposition = "inline"
+	}
+	if attachment.Filename != "" {
+		// FormatMediaType switches to RFC 2231 for non-ASCII names
+		params["name"] = attachment.Filename
+		header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	} else {
+		header.Set("Content-Disposition", disposition)
+	}
+	header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+	if attachment.ContentID != "" {
+		header.Set("Content-ID", "<"+strings.Trim(attachment.ContentID, "<>")+">")
+	}
+
+	var encoding string
+	var body []byte
+	switch {
+	case mediaType == "message/rfc822":
+		// Embedded messages must not be encoded (RFC 2046 section 5.2.1)
+		body = []byte(normalizeNewlines(string(attachment.Data)))
+		encoding = "8bit"
+		if is7bit(body) {
+			encoding = "7bit"
+		}
+	case strings.HasPrefix(mediaType, "text/"):
+		encoding, body = encodeBody([]byte(normalizeNewlines(string(attachment.Data))))
+	default:
+		encoding, body = "base64", encodeBase64(attachment.Data)
+	}
+	header.Set("Content-Transfer-Encoding", encoding)
+
+	return &node{header: header, body: body}
+}
+
+// render encodes a node, returning the header that describes it and its body
+func render(n *node) (textproto.MIMEHeader, []byte, error) {
+	if n.subtype == "" {
+		return n.header, n.body, nil
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, child := range n.children {
+		header, body, err := render(child)
+		if err != nil {
+			return nil, nil, err
+		}
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create MIME part: %w", err)
+		}
+		part.Write(body)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to close multipart body: %w", err)
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType("multipart/"+n.subtype, map[string]string{"boundary": writer.Boundary()}))
+	return header, buf.Bytes(), nil
+}
+
+// encodeBody picks a transfer encoding for text: 7bit when it is plain
+// ASCII with short lines, quoted-printable when it is mostly ASCII and
+// base64 otherwise
+func encodeBody(data []byte) (string, []byte) {
+	if is7bit(data) {
+		return "7bit", data
+	}
+
+	nonASCII := 0
+	for _, b := range data {
+		if b >= 0x80 {
+			nonASCII++
+		}
+	}
+	if nonASCII*3 > len(data) {
+		return "base64", encodeBase64(data)
+	}
+
+	var buf bytes.Buffer
+	writer := quotedprintable.NewWriter(&buf)
+	writer.Write(data)
+	writer.Close()
+	return "quoted-printable", buf.Bytes()
+}
+
+// is7bit reports whether data can be sent without any encoding
+func is7bit(data []byte) bool {
+	lineLength := 0
+	for i, b := range data {
+		if b >= 0x80 || b == 0 {
+			return false
+		}
+		if b == '\r' && (i+1 >= len(data) || data[i+1] != '\n') {
+			return false
+		}
+		if b == '\n' {
+			lineLength = 0
+			continue
+		}
+		lineLength++
+		if lineLength > 998 {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeBase64 encodes data in 76-column lines
+func encodeBase64(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	return buf.Bytes()
+}
+
+// normalizeNewlines converts any line endings to CRLF
+func normalizeNewlines(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.ReplaceAll(text, "\n", "\r\n")
+}
+
+// encodeHeader encodes non-ASCII text as RFC 2047 encoded-words
+func encodeHeader(value string) string {
+	return mime.QEncoding.Encode("utf-8", value)
+}
+
+// writeAddressHeader writes an address list header, encoding display names
+func writeAddressHeader(buf *bytes.Buffer, name string, addresses []string) error {
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	formatted := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		parsed, err := mail.ParseAddressList(address)
+		if err != nil {
+			return fmt.Errorf("invalid %s address %q: %w", name, address, err)
+		}
+		for _, addr := range parsed {
+			formatted = append(formatted, formatAddress(addr))
+		}
+	}
+	writeHeader(buf, name, strings.Join(formatted, ", "))
+	return nil
+}
+
+// formatAddress renders an address for a header, encoding the display name
+// when needed and leaving bare addresses without angle brackets
+func formatAddress(addr *mail.Address) string {
+	if addr.Name == "" {
+		return addr.Address
+	}
+	return addr.String()
+}
+
+// writePartHeader writes MIME part headers in a stable order
+func writePartHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			writeHeader(buf, name, value)
+		}
+	}
+}
+
+// writeHeader writes a header field, folding it at spaces so lines stay
+// within maxHeaderLine columns where the value allows it
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	line := name + ":"
+	for _, word := range strings.Split(value, " ") {
+		// A long first word goes on its own line, right after the name
+		if line != "" && len(line)+1+len(word) > maxHeaderLine {
+			buf.WriteString(line + "\r\n")
+			line = ""
+		}
+		line += " " + word
+	}
+	buf.WriteString(line + "\r\n")
+}
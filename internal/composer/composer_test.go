@@ -0,0 +1,220 @@
+package composer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+// roundTrip composes a message and parses it back with the reader's MIME parser
+func roundTrip(t *testing.T, msg *Message) (*email.Message, []byte) {
+	t.Helper()
+	raw, err := Compose(msg)
+	if err != nil {
+		t.Fatalf("Compose failed: %v", err)
+	}
+	parsed, err := email.ParseMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseMessage failed: %v\n%s", err, raw)
+	}
+	return parsed, raw
+}
+
+func TestComposePlainText(t *testing.T) {
+	msg := &Message{
+		From:     "Alice <alice@example.com>",
+		To:       []string{"bob@example.com", "Carol <carol@example.org>"},
+		Subject:  "Lunch",
+		TextBody: "Noon?\nAt the usual place.\n",
+	}
+	parsed, raw := roundTrip(t, msg)
+
+	if msg.MessageID == "" || !strings.HasSuffix(msg.MessageID, "@example.com") {
+		t.Errorf("Expected a generated Message-ID at example.com, got %q", msg.MessageID)
+	}
+	if parsed.ID != msg.MessageID {
+		t.Errorf("Expected Message-ID %q, got %q", msg.MessageID, parsed.ID)
+	}
+	if msg.Date.IsZero() || parsed.Timestamp.Unix() != msg.Date.Unix() {
+		t.Errorf("Expected Date %v, got %v", msg.Date, parsed.Timestamp)
+	}
+	if parsed.From != "Alice <alice@example.com>" {
+		t.Errorf("Expected From to round-trip, got %q", parsed.From)
+	}
+	if len(parsed.To) != 2 || parsed.To[1] != "Carol <carol@example.org>" {
+		t.Errorf("Expected two To addresses, got %v", parsed.To)
+	}
+	if parsed.TextBody != "Noon?\r\nAt the usual place.\r\n" {
+		t.Errorf("Expected the body with CRLF line endings, got %q", parsed.TextBody)
+	}
+	if !bytes.Contains(raw, []byte("Content-Transfer-Encoding: 7bit\r\n")) {
+		t.Errorf("Expected ASCII text to be sent as 7bit:\n%s", raw)
+	}
+	if !bytes.Contains(raw, []byte("MIME-Version: 1.0\r\n")) {
+		t.Errorf("Expected a MIME-Version header:\n%s", raw)
+	}
+}
+
+func TestComposeEncodesHeaders(t *testing.T) {
+	msg := &Message{
+		From:       "André Dupont <andre@example.com>",
+		To:         []string{"Zoë <zoe@example.com>"},
+		Subject:    "Rapport de l'été — une très longue ligne d'objet qui doit être repliée proprement",
+		InReplyTo:  "parent@example.com",
+		References: []string{"root@example.com", "parent@example.com"},
+		Headers:    map[string]string{"user-agent": "mel"},
+		TextBody:   "Bonjour",
+	}
+	parsed, raw := roundTrip(t, msg)
+
+	header := raw[:bytes.Index(raw, []byte("\r\n\r\n"))]
+	for _, b := range header {
+		if b >= 0x80 {
+			t.Fatalf("Expected headers to be pure ASCII:\n%s", header)
+		}
+	}
+	for _, line := range strings.Split(string(header), "\r\n") {
+		if len(line) > maxHeaderLine {
+			t.Errorf("Expected header lines within %d columns, got %d: %q", maxHeaderLine, len(line), line)
+		}
+	}
+
+	if parsed.Subject != msg.Subject {
+		t.Errorf("Expected Subject %q, got %q", msg.Subject, parsed.Subject)
+	}
+	if parsed.From != "André Dupont <andre@example.com>" {
+		t.Errorf("Expected From name to be decoded, got %q", parsed.From)
+	}
+	if len(parsed.To) != 1 || parsed.To[0] != "Zoë <zoe@example.com>" {
+		t.Errorf("Expected To name to be decoded, got %v", parsed.To)
+	}
+	if parsed.InReplyTo != "parent@example.com" {
+		t.Errorf("Expected In-Reply-To parent@example.com, got %q", parsed.InReplyTo)
+	}
+	if len(parsed.References) != 2 || parsed.References[0] != "root@example.com" {
+		t.Errorf("Expected References to round-trip, got %v", parsed.References)
+	}
+	if parsed.Headers["User-Agent"] != "mel" {
+		t.Errorf("Expected extra header User-Agent, got %v", parsed.Headers)
+	}
+}
+
+func TestComposeTransferEncodings(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		encoding string
+	}{
+		{"ascii", "Hello\n", "7bit"},
+		{"long line", strings.Repeat("a", 1200), "quoted-printable"},
+		{"accents", "Voilà le café.\n", "quoted-printable"},
+		{"mostly non-ascii", "こんにちは世界、元気ですか", "base64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, raw := roundTrip(t, &Message{
+				From:     "alice@example.com",
+				To:       []string{"bob@example.com"},
+				TextBody: tt.body,
+			})
+			if !bytes.Contains(raw, []byte("Content-Transfer-Encoding: "+tt.encoding+"\r\n")) {
+				t.Errorf("Expected %s encoding:\n%s", tt.encoding, raw)
+			}
+			for _, line := range bytes.Split(raw, []byte("\r\n")) {
+				if len(line) > 998 {
+					t.Errorf("Expected no line over 998 octets, got %d", len(line))
+				}
+			}
+			if want := strings.ReplaceAll(tt.body, "\n", "\r\n"); parsed.TextBody != want {
+				t.Errorf("Expected body %q, got %q", want, parsed.TextBody)
+			}
+		})
+	}
+}
+
+func TestComposeMultipart(t *testing.T) {
+	pdf := bytes.Repeat([]byte{0x25, 0x50, 0x44, 0x46, 0x00, 0xff}, 100)
+	original := "From: carol@example.org\r\nSubject: Original\r\n\r\nThe original text.\r\n"
+	msg := &Message{
+		From:     "alice@example.com",
+		To:       []string{"bob@example.com"},
+		Subject:  "Report",
+		TextBody: "See the report.",
+		HTMLBody: "<p>See the <b>report</b>.</p>",
+		Attachments: []Attachment{
+			{Filename: "résumé.pdf", Data: pdf},
+			{Filename: "notes.txt", Data: []byte("plain notes\n")},
+			{ContentType: "message/rfc822", Filename: "original.eml", Data: []byte(original)},
+		},
+	}
+	parsed, raw := roundTrip(t, msg)
+
+	root := parsed.Parts[0]
+	if root.ContentType != "multipart/mixed" || len(root.Parts) != 4 {
+		t.Fatalf("Expected multipart/mixed with 4 parts, got %s with %d", root.ContentType, len(root.Parts))
+	}
+	alternative := root.Parts[0]
+	if alternative.ContentType != "multipart/alternative" || len(alternative.Parts) != 2 {
+		t.Fatalf("Expected multipart/alternative with 2 parts, got %s with %d", alternative.ContentType, len(alternative.Parts))
+	}
+	if parsed.TextBody != "See the report." {
+		t.Errorf("Expected text body, got %q", parsed.TextBody)
+	}
+	if parsed.HTMLBody != "<p>See the <b>report</b>.</p>" {
+		t.Errorf("Expected HTML body, got %q", parsed.HTMLBody)
+	}
+
+	if len(parsed.Attachments) < 2 {
+		t.Fatalf("Expected at least 2 attachments, got %d", len(parsed.Attachments))
+	}
+	attachment := parsed.Attachments[0]
+	if attachment.Filename != "résumé.pdf" || attachment.ContentType != "application/pdf" {
+		t.Errorf("Expected résumé.pdf as application/pdf, got %q as %s", attachment.Filename, attachment.ContentType)
+	}
+	if !bytes.Equal(attachment.Data, pdf) {
+		t.Errorf("Expected binary attachment to round-trip, got %d bytes", len(attachment.Data))
+	}
+	if notes := parsed.Attachments[1]; notes.Filename != "notes.txt" {
+		t.Errorf("Expected notes.txt attachment, got %q", notes.Filename)
+	}
+
+	embedded := root.Parts[3]
+	if embedded.ContentType != "message/rfc822" || embedded.ContentTransferEncoding != "7bit" {
+		t.Errorf("Expected an unencoded message/rfc822 part, got %s (%s)", embedded.ContentType, embedded.ContentTransferEncoding)
+	}
+	if !bytes.Contains(raw, []byte("\r\nThe original text.\r\n")) {
+		t.Errorf("Expected the embedded message to be included verbatim:\n%s", raw)
+	}
+}
+
+func TestComposeKeepsDateAndMessageID(t *testing.T) {
+	date := time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	msg := &Message{
+		From:      "alice@example.com",
+		Date:      date,
+		MessageID: "fixed@example.com",
+	}
+	raw, err := Compose(msg)
+	if err != nil {
+		t.Fatalf("Compose failed: %v", err)
+	}
+	if !bytes.Contains(raw, []byte("Date: Fri, 01 Mar 2024 09:00:00 +0100\r\n")) {
+		t.Errorf("Expected the given date:\n%s", raw)
+	}
+	if !bytes.Contains(raw, []byte("Message-ID: <fixed@example.com>\r\n")) {
+		t.Errorf("Expected the given Message-ID:\n%s", raw)
+	}
+}
+
+func TestComposeInvalidAddress(t *testing.T) {
+	if _, err := Compose(&Message{From: "not an address"}); err == nil {
+		t.Errorf("Expected an error for an invalid From address")
+	}
+	if _, err := Compose(&Message{From: "alice@example.com", To: []string{"<broken"}}); err == nil {
+		t.Errorf("Expected an error for an invalid To address")
+	}
+}
@@ -0,0 +1,196 @@
+package composer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+// draftHeaders are the headers offered for editing, in the order written
+var draftHeaders = []string{"From", "To", "Cc", "Bcc", "Subject"}
+
+// FormatDraft renders a message as an editable text file: a block of
+// headers, a blank line and the body. Extra headers follow the usual ones
+// so they can be edited too; threading headers are not shown and are kept
+// on the message as they are.
+func FormatDraft(msg *Message) []byte {
+	var b strings.Builder
+	for _, name := range draftHeaders {
+		b.WriteString(name + ": " + draftHeaderValue(msg, name) + "\n")
+	}
+	if msg.ReplyTo != "" {
+		b.WriteString("Reply-To: " + msg.ReplyTo + "\n")
+	}
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(textproto.CanonicalMIMEHeaderKey(name) + ": " + msg.Headers[name] + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(msg.TextBody)
+	return []byte(b.String())
+}
+
+// ParseDraft reads back a file written by FormatDraft after it was edited,
+// updating the headers and body of msg. Unknown headers replace the extra
+// headers, so deleting a header line or emptying it removes the field.
+func ParseDraft(data []byte, msg *Message) error {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	headerBlock, body, found := strings.Cut(text, "\n\n")
+	if !found {
+		// A file holding only headers, or only a body
+		if strings.Contains(strings.SplitN(text, "\n", 2)[0], ":") {
+			headerBlock, body = text, ""
+		} else {
+			headerBlock, body = "", text
+		}
+	}
+
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(headerBlock + "\n\n")))
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return fmt.Errorf("failed to parse draft headers: %w", err)
+	}
+
+	msg.From = strings.TrimSpace(header.Get("From"))
+	msg.ReplyTo = strings.TrimSpace(header.Get("Reply-To"))
+	msg.Subject = strings.TrimSpace(header.Get("Subject"))
+	msg.To = splitRecipients(header.Get("To"))
+	msg.Cc = splitRecipients(header.Get("Cc"))
+	msg.Bcc = splitRecipients(header.Get("Bcc"))
+	msg.TextBody = body
+
+	msg.Headers = nil
+	for name, values := range header {
+		switch name {
+		case "From", "Reply-To", "Subject", "To", "Cc", "Bcc":
+			continue
+		}
+		value := strings.TrimSpace(values[0])
+		if value == "" {
+			continue
+		}
+		if msg.Headers == nil {
+			msg.Headers = map[string]string{}
+		}
+		msg.Headers[name] = value
+	}
+
+	// Check addresses now so mistakes can be fixed before sending
+	for _, field := range [][]string{{msg.From}, msg.To, msg.Cc, msg.Bcc} {
+		for _, address := range field {
+			if address == "" {
+				continue
+			}
+			if _, err := mail.ParseAddress(address); err != nil {
+				return fmt.Errorf("invalid address %q: %w", address, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadDraft reads a saved draft back into a message, keeping its headers,
+// text and attachments. A new Message-ID is generated when it is saved again.
+func LoadDraft(path string) (*Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open draft: %w", err)
+	}
+	defer file.Close()
+	return ReadDraft(file)
+}
+
+// ReadDraft reads a saved message back into a draft, like LoadDraft
+func ReadDraft(r io.Reader) (*Message, error) {
+	parsed, err := email.ParseMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		From:       parsed.From,
+		To:         parsed.To,
+		Cc:         parsed.Cc,
+		Bcc:        email.DecodeAddressList(parsed.Headers["Bcc"]),
+		ReplyTo:    parsed.Headers["Reply-To"],
+		Subject:    parsed.Subject,
+		InReplyTo:  parsed.InReplyTo,
+		References: parsed.References,
+		TextBody:   strings.ReplaceAll(parsed.TextBody, "\r\n", "\n"),
+	}
+	for _, attachment := range parsed.Attachments {
+		msg.Attachments = append(msg.Attachments, Attachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Data:        attachment.Data,
+			Inline:      attachment.Inline,
+			ContentID:   attachment.ContentID,
+		})
+	}
+	return msg, nil
+}
+
+// AttachFile reads a file and adds it to the message as an attachment
+func (m *Message) AttachFile(path string) error {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read attachment: %w", err)
+	}
+	m.Attachments = append(m.Attachments, Attachment{Filename: filepath.Base(path), Data: data})
+	return nil
+}
+
+// draftHeaderValue returns the editable value of one of draftHeaders
+func draftHeaderValue(msg *Message, name string) string {
+	switch name {
+	case "From":
+		return msg.From
+	case "To":
+		return strings.Join(msg.To, ", ")
+	case "Cc":
+		return strings.Join(msg.Cc, ", ")
+	case "Bcc":
+		return strings.Join(msg.Bcc, ", ")
+	default:
+		return msg.Subject
+	}
+}
+
+// splitRecipients splits an edited address list into readable addresses.
+// Commas inside quoted display names do not split.
+func splitRecipients(value string) []string {
+	if addresses, err := mail.ParseAddressList(value); err == nil {
+		result := make([]string, 0, len(addresses))
+		for _, addr := range addresses {
+			result = append(result, email.FormatAddress(addr))
+		}
+		return result
+	}
+
+	// Keep invalid input so ParseDraft can report it
+	var result []string
+	for _, address := range strings.Split(value, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			result = append(result, address)
+		}
+	}
+	return result
+}
@@ -0,0 +1,142 @@
+package composer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDraftRoundTrip(t *testing.T) {
+	msg := &Message{
+		From:       "Alice <alice@example.com>",
+		To:         []string{"Bob <bob@example.org>"},
+		Subject:    "Re: Budget",
+		InReplyTo:  "parent@example.org",
+		References: []string{"parent@example.org"},
+		TextBody:   "\n\n> quoted\n",
+	}
+
+	draft := string(FormatDraft(msg))
+	want := "From: Alice <alice@example.com>\nTo: Bob <bob@example.org>\nCc: \nBcc: \nSubject: Re: Budget\n\n\n\n> quoted\n"
+	if draft != want {
+		t.Errorf("Expected draft %q, got %q", want, draft)
+	}
+
+	edited := strings.Replace(draft, "Cc: ", `Cc: "Doe, Carol" <carol@example.org>, dave@example.org`, 1)
+	edited = strings.Replace(edited, "\n\n\n\n", "\n\nThanks!\n\n", 1)
+	edited += "X-Priority: 1\n"
+	if err := ParseDraft([]byte(edited), msg); err != nil {
+		t.Fatalf("ParseDraft failed: %v", err)
+	}
+
+	if len(msg.Cc) != 2 || msg.Cc[0] != `"Doe, Carol" <carol@example.org>` || msg.Cc[1] != "dave@example.org" {
+		t.Errorf("Expected two Cc addresses, got %v", msg.Cc)
+	}
+	if len(msg.Bcc) != 0 {
+		t.Errorf("Expected no Bcc, got %v", msg.Bcc)
+	}
+	if msg.TextBody != "Thanks!\n\n> quoted\nX-Priority: 1\n" {
+		t.Errorf("Expected edited body, got %q", msg.TextBody)
+	}
+	if msg.InReplyTo != "parent@example.org" || len(msg.References) != 1 {
+		t.Errorf("Expected threading headers to be kept, got %q %v", msg.InReplyTo, msg.References)
+	}
+}
+
+func TestParseDraftExtraHeadersAndErrors(t *testing.T) {
+	msg := &Message{}
+	data := "From: alice@example.com\nTo: bob@example.org\nSubject: Hi\nX-Mailer-Note: test\n\nBody"
+	if err := ParseDraft([]byte(data), msg); err != nil {
+		t.Fatalf("ParseDraft failed: %v", err)
+	}
+	if msg.Headers["X-Mailer-Note"] != "test" || msg.TextBody != "Body" {
+		t.Errorf("Expected extra header and body, got %v %q", msg.Headers, msg.TextBody)
+	}
+
+	if err := ParseDraft([]byte("From: alice@example.com\nTo: bob@\n\nBody"), msg); err == nil {
+		t.Errorf("Expected an error for an invalid recipient")
+	}
+}
+
+func TestParseDraftRemovesHeaders(t *testing.T) {
+	msg := &Message{
+		From:    "alice@example.com",
+		Headers: map[string]string{"X-Keep": "yes", "X-Deleted": "line", "X-Emptied": "value"},
+	}
+
+	draft := string(FormatDraft(msg))
+	for _, line := range []string{"X-Keep: yes\n", "X-Deleted: line\n", "X-Emptied: value\n"} {
+		if !strings.Contains(draft, line) {
+			t.Errorf("Expected %q in the draft, got %q", line, draft)
+		}
+	}
+
+	edited := strings.Replace(draft, "X-Deleted: line\n", "", 1)
+	edited = strings.Replace(edited, "X-Emptied: value", "X-Emptied: ", 1)
+	if err := ParseDraft([]byte(edited), msg); err != nil {
+		t.Fatalf("ParseDraft failed: %v", err)
+	}
+	if len(msg.Headers) != 1 || msg.Headers["X-Keep"] != "yes" {
+		t.Errorf("Expected only X-Keep to remain, got %v", msg.Headers)
+	}
+}
+
+func TestAttachFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &Message{}
+	if err := msg.AttachFile(path); err != nil {
+		t.Fatalf("AttachFile failed: %v", err)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Filename != "report.pdf" || string(msg.Attachments[0].Data) != "%PDF-1.4" {
+		t.Errorf("Expected report.pdf to be attached, got %+v", msg.Attachments)
+	}
+	if err := msg.AttachFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func TestLoadDraft(t *testing.T) {
+	original := &Message{
+		From:        "Alice <alice@example.com>",
+		To:          []string{"Bob <bob@example.org>"},
+		Bcc:         []string{"archive@example.com"},
+		Subject:     "Re: Budget",
+		InReplyTo:   "parent@example.org",
+		References:  []string{"root@example.org", "parent@example.org"},
+		TextBody:    "Draft text\n",
+		Attachments: []Attachment{{Filename: "budget.pdf", Data: []byte("%PDF\x00\xff")}},
+	}
+	raw, err := Compose(original)
+	if err != nil {
+		t.Fatalf("Compose failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "draft")
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	draft, err := LoadDraft(path)
+	if err != nil {
+		t.Fatalf("LoadDraft failed: %v", err)
+	}
+	if draft.From != original.From || draft.Subject != original.Subject || draft.TextBody != original.TextBody {
+		t.Errorf("Expected headers and body to be kept, got %q %q %q", draft.From, draft.Subject, draft.TextBody)
+	}
+	if len(draft.To) != 1 || len(draft.Bcc) != 1 || draft.Bcc[0] != "archive@example.com" {
+		t.Errorf("Expected To and Bcc to be kept, got %v %v", draft.To, draft.Bcc)
+	}
+	if draft.InReplyTo != "parent@example.org" || len(draft.References) != 2 {
+		t.Errorf("Expected threading headers to be kept, got %q %v", draft.InReplyTo, draft.References)
+	}
+	if len(draft.Attachments) != 1 || string(draft.Attachments[0].Data) != "%PDF\x00\xff" {
+		t.Errorf("Expected the attachment to be kept, got %+v", draft.Attachments)
+	}
+	if draft.MessageID != "" {
+		t.Errorf("Expected no Message-ID on a loaded draft, got %q", draft.MessageID)
+	}
+}
@@ -0,0 +1,320 @@
+package composer
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+// maxReferences bounds the References header of replies in long threads;
+// the root and the most recent ancestors are kept (RFC 5322 section 3.6.4)
+const maxReferences = 20
+
+var (
+	// replyPrefix matches reply markers, including localised and counted
+	// forms such as "AW:", "SV:" and "Re[2]:"
+	replyPrefix = regexp.MustCompile(`(?i)^\s*(re|aw|sv|antw|vs|ref)(\[\d+\]|\(\d+\))?\s*:\s*`)
+
+	// forwardPrefix matches forward markers such as "Fwd:", "Fw:" and "TR:"
+	forwardPrefix = regexp.MustCompile(`(?i)^\s*(fwd|fw|tr|wg)(\[\d+\]|\(\d+\))?\s*:\s*`)
+)
+
+// ForwardMode selects how the original message is included in a forward
+type ForwardMode int
+
+const (
+	// ForwardInline quotes the original in the body and keeps its attachments
+	ForwardInline ForwardMode = iota
+	// ForwardAttachment attaches the original untouched as message/rfc822
+	ForwardAttachment
+)
+
+// Identity holds the user's own addresses: From is used to send and Aliases
+// are other addresses that also belong to the user
+type Identity struct {
+	From    string
+	Aliases []string
+}
+
+// IsOwn reports whether an address belongs to the user
+func (id Identity) IsOwn(address string) bool {
+	addr := addressOf(address)
+	if addr == "" {
+		return false
+	}
+	for _, own := range append([]string{id.From}, id.Aliases...) {
+		if addressOf(own) == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// fromFor picks the sender of a reply: the alias the original was sent to
+// when there is one, so replies come from the address people wrote to
+func (id Identity) fromFor(original *email.Message) string {
+	for _, recipient := range append(append([]string{}, original.To...), original.Cc...) {
+		for _, alias := range id.Aliases {
+			if addressOf(alias) == addressOf(recipient) {
+				return alias
+			}
+		}
+	}
+	return id.From
+}
+
+// Reply builds a reply to a message. With all set it is a reply-all: the
+// other recipients are kept in Cc, without the user's own addresses.
+func Reply(original *email.Message, identity Identity, all bool) *Message {
+	msg := &Message{
+		From:     identity.fromFor(original),
+		Subject:  ReplySubject(original.Subject),
+		TextBody: "\n\n" + Attribution(original) + "\n" + Quote(bodyOf(original)),
+	}
+
+	// Backends without a Message-ID fall back to the file path as the ID
+	if strings.Contains(original.ID, "@") {
+		inReplyTo, references := threadingOf(original)
+		if len(references) == 0 && inReplyTo != "" {
+			references = []string{inReplyTo}
+		}
+		msg.InReplyTo = original.ID
+		msg.References = append(references, original.ID)
+		if len(msg.References) > maxReferences {
+			tail := msg.References[len(msg.References)-maxReferences+1:]
+			msg.References = append([]string{msg.References[0]}, tail...)
+		}
+	}
+
+	// Replying to one of our own messages goes back to its recipients
+	switch {
+	case identity.IsOwn(original.From):
+		msg.To = original.To
+	case original.Headers["Reply-To"] != "":
+		msg.To = email.DecodeAddressList(original.Headers["Reply-To"])
+	default:
+		msg.To = []string{original.From}
+	}
+
+	if all {
+		msg.Cc = append(append([]string{}, original.To...), original.Cc...)
+	}
+
+	seen := map[string]bool{}
+	msg.To = dedupeAddresses(msg.To, identity, seen, len(msg.To) > 1)
+	msg.Cc = dedupeAddresses(msg.Cc, identity, seen, true)
+	return msg
+}
+
+// Forward builds a forward of a message, either quoted inline or with the
+// original attached as message/rfc822. Attaching needs the message file.
+func Forward(original *email.Message, identity Identity, mode ForwardMode) (*Message, error) {
+	msg := &Message{
+		From:    identity.From,
+		Subject: ForwardSubject(original.Subject),
+	}
+
+	if mode == ForwardAttachment {
+		if len(original.Filenames) == 0 {
+			return nil, fmt.Errorf("failed to forward message %s: no message file", original.ID)
+		}
+		data, err := os.ReadFile(original.Filenames[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message file: %w", err)
+		}
+		msg.Attachments = []Attachment{{
+			Filename:    attachmentName(original.Subject),
+			ContentType: "message/rfc822",
+			Data:        data,
+		}}
+		return msg, nil
+	}
+
+	var body strings.Builder
+	body.WriteString("\n\n---------- Forwarded message ----------\n")
+	body.WriteString("From: " + original.From + "\n")
+	if !original.Timestamp.IsZero() {
+		body.WriteString("Date: " + original.Timestamp.Format("Mon, 2 Jan 2006 at 15:04") + "\n")
+	}
+	body.WriteString("Subject: " + original.Subject + "\n")
+	if len(original.To) > 0 {
+		body.WriteString("To: " + strings.Join(original.To, ", ") + "\n")
+	}
+	if len(original.Cc) > 0 {
+		body.WriteString("Cc: " + strings.Join(original.Cc, ", ") + "\n")
+	}
+	body.WriteString("\n" + bodyOf(original))
+	msg.TextBody = body.String()
+
+	for _, attachment := range attachmentsOf(original) {
+		if len(attachment.Data) == 0 {
+			continue
+		}
+		msg.Attachments = append(msg.Attachments, Attachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Data:        attachment.Data,
+		})
+	}
+	return msg, nil
+}
+
+// ReplySubject prefixes a subject with a single "Re:"
+func ReplySubject(subject string) string {
+	return "Re: " + stripPrefixes(subject, replyPrefix)
+}
+
+// ForwardSubject prefixes a subject with a single "Fwd:"
+func ForwardSubject(subject string) string {
+	return "Fwd: " + stripPrefixes(subject, forwardPrefix)
+}
+
+// stripPrefixes removes any number of leading markers matched by prefix
+func stripPrefixes(subject string, prefix *regexp.Regexp) string {
+	for {
+		stripped := prefix.ReplaceAllString(subject, "")
+		if stripped == subject {
+			return strings.TrimSpace(subject)
+		}
+		subject = stripped
+	}
+}
+
+// Attribution returns the line introducing a quoted message
+func Attribution(original *email.Message) string {
+	if original.Timestamp.IsZero() {
+		return original.From + " wrote:"
+	}
+	return fmt.Sprintf("On %s, %s wrote:", original.Timestamp.Format("Mon, 2 Jan 2006 at 15:04"), original.From)
+}
+
+// Quote prefixes every line of text with "> ", leaving out the signature
+func Quote(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if i := strings.Index(text, "\n-- \n"); i >= 0 {
+		text = text[:i+1]
+	} else if strings.HasPrefix(text, "-- \n") {
+		text = ""
+	}
+	text = strings.TrimRight(text, "\n")
+
+	var quoted strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case line == "":
+			quoted.WriteString(">\n")
+		case strings.HasPrefix(line, ">"):
+			quoted.WriteString(">" + line + "\n")
+		default:
+			quoted.WriteString("> " + line + "\n")
+		}
+	}
+	return quoted.String()
+}
+
+// bodyOf returns the readable text of a message
+func bodyOf(original *email.Message) string {
+	if original.TextBody != "" {
+		return original.TextBody
+	}
+	return original.Body
+}
+
+// attachmentsOf returns the attachments of a message with their content,
+// parsing its file when the backend only listed them (notmuch does)
+func attachmentsOf(original *email.Message) []*email.Attachment {
+	complete := true
+	for _, attachment := range original.Attachments {
+		if len(attachment.Data) == 0 {
+			complete = false
+		}
+	}
+	if complete || len(original.Filenames) == 0 {
+		return original.Attachments
+	}
+
+	file, err := os.Open(original.Filenames[0])
+	if err != nil {
+		return original.Attachments
+	}
+	defer file.Close()
+
+	parsed, err := email.ParseMessage(file)
+	if err != nil {
+		return original.Attachments
+	}
+	return parsed.Attachments
+}
+
+// threadingOf returns the In-Reply-To and References of a message, reading
+// them from its file when the backend did not provide them (notmuch does not)
+func threadingOf(original *email.Message) (string, []string) {
+	if original.InReplyTo != "" || len(original.References) > 0 || len(original.Filenames) == 0 {
+		return original.InReplyTo, append([]string{}, original.References...)
+	}
+
+	file, err := os.Open(original.Filenames[0])
+	if err != nil {
+		return "", nil
+	}
+	defer file.Close()
+
+	msg, err := mail.ReadMessage(file)
+	if err != nil {
+		return "", nil
+	}
+	inReplyTo := email.ParseMessageIDs(msg.Header.Get("In-Reply-To"))
+	references := email.ParseMessageIDs(msg.Header.Get("References"))
+	if len(inReplyTo) > 0 {
+		return inReplyTo[0], references
+	}
+	return "", references
+}
+
+// dedupeAddresses drops the user's own addresses and addresses already in
+// seen. Own addresses are kept when dropping them would leave nothing, so
+// a note to self can still be answered.
+func dedupeAddresses(addresses []string, identity Identity, seen map[string]bool, dropOwn bool) []string {
+	var result []string
+	for _, address := range addresses {
+		addr := addressOf(address)
+		if addr == "" || seen[addr] || (dropOwn && identity.IsOwn(address)) {
+			continue
+		}
+		seen[addr] = true
+		result = append(result, address)
+	}
+	return result
+}
+
+// addressOf returns the lowercased bare address of a formatted address
+func addressOf(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		return strings.ToLower(parsed.Address)
+	}
+	if start := strings.LastIndex(address, "<"); start >= 0 {
+		if end := strings.Index(address[start:], ">"); end > 0 {
+			address = address[start+1 : start+end]
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// attachmentName turns a subject into a file name for a forwarded message
+func attachmentName(subject string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == filepath.Separator || r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(subject))
+	if name == "" {
+		name = "message"
+	}
+	return name + ".eml"
+}
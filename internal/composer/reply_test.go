@@ -0,0 +1,180 @@
+package composer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+var testIdentity = Identity{
+	From:    "Alice <alice@example.com>",
+	Aliases: []string{"Alice <alice@work.example>"},
+}
+
+func testOriginal() *email.Message {
+	return &email.Message{
+		ID:         "parent@example.org",
+		From:       "Bob <bob@example.org>",
+		To:         []string{"Alice <alice@work.example>", "Carol <carol@example.org>"},
+		Cc:         []string{"alice@example.com", "dave@example.org", "bob@example.org"},
+		Subject:    "Re: RE: Re[2]: Budget",
+		Timestamp:  time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+		TextBody:   "Numbers attached.\n> earlier quote\n\nBob\n-- \nBob's signature\n",
+		InReplyTo:  "grandparent@example.org",
+		References: []string{"root@example.org", "grandparent@example.org"},
+		Headers:    map[string]string{},
+	}
+}
+
+func TestReplySubject(t *testing.T) {
+	tests := map[string]string{
+		"Budget":              "Re: Budget",
+		"Re: Budget":          "Re: Budget",
+		"RE: re: Re: Budget":  "Re: Budget",
+		"Re[3]: Budget":       "Re: Budget",
+		"AW: Re: Budget":      "Re: Budget",
+		"Fwd: Budget":         "Re: Fwd: Budget",
+		"Reunion: next month": "Re: Reunion: next month",
+	}
+	for subject, want := range tests {
+		if got := ReplySubject(subject); got != want {
+			t.Errorf("Expected ReplySubject(%q) = %q, got %q", subject, want, got)
+		}
+	}
+
+	if got := ForwardSubject("Fwd: FW: Budget"); got != "Fwd: Budget" {
+		t.Errorf("Expected Fwd: Budget, got %q", got)
+	}
+	if got := ForwardSubject("Re: Budget"); got != "Fwd: Re: Budget" {
+		t.Errorf("Expected Fwd: Re: Budget, got %q", got)
+	}
+}
+
+func TestReply(t *testing.T) {
+	reply := Reply(testOriginal(), testIdentity, false)
+
+	if reply.Subject != "Re: Budget" {
+		t.Errorf("Expected subject Re: Budget, got %q", reply.Subject)
+	}
+	if reply.From != "Alice <alice@work.example>" {
+		t.Errorf("Expected the alias the message was sent to as sender, got %q", reply.From)
+	}
+	if len(reply.To) != 1 || reply.To[0] != "Bob <bob@example.org>" || len(reply.Cc) != 0 {
+		t.Errorf("Expected reply to Bob only, got To %v Cc %v", reply.To, reply.Cc)
+	}
+	if reply.InReplyTo != "parent@example.org" {
+		t.Errorf("Expected In-Reply-To parent@example.org, got %q", reply.InReplyTo)
+	}
+	want := []string{"root@example.org", "grandparent@example.org", "parent@example.org"}
+	if strings.Join(reply.References, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected References %v, got %v", want, reply.References)
+	}
+
+	body := "\n\nOn Fri, 1 Mar 2024 at 09:30, Bob <bob@example.org> wrote:\n" +
+		"> Numbers attached.\n>> earlier quote\n>\n> Bob\n"
+	if reply.TextBody != body {
+		t.Errorf("Expected quoted body without signature %q, got %q", body, reply.TextBody)
+	}
+}
+
+func TestReplyAll(t *testing.T) {
+	reply := Reply(testOriginal(), testIdentity, true)
+
+	if len(reply.To) != 1 || reply.To[0] != "Bob <bob@example.org>" {
+		t.Errorf("Expected To Bob, got %v", reply.To)
+	}
+	want := []string{"Carol <carol@example.org>", "dave@example.org"}
+	if strings.Join(reply.Cc, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected Cc %v without own or duplicate addresses, got %v", want, reply.Cc)
+	}
+}
+
+func TestReplyToHeaderAndOwnMessage(t *testing.T) {
+	original := testOriginal()
+	original.Headers["Reply-To"] = "List <list@example.org>"
+	if reply := Reply(original, testIdentity, false); len(reply.To) != 1 || reply.To[0] != "List <list@example.org>" {
+		t.Errorf("Expected reply to the Reply-To address, got %v", reply.To)
+	}
+
+	own := testOriginal()
+	own.From = "alice@example.com"
+	reply := Reply(own, testIdentity, false)
+	if len(reply.To) != 1 || reply.To[0] != "Carol <carol@example.org>" {
+		t.Errorf("Expected a reply to our own message to go to its recipients, got %v", reply.To)
+	}
+}
+
+func TestReplyReadsThreadingFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "message")
+	raw := "Message-ID: <parent@example.org>\r\nIn-Reply-To: <grandparent@example.org>\r\n" +
+		"From: bob@example.org\r\nSubject: Budget\r\n\r\nBody\r\n"
+	if err := os.WriteFile(path, []byte(raw), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	original := testOriginal()
+	original.InReplyTo = ""
+	original.References = nil
+	original.Filenames = []string{path}
+	reply := Reply(original, testIdentity, false)
+	if strings.Join(reply.References, " ") != "grandparent@example.org parent@example.org" {
+		t.Errorf("Expected References from the message file, got %v", reply.References)
+	}
+
+	// Without a real Message-ID there is nothing to thread on
+	original.ID = path
+	if reply := Reply(original, testIdentity, false); reply.InReplyTo != "" || len(reply.References) != 0 {
+		t.Errorf("Expected no threading headers for a path ID, got %q %v", reply.InReplyTo, reply.References)
+	}
+}
+
+func TestForward(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "message")
+	raw := "Message-ID: <parent@example.org>\r\nFrom: bob@example.org\r\nSubject: Budget\r\n\r\nBody\r\n"
+	if err := os.WriteFile(path, []byte(raw), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	original := testOriginal()
+	original.Filenames = []string{path}
+	original.Attachments = []*email.Attachment{{Filename: "budget.pdf", ContentType: "application/pdf", Data: []byte("%PDF")}}
+
+	inline, err := Forward(original, testIdentity, ForwardInline)
+	if err != nil {
+		t.Fatalf("Forward failed: %v", err)
+	}
+	if inline.Subject != "Fwd: Re: RE: Re[2]: Budget" || len(inline.To) != 0 {
+		t.Errorf("Expected an unaddressed Fwd: subject, got %q to %v", inline.Subject, inline.To)
+	}
+	if !strings.Contains(inline.TextBody, "---------- Forwarded message ----------\nFrom: Bob <bob@example.org>\n") ||
+		!strings.Contains(inline.TextBody, "Bob's signature") {
+		t.Errorf("Expected the full original inline, got %q", inline.TextBody)
+	}
+	if len(inline.Attachments) != 1 || inline.Attachments[0].Filename != "budget.pdf" {
+		t.Errorf("Expected the original attachment to be kept, got %v", inline.Attachments)
+	}
+
+	attached, err := Forward(original, testIdentity, ForwardAttachment)
+	if err != nil {
+		t.Fatalf("Forward failed: %v", err)
+	}
+	if len(attached.Attachments) != 1 || attached.Attachments[0].ContentType != "message/rfc822" ||
+		string(attached.Attachments[0].Data) != raw {
+		t.Errorf("Expected the original file as message/rfc822, got %+v", attached.Attachments)
+	}
+
+	// The forward must survive composition and parsing
+	attached.To = []string{"erin@example.org"}
+	parsed, _ := roundTrip(t, attached)
+	if len(parsed.Parts[0].Parts) != 2 || parsed.Parts[0].Parts[1].ContentType != "message/rfc822" {
+		t.Errorf("Expected a message/rfc822 part after round-trip, got %+v", parsed.Parts[0])
+	}
+
+	original.Filenames = nil
+	if _, err := Forward(original, testIdentity, ForwardAttachment); err == nil {
+		t.Errorf("Expected an error forwarding as attachment without a message file")
+	}
+}
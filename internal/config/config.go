@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
+	"net/mail"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,6 +15,10 @@ type Config struct {
 	// Email settings
 	Email EmailConfig `yaml:"email"`
 
+	// Mail accounts; without any, the email settings make up a single
+	// account
+	Accounts []AccountConfig `yaml:"accounts,omitempty"`
+
 	// UI settings
 	UI UIConfig `yaml:"ui"`
 
@@ -25,13 +31,225 @@ type EmailConfig struct {
 	// Maildir path (default: ~/Mail)
 	Maildir string `yaml:"maildir"`
 
-	// Default account to use
+	// Mail backend (auto, notmuch or maildir). "auto" uses notmuch when
+	// it is installed and falls back to reading the maildir directly.
+	Backend string `yaml:"backend"`
+
+	// Name of the account selected at startup (default: the first one)
 	DefaultAccount string `yaml:"default_account"`
 
+	// Sender address, e.g. "Jane Doe <jane@example.com>". Defaults to the
+	// user.name and user.primary_email settings of notmuch.
+	From string `yaml:"from,omitempty"`
+
+	// Other addresses of the user, left out of reply-all recipients
+	Aliases []string `yaml:"aliases,omitempty"`
+
+	// Folder where unfinished messages are saved (default: Drafts)
+	DraftsFolder string `yaml:"drafts_folder"`
+
+	// Folder where a copy of sent messages is saved (default: Sent). Leave
+	// empty when the mail server already keeps one, as Gmail does.
+	SentFolder string `yaml:"sent_folder"`
+
+	// Directory where messages that could not be sent wait to be retried
+	// (default: ~/.local/share/mel/outbox)
+	OutboxDir string `yaml:"outbox_dir"`
+
+	// Directory where messages scheduled to be sent later are kept
+	// (default: ~/.local/share/mel/scheduled)
+	ScheduleDir string `yaml:"schedule_dir"`
+
+	// Seconds a sent message is held back so it can be taken back with u
+	// (0 sends at once)
+	SendDelay int `yaml:"send_delay"`
+
 	// Auto-sync interval in seconds (0 to disable)
 	AutoSyncInterval int `yaml:"auto_sync_interval"`
 }
 
+// AccountConfig describes one mail account. Settings left out default to
+// the matching email and external_tools settings.
+type AccountConfig struct {
+	// Name shown in the sidebar
+	Name string `yaml:"name"`
+
+	// Root of the account's maildir (default: the account name inside
+	// email.maildir). It must be inside email.maildir when using notmuch,
+	// which indexes the whole tree.
+	Maildir string `yaml:"maildir,omitempty"`
+
+	// Addresses the user sends from on this account; the first one is the
+	// default sender, and all are left out of reply-all recipients
+	Identities []string `yaml:"identities,omitempty"`
+
+	// Tool syncing the account: mbsync, offlineimap or command (default:
+	// external_tools.sync_driver)
+	SyncDriver string `yaml:"sync_driver,omitempty"`
+
+	// mbsync channel or group syncing the account (default: the account name)
+	MbsyncChannel string `yaml:"mbsync_channel,omitempty"`
+
+	// offlineimap account syncing the account (default: the account name)
+	OfflineimapAccount string `yaml:"offlineimap_account,omitempty"`
+
+	// Command run by the command sync driver (default:
+	// external_tools.sync_command)
+	SyncCommand string `yaml:"sync_command,omitempty"`
+
+	// Shell commands run before syncing and after new mail is indexed
+	// (default: external_tools.pre_sync_hook and post_new_hook)
+	PreSyncHook string `yaml:"pre_sync_hook,omitempty"`
+	PostNewHook string `yaml:"post_new_hook,omitempty"`
+
+	// msmtp account used to send mail (default: chosen by msmtp from the
+	// sender address)
+	MsmtpAccount string `yaml:"msmtp_account,omitempty"`
+
+	// Sendmail-compatible command used instead of msmtp for this account
+	Sendmail string `yaml:"sendmail,omitempty"`
+
+	// Special folders of the account
+	Folders FolderMapping `yaml:"folders,omitempty"`
+}
+
+// FolderMapping names the special folders of an account
+type FolderMapping struct {
+	Inbox   string `yaml:"inbox,omitempty"`   // Default: INBOX
+	Drafts  string `yaml:"drafts,omitempty"`  // Default: email.drafts_folder
+	Sent    string `yaml:"sent,omitempty"`    // Default: email.sent_folder
+	Trash   string `yaml:"trash,omitempty"`   // Default: Trash
+	Archive string `yaml:"archive,omitempty"` // Default: Archive
+}
+
+// From returns the default sender address of the account
+func (a *AccountConfig) From() string {
+	if len(a.Identities) == 0 {
+		return ""
+	}
+	return a.Identities[0]
+}
+
+// HasAddress reports whether an email address is one of the account's
+// identities
+func (a *AccountConfig) HasAddress(address string) bool {
+	for _, identity := range a.Identities {
+		parsed, err := mail.ParseAddress(identity)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(parsed.Address, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// AccountList returns the configured accounts with their defaults filled in.
+// Without an accounts list, the email settings make up a single account
+// syncing every mbsync channel or offlineimap account.
+func (c *Config) AccountList() ([]AccountConfig, error) {
+	if len(c.Accounts) == 0 {
+		account := AccountConfig{
+			Name:     filepath.Base(c.Email.Maildir),
+			Maildir:  c.Email.Maildir,
+			Sendmail: c.ExternalTools.Sendmail,
+		}
+		if c.Email.From != "" {
+			account.Identities = append([]string{c.Email.From}, c.Email.Aliases...)
+		}
+		if err := c.fillSync(&account); err != nil {
+			return nil, err
+		}
+		c.fillFolders(&account.Folders)
+		return []AccountConfig{account}, nil
+	}
+
+	accounts := make([]AccountConfig, 0, len(c.Accounts))
+	seen := make(map[string]bool)
+	for _, account := range c.Accounts {
+		if account.Name == "" {
+			return nil, fmt.Errorf("account with maildir %q has no name", account.Maildir)
+		}
+		if seen[account.Name] {
+			return nil, fmt.Errorf("duplicate account %q", account.Name)
+		}
+		seen[account.Name] = true
+
+		if account.Maildir == "" {
+			account.Maildir = filepath.Join(c.Email.Maildir, account.Name)
+		}
+		if len(account.Identities) == 0 && c.Email.From != "" {
+			account.Identities = append([]string{c.Email.From}, c.Email.Aliases...)
+		}
+		if account.MbsyncChannel == "" {
+			account.MbsyncChannel = account.Name
+		}
+		if account.OfflineimapAccount == "" {
+			account.OfflineimapAccount = account.Name
+		}
+		if account.Sendmail == "" {
+			account.Sendmail = c.ExternalTools.Sendmail
+		}
+		if err := c.fillSync(&account); err != nil {
+			return nil, fmt.Errorf("account %s: %w", account.Name, err)
+		}
+		c.fillFolders(&account.Folders)
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+// fillSync sets the sync driver, command and hooks left out of an account
+// and checks them
+func (c *Config) fillSync(account *AccountConfig) error {
+	if account.PreSyncHook == "" {
+		account.PreSyncHook = c.ExternalTools.PreSyncHook
+	}
+	if account.PostNewHook == "" {
+		account.PostNewHook = c.ExternalTools.PostNewHook
+	}
+	if account.SyncDriver == "" {
+		account.SyncDriver = c.ExternalTools.SyncDriver
+	}
+	if account.SyncDriver == "" {
+		account.SyncDriver = "mbsync"
+	}
+	if account.SyncCommand == "" {
+		account.SyncCommand = c.ExternalTools.SyncCommand
+	}
+
+	switch account.SyncDriver {
+	case "mbsync", "offlineimap":
+	case "command":
+		if strings.TrimSpace(account.SyncCommand) == "" {
+			return fmt.Errorf("sync_driver command needs a sync_command")
+		}
+	default:
+		return fmt.Errorf("invalid sync_driver %q; allowed: mbsync, offlineimap, command", account.SyncDriver)
+	}
+	return nil
+}
+
+// fillFolders sets the special folders left out of an account mapping
+func (c *Config) fillFolders(folders *FolderMapping) {
+	if folders.Inbox == "" {
+		folders.Inbox = "INBOX"
+	}
+	if folders.Drafts == "" {
+		folders.Drafts = c.Email.DraftsFolder
+	}
+	if folders.Sent == "" {
+		folders.Sent = c.Email.SentFolder
+	}
+	if folders.Trash == "" {
+		folders.Trash = "Trash"
+	}
+	if folders.Archive == "" {
+		folders.Archive = "Archive"
+	}
+}
+
 // UIConfig contains UI-related configuration
 type UIConfig struct {
 	// Theme settings
@@ -72,6 +290,27 @@ type ExternalToolsConfig struct {
 
 	// Path to msmtp executable
 	Msmtp string `yaml:"msmtp"`
+
+	// Sendmail-compatible command used instead of msmtp when set,
+	// e.g. "sendmail -t -oi"; it must read recipients from the headers
+	Sendmail string `yaml:"sendmail,omitempty"`
+
+	// Path to offlineimap executable
+	Offlineimap string `yaml:"offlineimap"`
+
+	// Tool syncing accounts that do not choose one: mbsync, offlineimap or
+	// command (default: mbsync)
+	SyncDriver string `yaml:"sync_driver,omitempty"`
+
+	// Custom sync command for the command driver, with {{.Account}},
+	// {{.Maildir}} and {{.Folder}} replaced, e.g. "gmi sync -C {{.Maildir}}"
+	SyncCommand string `yaml:"sync_command,omitempty"`
+
+	// Shell command run before each sync; the sync is skipped if it fails
+	PreSyncHook string `yaml:"pre_sync_hook,omitempty"`
+
+	// Shell command run once synced mail is indexed, e.g. "afew --tag --new"
+	PostNewHook string `yaml:"post_new_hook,omitempty"`
 }
 
 // DefaultConfig returns the default configuration
@@ -81,7 +320,12 @@ func DefaultConfig() *Config {
 	return &Config{
 		Email: EmailConfig{
 			Maildir:          filepath.Join(homeDir, "Mail"),
+			Backend:          "auto",
 			DefaultAccount:   "",
+			DraftsFolder:     "Drafts",
+			SentFolder:       "Sent",
+			OutboxDir:        filepath.Join(dataDir(homeDir), "outbox"),
+			ScheduleDir:      filepath.Join(dataDir(homeDir), "scheduled"),
 			AutoSyncInterval: 300, // 5 minutes
 		},
 		UI: UIConfig{
@@ -96,9 +340,10 @@ func DefaultConfig() *Config {
 			},
 		},
 		ExternalTools: ExternalToolsConfig{
-			Mbsync:  "mbsync",
-			Notmuch: "notmuch",
-			Msmtp:   "msmtp",
+			Mbsync:      "mbsync",
+			Notmuch:     "notmuch",
+			Msmtp:       "msmtp",
+			Offlineimap: "offlineimap",
 		},
 	}
 }
@@ -155,6 +400,20 @@ func (c *Config) Save() error {
 	return nil
 }
 
+// Path returns the path of the configuration file, whether or not it exists
+func Path() (string, error) {
+	return getConfigPath()
+}
+
+// dataDir returns the directory where mel keeps its own data, following
+// the XDG base directory specification
+func dataDir(homeDir string) string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "mel")
+	}
+	return filepath.Join(homeDir, ".local", "share", "mel")
+}
+
 // getConfigPath returns the path to the configuration file
 func getConfigPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
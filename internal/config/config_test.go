@@ -30,3 +30,67 @@ func TestConfigLoad(t *testing.T) {
 		t.Fatal("Load() returned nil config")
 	}
 }
+
+func TestAccountList(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Email.Maildir = "/home/jane/Mail"
+	cfg.Email.From = "Jane Doe <jane@example.com>"
+
+	// Without accounts, the email settings make up a single account
+	accounts, err := cfg.AccountList()
+	if err != nil {
+		t.Fatalf("AccountList failed: %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].Maildir != "/home/jane/Mail" || accounts[0].MbsyncChannel != "" {
+		t.Fatalf("Expected a single account syncing every channel, got %+v", accounts)
+	}
+	if accounts[0].From() != cfg.Email.From || accounts[0].Folders.Drafts != "Drafts" || accounts[0].SyncDriver != "mbsync" {
+		t.Errorf("Expected the email settings, got %+v", accounts[0])
+	}
+
+	cfg.Accounts = []AccountConfig{
+		{Name: "work", Identities: []string{"jane@work.example", "j.doe@work.example"}, Folders: FolderMapping{Sent: "Sent Items"}},
+		{Name: "home", Maildir: "/srv/mail/home", MbsyncChannel: "gmail", PostNewHook: "notmuch tag +home -- tag:new"},
+		{Name: "gmail", SyncDriver: "command", SyncCommand: "gmi sync -C {{.Maildir}}"},
+	}
+	cfg.ExternalTools.SyncDriver = "offlineimap"
+	cfg.ExternalTools.PostNewHook = "afew --tag --new"
+	accounts, err = cfg.AccountList()
+	if err != nil {
+		t.Fatalf("AccountList failed: %v", err)
+	}
+	work, home, gmail := accounts[0], accounts[1], accounts[2]
+	if work.Maildir != "/home/jane/Mail/work" || work.MbsyncChannel != "work" {
+		t.Errorf("Expected the maildir and channel to default to the name, got %+v", work)
+	}
+	if work.Folders.Sent != "Sent Items" || work.Folders.Inbox != "INBOX" || work.Folders.Drafts != "Drafts" {
+		t.Errorf("Expected the folder mapping with defaults, got %+v", work.Folders)
+	}
+	if !work.HasAddress("J.Doe@work.example") || work.HasAddress("jane@example.com") {
+		t.Errorf("Expected only the account identities to match")
+	}
+	if home.Maildir != "/srv/mail/home" || home.MbsyncChannel != "gmail" || home.From() != cfg.Email.From {
+		t.Errorf("Expected the configured settings kept, got %+v", home)
+	}
+	if work.PostNewHook != "afew --tag --new" || home.PostNewHook != "notmuch tag +home -- tag:new" {
+		t.Errorf("Expected the post-new hook to default to external_tools, got %q", work.PostNewHook)
+	}
+	if work.SyncDriver != "offlineimap" || work.OfflineimapAccount != "work" || gmail.SyncDriver != "command" {
+		t.Errorf("Expected the sync driver to default to external_tools, got %q and %q", work.SyncDriver, gmail.SyncDriver)
+	}
+
+	cfg.Accounts[2].SyncCommand = ""
+	if _, err := cfg.AccountList(); err == nil {
+		t.Errorf("Expected an error for the command driver without a command")
+	}
+	cfg.Accounts[2].SyncDriver = "fetchmail"
+	if _, err := cfg.AccountList(); err == nil {
+		t.Errorf("Expected an error for an unknown sync driver")
+	}
+	cfg.Accounts = cfg.Accounts[:2]
+
+	cfg.Accounts = append(cfg.Accounts, AccountConfig{Name: "work"})
+	if _, err := cfg.AccountList(); err == nil {
+		t.Errorf("Expected an error for duplicate account names")
+	}
+}
@@ -0,0 +1,348 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/romaintb/mel/internal/config"
+	"github.com/romaintb/mel/internal/email"
+)
+
+// Status is the outcome of a single check
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusWarn
+	StatusFail
+)
+
+// String returns the label printed in front of a check
+func (s Status) String() string {
+	switch s {
+	case StatusWarn:
+		return "warn"
+	case StatusFail:
+		return "FAIL"
+	default:
+		return " ok "
+	}
+}
+
+// Check is the result of one diagnostic
+type Check struct {
+	Name   string
+	Status Status
+	Detail string // What was found
+	Fix    string // How to fix a warning or failure
+}
+
+// Doctor diagnoses the environment mel runs in: external tools, the notmuch
+// configuration and database, and the maildir layout and permissions
+type Doctor struct {
+	config     *config.Config
+	configPath string
+	runner     *email.Runner
+}
+
+// New creates a new doctor for a configuration loaded from configPath
+func New(cfg *config.Config, configPath string) *Doctor {
+	return &Doctor{
+		config:     cfg,
+		configPath: configPath,
+		runner:     email.NewRunner(email.DefaultTimeouts()),
+	}
+}
+
+// Run performs every check and returns the results in order
+func (d *Doctor) Run(ctx context.Context) []Check {
+	var checks []Check
+
+	checks = append(checks, d.checkConfig())
+
+	tools := d.config.ExternalTools
+	notmuch := d.checkTool("notmuch", "external_tools.notmuch", tools.Notmuch, d.notmuchRequired())
+	checks = append(checks, notmuch)
+	checks = append(checks, d.checkSyncTools()...)
+	if sendmail := strings.Fields(tools.Sendmail); len(sendmail) > 0 {
+		// A sendmail-compatible command replaces msmtp entirely
+		checks = append(checks, d.checkTool("sendmail", "external_tools.sendmail", sendmail[0], false))
+	} else {
+		checks = append(checks, d.checkTool("msmtp", "external_tools.msmtp", tools.Msmtp, false))
+	}
+	if notmuch.Status == StatusOK {
+		checks = append(checks, d.checkNotmuchConfig(ctx), d.checkNotmuchDatabase(ctx))
+	}
+
+	for _, account := range d.maildirAccounts() {
+		maildir := d.checkMaildir(account)
+		checks = append(checks, maildir)
+		if maildir.Status != StatusFail {
+			checks = append(checks, d.checkWritable(account))
+		}
+	}
+
+	return checks
+}
+
+// Print writes the checks and returns the number of failures
+func Print(w io.Writer, checks []Check) int {
+	failures := 0
+	for _, check := range checks {
+		fmt.Fprintf(w, "[%s] %s: %s\n", check.Status, check.Name, check.Detail)
+		if check.Fix != "" && check.Status != StatusOK {
+			fmt.Fprintf(w, "       fix: %s\n", check.Fix)
+		}
+		if check.Status == StatusFail {
+			failures++
+		}
+	}
+	return failures
+}
+
+// notmuchRequired reports whether the configured backend needs notmuch
+func (d *Doctor) notmuchRequired() bool {
+	return strings.EqualFold(strings.TrimSpace(d.config.Email.Backend), "notmuch")
+}
+
+// checkConfig reports which configuration file is in use
+func (d *Doctor) checkConfig() Check {
+	check := Check{Name: "config"}
+	if _, err := os.Stat(d.configPath); err != nil {
+		check.Status = StatusWarn
+		check.Detail = "no config file, using defaults"
+		check.Fix = "create " + d.configPath + " to customise mel"
+		return check
+	}
+	check.Detail = d.configPath
+	return check
+}
+
+// checkTool looks up an external tool binary
+func (d *Doctor) checkTool(name, key, path string, required bool) Check {
+	check := Check{Name: name}
+	found, err := exec.LookPath(path)
+	if err == nil {
+		check.Detail = found
+		return check
+	}
+
+	check.Status = StatusWarn
+	if required {
+		check.Status = StatusFail
+	}
+	check.Detail = fmt.Sprintf("%q not found", path)
+	check.Fix = fmt.Sprintf("install %s or set %s in %s", name, key, d.configPath)
+
+	switch name {
+	case "notmuch":
+		if !required {
+			check.Detail += "; mel falls back to reading the maildir directly"
+		}
+	case "mbsync", "offlineimap", "sync command":
+		check.Detail += "; mail cannot be synchronized"
+	case "msmtp", "sendmail":
+		check.Detail += "; mail cannot be sent"
+	}
+	return check
+}
+
+// checkSyncTools looks up the sync tool of every account, once per tool
+func (d *Doctor) checkSyncTools() []Check {
+	tools := d.config.ExternalTools
+	accounts, err := d.config.AccountList()
+	if err != nil {
+		return []Check{{Name: "sync", Status: StatusFail, Detail: err.Error(), Fix: "fix the accounts in " + d.configPath}}
+	}
+
+	var checks []Check
+	seen := make(map[string]bool)
+	for _, account := range accounts {
+		var check Check
+		switch account.SyncDriver {
+		case "offlineimap":
+			check = d.checkTool("offlineimap", "external_tools.offlineimap", tools.Offlineimap, false)
+		case "command":
+			check = d.checkTool("sync command", "sync_command", strings.Fields(account.SyncCommand)[0], false)
+		default:
+			check = d.checkTool("mbsync", "external_tools.mbsync", tools.Mbsync, false)
+		}
+		if key := check.Name + " " + check.Detail; !seen[key] {
+			seen[key] = true
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// checkNotmuchConfig compares the maildir notmuch indexes with mel's
+func (d *Doctor) checkNotmuchConfig(ctx context.Context) Check {
+	check := Check{Name: "notmuch config"}
+
+	// database.mail_root is preferred by recent notmuch versions
+	var root string
+	for _, key := range []string{"database.mail_root", "database.path"} {
+		output, err := d.runner.Run(ctx, email.Command{
+			Path:    d.config.ExternalTools.Notmuch,
+			Args:    []string{"config", "get", key},
+			Timeout: d.runner.Timeouts.Query,
+		})
+		if err == nil && strings.TrimSpace(string(output)) != "" {
+			root = strings.TrimSpace(string(output))
+			break
+		}
+	}
+
+	if root == "" {
+		check.Status = StatusFail
+		check.Detail = "no mail root configured"
+		check.Fix = "run `notmuch setup` and point it at " + d.config.Email.Maildir
+		return check
+	}
+
+	if !samePath(root, d.config.Email.Maildir) {
+		check.Status = StatusWarn
+		check.Detail = fmt.Sprintf("notmuch indexes %s but mel reads %s", root, d.config.Email.Maildir)
+		check.Fix = "set email.maildir in " + d.configPath + " or database.path with `notmuch config set`"
+		return check
+	}
+
+	check.Detail = "mail root " + root
+	return check
+}
+
+// checkNotmuchDatabase makes sure the database can be opened and queried
+func (d *Doctor) checkNotmuchDatabase(ctx context.Context) Check {
+	check := Check{Name: "notmuch database"}
+
+	tools := d.config.ExternalTools
+	manager := email.NewManager(d.config.Email.Maildir, tools.Notmuch, tools.Mbsync, tools.Msmtp)
+	count, err := manager.CountMessages(ctx, "*")
+	if err != nil {
+		check.Status = StatusFail
+		if errors.Is(err, email.ErrDatabaseLocked) {
+			check.Status = StatusWarn
+		}
+		check.Detail = err.Error()
+		check.Fix = email.Hint(err)
+		if check.Fix == "" {
+			check.Fix = "run `notmuch new` from a shell to see the full error"
+		}
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("%d messages indexed", count)
+	return check
+}
+
+// maildirAccounts returns the accounts whose maildir is checked, falling back
+// to email.maildir when the accounts are invalid
+func (d *Doctor) maildirAccounts() []config.AccountConfig {
+	accounts, err := d.config.AccountList()
+	if err != nil {
+		return []config.AccountConfig{{Maildir: d.config.Email.Maildir}}
+	}
+	return accounts
+}
+
+// accountCheck names a per-account check after the account when several
+// accounts are configured
+func (d *Doctor) accountCheck(name string, account config.AccountConfig) Check {
+	if len(d.config.Accounts) > 0 {
+		name += " " + account.Name
+	}
+	return Check{Name: name}
+}
+
+// checkMaildir checks that the maildir of an account exists and contains
+// folders
+func (d *Doctor) checkMaildir(account config.AccountConfig) Check {
+	check := d.accountCheck("maildir", account)
+	root := account.Maildir
+
+	info, err := os.Stat(root)
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = fmt.Sprintf("%s: %v", root, err)
+		check.Fix = email.Hint(email.ErrMaildirMissing)
+		return check
+	}
+	if !info.IsDir() {
+		check.Status = StatusFail
+		check.Detail = root + " is not a directory"
+		check.Fix = "set email.maildir in " + d.configPath + " to your mail directory"
+		if len(d.config.Accounts) > 0 {
+			check.Fix = "set the maildir of account " + account.Name + " in " + d.configPath
+		}
+		return check
+	}
+
+	folders, err := email.ScanFolders(root)
+	if err != nil {
+		check.Status = StatusFail
+		check.Detail = err.Error()
+		return check
+	}
+
+	layout := email.DetectLayout(root)
+	check.Detail = fmt.Sprintf("%s, %d folders, %s layout", root, len(folders), layout)
+
+	if len(folders) == 0 {
+		check.Status = StatusWarn
+		check.Fix = "no folders with cur/new/tmp found; run your sync tool (e.g. `mbsync -a`) to download mail"
+		return check
+	}
+	for _, folder := range folders {
+		if strings.EqualFold(folder.Name, "INBOX") {
+			return check
+		}
+	}
+	check.Status = StatusWarn
+	check.Fix = "no INBOX folder found; check the Inbox setting of your sync tool"
+	return check
+}
+
+// checkWritable makes sure mel can write to the maildir, which flag changes,
+// drafts and sent mail all need
+func (d *Doctor) checkWritable(account config.AccountConfig) Check {
+	check := d.accountCheck("permissions", account)
+	root := account.Maildir
+
+	dirs := []string{root}
+	if folders, err := email.ScanFolders(root); err == nil && len(folders) > 0 {
+		// Deliveries go through tmp/, so a stray test file never looks like mail
+		dirs = append(dirs, filepath.Join(folders[0].Path, "tmp"))
+	}
+
+	for _, dir := range dirs {
+		file, err := os.CreateTemp(dir, ".mel-doctor-*")
+		if err != nil {
+			check.Status = StatusFail
+			check.Detail = fmt.Sprintf("cannot write to %s", dir)
+			check.Fix = "give your user write access, e.g. `chmod -R u+w " + root + "`"
+			return check
+		}
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	check.Detail = "maildir is writable"
+	return check
+}
+
+// samePath compares two directory paths after cleaning and resolving links
+func samePath(a, b string) bool {
+	clean := func(p string) string {
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			p = resolved
+		}
+		return filepath.Clean(p)
+	}
+	return clean(a) == clean(b)
+}
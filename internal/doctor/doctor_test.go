@@ -0,0 +1,117 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/romaintb/mel/internal/config"
+)
+
+// writeTool writes an executable shell script standing in for an external tool
+func writeTool(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDoctor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools need a POSIX shell")
+	}
+
+	maildir := t.TempDir()
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(maildir, "INBOX", sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bin := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Email.Maildir = maildir
+	cfg.ExternalTools.Notmuch = writeTool(t, bin, "notmuch", `case "$1" in
+config) echo "`+maildir+`" ;;
+count) echo 42 ;;
+esac
+`)
+	cfg.ExternalTools.Mbsync = writeTool(t, bin, "mbsync", "exit 0\n")
+	cfg.ExternalTools.Msmtp = filepath.Join(bin, "msmtp")
+
+	checks := New(cfg, filepath.Join(t.TempDir(), "config.yaml")).Run(context.Background())
+
+	statuses := make(map[string]Status)
+	for _, check := range checks {
+		statuses[check.Name] = check.Status
+	}
+	want := map[string]Status{
+		"config":           StatusWarn,
+		"notmuch":          StatusOK,
+		"mbsync":           StatusOK,
+		"msmtp":            StatusWarn,
+		"notmuch config":   StatusOK,
+		"notmuch database": StatusOK,
+		"maildir":          StatusOK,
+		"permissions":      StatusOK,
+	}
+	for name, status := range want {
+		if got, ok := statuses[name]; !ok || got != status {
+			t.Errorf("Check %s: expected %s, got %s (present: %v)", name, status, got, ok)
+		}
+	}
+
+	var out bytes.Buffer
+	if failures := Print(&out, checks); failures != 0 {
+		t.Errorf("Expected no failures, got %d:\n%s", failures, out.String())
+	}
+	if !strings.Contains(out.String(), "fix: install msmtp") {
+		t.Errorf("Expected a fix for the missing msmtp, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "42 messages indexed") {
+		t.Errorf("Expected the database count, got:\n%s", out.String())
+	}
+}
+
+func TestDoctorMissingMaildir(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Email.Maildir = filepath.Join(t.TempDir(), "Mail")
+	cfg.Email.Backend = "notmuch"
+	cfg.ExternalTools.Notmuch = filepath.Join(t.TempDir(), "notmuch")
+
+	var out bytes.Buffer
+	failures := Print(&out, New(cfg, "config.yaml").Run(context.Background()))
+	if failures != 2 {
+		t.Errorf("Expected missing notmuch and maildir to fail, got %d:\n%s", failures, out.String())
+	}
+}
+
+func TestDoctorChecksEveryAccount(t *testing.T) {
+	root := t.TempDir()
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, "personal", "INBOX", sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Email.Maildir = root
+	cfg.Accounts = []config.AccountConfig{{Name: "personal"}, {Name: "work"}}
+
+	statuses := make(map[string]Status)
+	for _, check := range New(cfg, "config.yaml").Run(context.Background()) {
+		statuses[check.Name] = check.Status
+	}
+	if statuses["maildir personal"] != StatusOK || statuses["permissions personal"] != StatusOK {
+		t.Errorf("Expected the personal maildir to pass, got %v", statuses)
+	}
+	if status, ok := statuses["maildir work"]; !ok || status != StatusFail {
+		t.Errorf("Expected the missing work maildir to fail, got %v", statuses)
+	}
+}
@@ -0,0 +1,62 @@
+package email
+
+import "context"
+
+// Backend is the interface implemented by mail storage backends.
+// The notmuch-based Manager is the default implementation; other
+// backends (or test fakes) can be plugged into the UI and search
+// service through this interface. Every call takes a context so the
+// UI can cancel slow operations.
+type Backend interface {
+	// GetMailFolders returns all available folders
+	GetMailFolders(ctx context.Context) ([]*MailFolder, error)
+
+	// StreamMailFolders lists folders progressively: the folder list first,
+	// then counts as they become available. The channel is closed when done.
+	StreamMailFolders(ctx context.Context) <-chan FolderUpdate
+
+	// GetThreadsFromFolder returns the threads of a folder, newest first
+	GetThreadsFromFolder(ctx context.Context, folderName string) ([]*Thread, error)
+
+	// GetThread retrieves a thread with all its messages
+	GetThread(ctx context.Context, threadID string) (*Thread, error)
+
+	// TagThread adds and removes tags on every message of a thread
+	TagThread(ctx context.Context, threadID string, add, remove []string) error
+
+	// MarkThreadRead marks all messages in a thread as read
+	MarkThreadRead(ctx context.Context, threadID string) error
+
+	// ArchiveThread archives a thread
+	ArchiveThread(ctx context.Context, threadID string) error
+
+	// DeleteThread deletes a thread
+	DeleteThread(ctx context.Context, threadID string) error
+
+	// StarThread stars or unstars a thread
+	StarThread(ctx context.Context, threadID string, starred bool) error
+
+	// GetUnreadCount returns the total number of unread messages
+	GetUnreadCount(ctx context.Context) (int, error)
+
+	// CountMessages returns the number of messages matching a query
+	CountMessages(ctx context.Context, query string) (int, error)
+
+	// CountThreads returns the number of threads matching a query
+	CountThreads(ctx context.Context, query string) (int, error)
+
+	// SearchEmails searches threads matching a query
+	SearchEmails(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error)
+
+	// StoreMessage saves a message file in a folder, creating the folder if
+	// needed. Stored messages are the user's own (drafts, sent mail), so they
+	// are marked read; tags such as "draft" are added.
+	StoreMessage(ctx context.Context, folderName string, message []byte, tags []string) error
+
+	// RemoveMessage deletes every file of a message, such as a draft that
+	// was replaced or sent
+	RemoveMessage(ctx context.Context, messageID string) error
+}
+
+// Ensure Manager implements Backend
+var _ Backend = (*Manager)(nil)
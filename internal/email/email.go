@@ -1,10 +1,10 @@
 package email
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
+	"net/mail"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -28,8 +28,11 @@ type Thread struct {
 	Timestamp     time.Time  `json:"timestamp"`
 	UnreadCount   int        `json:"unread_count"`
 	MessageCount  int        `json:"message_count"`
+	MatchedCount  int        `json:"matched_count"` // Messages matching the query that found the thread
+	Tags          []string   `json:"tags"`
 	LatestMessage *Message   `json:"latest_message"`
-	Messages      []*Message `json:"messages"`
+	Messages      []*Message `json:"messages"` // All messages in display (depth-first) order
+	Roots         []*Message `json:"-"`        // Top-level messages of the reply tree
 }
 
 // Message represents an individual email message
@@ -45,76 +48,275 @@ type Message struct {
 	Unread    bool      `json:"unread"`
 	Starred   bool      `json:"starred"`
 	Labels    []string  `json:"labels"`
+
+	// Full message details, filled when a thread is fetched
+	Headers     map[string]string `json:"headers,omitempty"`
+	Filenames   []string          `json:"filenames,omitempty"`
+	Parts       []*MessagePart    `json:"parts,omitempty"`
+	TextBody    string            `json:"text_body,omitempty"` // First text/plain part
+	HTMLBody    string            `json:"html_body,omitempty"` // First text/html part
+	Attachments []*Attachment     `json:"attachments,omitempty"`
+	Matched     bool              `json:"matched"`
+	Excluded    bool              `json:"excluded"` // Hidden by notmuch's search.exclude_tags
+
+	// Threading headers, without angle brackets
+	InReplyTo  string   `json:"in_reply_to,omitempty"`
+	References []string `json:"references,omitempty"`
+
+	// Reply tree
+	ParentID string     `json:"parent_id,omitempty"`
+	Depth    int        `json:"depth"`
+	Replies  []*Message `json:"-"`
+}
+
+// Summarize fills the thread-level fields from its messages
+func (t *Thread) Summarize() {
+	if len(t.Messages) == 0 {
+		return
+	}
+
+	t.Subject = t.Messages[0].Subject
+	t.MessageCount = len(t.Messages)
+	t.UnreadCount = 0
+	t.Participants = nil
+	t.LatestMessage = nil
+	t.Tags = nil
+
+	seen := make(map[string]bool)
+	seenTags := make(map[string]bool)
+	for _, msg := range t.Messages {
+		if msg.Unread {
+			t.UnreadCount++
+		}
+		if msg.From != "" && !seen[msg.From] {
+			seen[msg.From] = true
+			t.Participants = append(t.Participants, msg.From)
+		}
+		if t.LatestMessage == nil || !msg.Timestamp.Before(t.LatestMessage.Timestamp) {
+			t.LatestMessage = msg
+		}
+		for _, tag := range msg.Labels {
+			if !seenTags[tag] {
+				seenTags[tag] = true
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+	}
+	sort.Strings(t.Tags)
+	t.Timestamp = t.LatestMessage.Timestamp
 }
 
 // SearchResult represents a search result
 type SearchResult struct {
 	Threads []*Thread `json:"threads"`
 	Query   string    `json:"query"`
-	Total   int       `json:"total"`
+	Total   int       `json:"total"` // Total matching threads, regardless of paging
+	Offset  int       `json:"offset"`
+	Limit   int       `json:"limit"`
+}
+
+// SearchOptions controls paging and ordering of search results
+type SearchOptions struct {
+	// Offset is the number of threads to skip
+	Offset int
+
+	// Limit is the maximum number of threads to return (0 for no limit)
+	Limit int
+
+	// OldestFirst sorts results oldest-first instead of newest-first
+	OldestFirst bool
+}
+
+// HasMore reports whether more results exist past the current page
+func (r *SearchResult) HasMore() bool {
+	return r.Offset+len(r.Threads) < r.Total
 }
 
 // Manager handles email operations and external tool integration
 type Manager struct {
 	maildirPath string
 	notmuchPath string
-	mbsyncPath  string
 	msmtpPath   string
+	runner      *Runner
+	sender      *Sender
+
+	// Path of the maildir inside the notmuch database, for accounts kept
+	// in a subdirectory of the indexed tree
+	folderPrefix string
+
+	// Fetches new mail and indexes it
+	syncer *Syncer
+
+	// Folder directories from the last folder listing
+	folders *FolderDirs
+
+	// Folder whose removed drafts are hidden until the index is updated
+	draftsFolder string
 }
 
 // NewManager creates a new email manager
 func NewManager(maildirPath, notmuchPath, mbsyncPath, msmtpPath string) *Manager {
-	return &Manager{
+	m := &Manager{
 		maildirPath: maildirPath,
 		notmuchPath: notmuchPath,
-		mbsyncPath:  mbsyncPath,
 		msmtpPath:   msmtpPath,
+		runner:      NewRunner(DefaultTimeouts()),
+		sender:      NewSender(msmtpPath, ""),
+		folders:     NewFolderDirs(maildirPath),
 	}
+	m.syncer = NewSyncer(MbsyncDriver{Path: mbsyncPath}, m.IndexNew)
+	return m
 }
 
-// SyncEmails synchronizes emails using mbsync
-func (m *Manager) SyncEmails() error {
-	cmd := exec.Command(m.mbsyncPath, "-a")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to sync emails: %w", err)
+// SetDatabasePath sets the root of the notmuch database when the maildir
+// is only part of it, as with one maildir per account under a common root
+func (m *Manager) SetDatabasePath(databasePath string) error {
+	rel, err := filepath.Rel(databasePath, m.maildirPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("maildir %s is not inside the notmuch database %s", m.maildirPath, databasePath)
+	}
+	m.folderPrefix = relativeFolderDir(databasePath, m.maildirPath)
+	return nil
+}
+
+// SetDraftsFolder sets the folder drafts are saved in. Copies removed when
+// a draft is replaced stay indexed, tagged deleted, until the next sync, and
+// are left out of its listing meanwhile.
+func (m *Manager) SetDraftsFolder(folderName string) {
+	m.draftsFolder = folderName
+}
+
+// listingQuery builds the query listing the messages of a folder
+func (m *Manager) listingQuery(folderName, folderDir string) string {
+	query := folderQuery(folderDir)
+	if folderName == m.draftsFolder {
+		query += " and not tag:deleted"
+	}
+	return query
+}
+
+// SetSyncDriver sets the tool fetching new mail, mbsync -a by default
+func (m *Manager) SetSyncDriver(driver SyncDriver) {
+	m.syncer = NewSyncer(driver, m.IndexNew)
+}
+
+// Syncer returns the syncer fetching new mail for the maildir
+func (m *Manager) Syncer() *Syncer {
+	return m.syncer
+}
+
+// notmuch runs a notmuch subcommand and returns its output
+func (m *Manager) notmuch(ctx context.Context, timeout time.Duration, args ...string) ([]byte, error) {
+	output, err := m.runner.Run(ctx, Command{Path: m.notmuchPath, Args: args, Timeout: timeout})
+	return output, classifyNotmuchError(err)
+}
+
+// UserIdentity returns the sender address and the other addresses of the
+// user from the notmuch configuration (user.name, user.primary_email and
+// user.other_email)
+func (m *Manager) UserIdentity(ctx context.Context) (string, []string, error) {
+	get := func(key string) (string, error) {
+		output, err := m.notmuch(ctx, m.runner.Timeouts.Query, "config", "get", key)
+		return strings.TrimSpace(string(output)), err
+	}
+
+	primary, err := get("user.primary_email")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read notmuch user settings: %w", err)
+	}
+	if primary == "" {
+		return "", nil, nil
+	}
+
+	from := primary
+	if name, _ := get("user.name"); name != "" {
+		from = FormatAddress(&mail.Address{Name: name, Address: primary})
+	}
+
+	var aliases []string
+	others, _ := get("user.other_email")
+	for _, other := range strings.FieldsFunc(others, func(r rune) bool { return r == ';' || r == '\n' }) {
+		if other = strings.TrimSpace(other); other != "" {
+			aliases = append(aliases, other)
+		}
+	}
+	return from, aliases, nil
+}
+
+// SyncEmails synchronizes emails with the sync driver and indexes the new mail
+func (m *Manager) SyncEmails(ctx context.Context) error {
+	return m.syncer.Sync(ctx, "", nil)
+}
+
+// IndexNew adds new and moved message files to the notmuch database
+func (m *Manager) IndexNew(ctx context.Context) error {
+	if _, err := m.notmuch(ctx, m.runner.Timeouts.Sync, "new", "--quiet"); err != nil {
+		return fmt.Errorf("failed to update notmuch index: %w", err)
 	}
 	return nil
 }
 
 // SearchEmails searches emails using notmuch
-func (m *Manager) SearchEmails(query string) (*SearchResult, error) {
+func (m *Manager) SearchEmails(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error) {
+	args := []string{"search", "--format=json", "--output=summary"}
+	if opts.OldestFirst {
+		args = append(args, "--sort=oldest-first")
+	} else {
+		args = append(args, "--sort=newest-first")
+	}
+	if opts.Offset > 0 {
+		args = append(args, fmt.Sprintf("--offset=%d", opts.Offset))
+	}
+	if opts.Limit > 0 {
+		args = append(args, fmt.Sprintf("--limit=%d", opts.Limit))
+	}
+	args = append(args, query)
+
 	// Use notmuch search with JSON output
-	cmd := exec.Command(m.notmuchPath, "search", "--format=json", query)
-	output, err := cmd.Output()
+	output, err := m.notmuch(ctx, m.runner.Timeouts.Query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search emails: %w", err)
 	}
 
 	// Parse notmuch JSON output and convert to our models
-	// This is a simplified implementation - would need proper JSON parsing
-	threads, err := m.parseNotmuchResults(output)
+	threads, err := m.parseNotmuchSearchResults(output)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse search results: %w", err)
 	}
 
+	// The page may be partial, so ask notmuch for the full thread count
+	total := opts.Offset + len(threads)
+	if opts.Offset > 0 || (opts.Limit > 0 && len(threads) >= opts.Limit) {
+		total, err = m.CountThreads(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &SearchResult{
 		Threads: threads,
 		Query:   query,
-		Total:   len(threads),
+		Total:   total,
+		Offset:  opts.Offset,
+		Limit:   opts.Limit,
 	}, nil
 }
 
 // GetThread retrieves a specific thread with all messages
-func (m *Manager) GetThread(threadID string) (*Thread, error) {
-	// Use notmuch show to get thread details
-	cmd := exec.Command(m.notmuchPath, "show", "--format=json", threadID)
-	output, err := cmd.Output()
+func (m *Manager) GetThread(ctx context.Context, threadID string) (*Thread, error) {
+	query := threadID
+	if !strings.HasPrefix(query, "thread:") {
+		query = "thread:" + query
+	}
+
+	// Use notmuch show to get the full thread, including HTML parts
+	output, err := m.notmuch(ctx, m.runner.Timeouts.Query, "show", "--format=json", "--entire-thread=true", "--include-html", query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get thread: %w", err)
 	}
 
 	// Parse notmuch output and convert to Thread model
-	thread, err := m.parseNotmuchThread(output)
+	thread, err := m.parseNotmuchThread(threadID, output)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse thread: %w", err)
 	}
@@ -123,12 +325,11 @@ func (m *Manager) GetThread(threadID string) (*Thread, error) {
 }
 
 // GetThreadsFromFolder gets threads from a specific folder
-func (m *Manager) GetThreadsFromFolder(folderName string) ([]*Thread, error) {
-	// Use notmuch search to get threads from the folder
-	query := fmt.Sprintf("folder:%s", folderName)
-	cmd := exec.Command(m.notmuchPath, "search", "--format=json", "--sort=newest-first", query)
-
-	output, err := cmd.Output()
+func (m *Manager) GetThreadsFromFolder(ctx context.Context, folderName string) ([]*Thread, error) {
+	// Use notmuch search to get threads from the folder; folder: matches
+	// directories, which differ from folder names in Maildir++ layouts
+	query := m.listingQuery(folderName, m.folderDir(folderName))
+	output, err := m.notmuch(ctx, m.runner.Timeouts.Query, "search", "--format=json", "--sort=newest-first", query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search threads in folder %s: %w", folderName, err)
 	}
@@ -143,192 +344,97 @@ func (m *Manager) GetThreadsFromFolder(folderName string) ([]*Thread, error) {
 	return threads, nil
 }
 
+// TagThread adds and removes tags on every message of a thread
+func (m *Manager) TagThread(ctx context.Context, threadID string, add, remove []string) error {
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	args := []string{"tag"}
+	for _, tag := range add {
+		args = append(args, "+"+tag)
+	}
+	for _, tag := range remove {
+		args = append(args, "-"+tag)
+	}
+	args = append(args, "--", fmt.Sprintf("thread:%s", strings.TrimPrefix(threadID, "thread:")))
+
+	if _, err := m.notmuch(ctx, m.runner.Timeouts.Tag, args...); err != nil {
+		return fmt.Errorf("failed to tag thread: %w", err)
+	}
+	return nil
+}
+
 // MarkThreadRead marks all messages in a thread as read
-func (m *Manager) MarkThreadRead(threadID string) error {
-	cmd := exec.Command(m.notmuchPath, "tag", "-unread", fmt.Sprintf("thread:%s", threadID))
-	if err := cmd.Run(); err != nil {
+func (m *Manager) MarkThreadRead(ctx context.Context, threadID string) error {
+	if err := m.TagThread(ctx, threadID, nil, []string{"unread"}); err != nil {
 		return fmt.Errorf("failed to mark thread as read: %w", err)
 	}
 	return nil
 }
 
 // ArchiveThread archives a thread (moves to archive folder)
-func (m *Manager) ArchiveThread(threadID string) error {
-	cmd := exec.Command(m.notmuchPath, "tag", "+archive", fmt.Sprintf("thread:%s", threadID))
-	if err := cmd.Run(); err != nil {
+func (m *Manager) ArchiveThread(ctx context.Context, threadID string) error {
+	if err := m.TagThread(ctx, threadID, []string{"archive"}, nil); err != nil {
 		return fmt.Errorf("failed to archive thread: %w", err)
 	}
 	return nil
 }
 
 // DeleteThread deletes a thread
-func (m *Manager) DeleteThread(threadID string) error {
-	cmd := exec.Command(m.notmuchPath, "tag", "+deleted", fmt.Sprintf("thread:%s", threadID))
-	if err := cmd.Run(); err != nil {
+func (m *Manager) DeleteThread(ctx context.Context, threadID string) error {
+	if err := m.TagThread(ctx, threadID, []string{"deleted"}, nil); err != nil {
 		return fmt.Errorf("failed to delete thread: %w", err)
 	}
 	return nil
 }
 
 // StarThread stars/unstars a thread
-func (m *Manager) StarThread(threadID string, starred bool) error {
-	tag := "+starred"
-	if !starred {
-		tag = "-starred"
+func (m *Manager) StarThread(ctx context.Context, threadID string, starred bool) error {
+	var err error
+	if starred {
+		err = m.TagThread(ctx, threadID, []string{"starred"}, nil)
+	} else {
+		err = m.TagThread(ctx, threadID, nil, []string{"starred"})
 	}
-
-	cmd := exec.Command(m.notmuchPath, "tag", tag, fmt.Sprintf("thread:%s", threadID))
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		return fmt.Errorf("failed to star/unstar thread: %w", err)
 	}
 	return nil
 }
 
 // GetUnreadCount returns the total unread count
-func (m *Manager) GetUnreadCount() (int, error) {
-	cmd := exec.Command(m.notmuchPath, "count", "tag:unread")
-	output, err := cmd.Output()
+func (m *Manager) GetUnreadCount(ctx context.Context) (int, error) {
+	count, err := m.CountMessages(ctx, "tag:unread")
 	if err != nil {
 		return 0, fmt.Errorf("failed to get unread count: %w", err)
 	}
-
-	// Parse output to get count
-	count := 0
-	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d", &count); err != nil {
-		return 0, fmt.Errorf("failed to parse unread count: %w", err)
-	}
 	return count, nil
 }
 
-// GetMailFolders scans the mail directory and returns all available folders
-func (m *Manager) GetMailFolders() ([]*MailFolder, error) {
-	var folders []*MailFolder
-
-	// Check if mail directory exists
-	if _, err := os.Stat(m.maildirPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("mail directory does not exist: %s", m.maildirPath)
-	}
-
-	// Walk through the mail directory
-	err := filepath.Walk(m.maildirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			// Log error but continue scanning other directories
-			return nil
-		}
-
-		// Skip the root directory itself
-		if path == m.maildirPath {
-			return nil
-		}
-
-		// Only process directories
-		if !info.IsDir() {
-			return nil
-		}
-
-		// Get relative path from mail directory
-		relPath, err := filepath.Rel(m.maildirPath, path)
-		if err != nil {
-			// Skip this directory if we can't get relative path
-			return nil
-		}
-
-		// Skip hidden directories (starting with .)
-		if strings.HasPrefix(relPath, ".") {
-			return nil
-		}
-
-		// Skip Maildir storage folders (cur, new, tmp)
-		if m.isMaildirStorageFolder(relPath) {
-			return nil
-		}
-
-		// Check if this is a special folder
-		isSpecial := m.isSpecialFolder(relPath)
-
-		// Get unread and message counts using notmuch
-		unreadCount, messageCount := m.getFolderCounts(relPath)
-
-		folder := &MailFolder{
-			Name:         relPath,
-			Path:         path,
-			UnreadCount:  unreadCount,
-			MessageCount: messageCount,
-			IsSpecial:    isSpecial,
-		}
-
-		folders = append(folders, folder)
-		return nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan mail directory: %w", err)
-	}
-
-	// Sort folders: special folders first, then alphabetically
-	sort.Slice(folders, func(i, j int) bool {
-		if folders[i].IsSpecial && !folders[j].IsSpecial {
-			return true
-		}
-		if !folders[i].IsSpecial && folders[j].IsSpecial {
-			return false
-		}
-		return strings.ToLower(folders[i].Name) < strings.ToLower(folders[j].Name)
-	})
-
-	return folders, nil
-}
-
-// isSpecialFolder checks if a folder is a special system folder
-func (m *Manager) isSpecialFolder(folderName string) bool {
-	upperName := strings.ToUpper(folderName)
-	specialFolders := []string{"INBOX", "SENT", "DRAFTS", "TRASH", "SPAM", "ARCHIVE", "JUNK"}
-
-	for _, special := range specialFolders {
-		if upperName == special {
-			return true
-		}
-	}
-	return false
+// CountMessages returns the number of messages matching a query
+func (m *Manager) CountMessages(ctx context.Context, query string) (int, error) {
+	return m.count(ctx, "messages", query)
 }
 
-// isMaildirStorageFolder checks if a folder is a Maildir storage folder
-func (m *Manager) isMaildirStorageFolder(folderName string) bool {
-	// Maildir storage folders that should not be displayed
-	storageFolders := []string{"cur", "new", "tmp"}
-
-	for _, storage := range storageFolders {
-		if folderName == storage {
-			return true
-		}
-	}
-	return false
+// CountThreads returns the number of threads matching a query
+func (m *Manager) CountThreads(ctx context.Context, query string) (int, error) {
+	return m.count(ctx, "threads", query)
 }
 
-// getFolderCounts gets the unread and total message counts for a folder
-func (m *Manager) getFolderCounts(folderName string) (unread, total int) {
-	// Use notmuch to count messages in the folder
-	// Format: folder:folderName
-	query := fmt.Sprintf("folder:%s", folderName)
-
-	// Get total count
-	totalCmd := exec.Command(m.notmuchPath, "count", query)
-	if output, err := totalCmd.Output(); err == nil {
-		if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d", &total); err != nil {
-			total = 0
-		}
+// count runs notmuch count with the given output type
+func (m *Manager) count(ctx context.Context, outputType, query string) (int, error) {
+	output, err := m.notmuch(ctx, m.runner.Timeouts.Query, "count", "--output="+outputType, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", outputType, err)
 	}
 
-	// Get unread count
-	unreadQuery := fmt.Sprintf("%s and tag:unread", query)
-	unreadCmd := exec.Command(m.notmuchPath, "count", unreadQuery)
-	if output, err := unreadCmd.Output(); err == nil {
-		if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d", &unread); err != nil {
-			unread = 0
-		}
+	// Parse output to get count
+	count := 0
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d", &count); err != nil {
+		return 0, fmt.Errorf("failed to parse %s count: %w", outputType, err)
 	}
-
-	return unread, total
+	return count, nil
 }
 
 // NotmuchSearchResult represents a single search result from notmuch
@@ -381,6 +487,8 @@ func (m *Manager) parseNotmuchSearchResults(output []byte) ([]*Thread, error) {
 			Timestamp:    timestamp,
 			UnreadCount:  boolToInt(isUnread),
 			MessageCount: result.Total,
+			MatchedCount: result.Matched,
+			Tags:         result.Tags,
 		}
 
 		threads = append(threads, thread)
@@ -395,19 +503,3 @@ func boolToInt(b bool) int {
 	}
 	return 0
 }
-
-// parseNotmuchResults parses notmuch search results
-// This is a placeholder - would need proper JSON parsing of notmuch output
-func (m *Manager) parseNotmuchResults(output []byte) ([]*Thread, error) {
-	// TODO: Implement proper parsing of notmuch JSON output
-	// For now, return empty result
-	return []*Thread{}, nil
-}
-
-// parseNotmuchThread parses notmuch thread output
-// This is a placeholder - would need proper JSON parsing of notmuch output
-func (m *Manager) parseNotmuchThread(output []byte) (*Thread, error) {
-	// TODO: Implement proper parsing of notmuch thread output
-	// For now, return empty thread
-	return &Thread{}, nil
-}
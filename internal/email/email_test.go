@@ -0,0 +1,78 @@
+package email
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseNotmuchSearchResults(t *testing.T) {
+	m := NewManager("", "notmuch", "mbsync", "msmtp")
+
+	output := []byte(`[{"thread": "0000000000000002", "timestamp": 1700000000, "date_relative": "Nov 14",
+	  "matched": 2, "total": 5, "authors": "Alice| Bob", "subject": "Release planning",
+	  "query": ["id:a@example.com", null], "tags": ["inbox", "unread"]}]`)
+
+	threads, err := m.parseNotmuchSearchResults(output)
+	if err != nil {
+		t.Fatalf("parseNotmuchSearchResults() failed: %v", err)
+	}
+
+	if len(threads) != 1 {
+		t.Fatalf("Expected 1 thread, got %d", len(threads))
+	}
+
+	thread := threads[0]
+	if thread.MatchedCount != 2 || thread.MessageCount != 5 {
+		t.Errorf("Expected 2 of 5 messages matched, got %d of %d", thread.MatchedCount, thread.MessageCount)
+	}
+	if thread.UnreadCount != 1 {
+		t.Errorf("Expected thread to be unread, got %d", thread.UnreadCount)
+	}
+	if len(thread.Tags) != 2 {
+		t.Errorf("Expected 2 tags, got %v", thread.Tags)
+	}
+}
+
+func TestSearchResultHasMore(t *testing.T) {
+	result := &SearchResult{Threads: make([]*Thread, 10), Total: 25, Offset: 10, Limit: 10}
+	if !result.HasMore() {
+		t.Error("Expected more results after the second page")
+	}
+
+	result.Offset = 15
+	if result.HasMore() {
+		t.Error("Expected no more results on the last page")
+	}
+}
+
+func TestUserIdentity(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake notmuch needs a POSIX shell")
+	}
+
+	notmuch := filepath.Join(t.TempDir(), "notmuch")
+	script := `#!/bin/sh
+case "$3" in
+user.name) echo "Zoë Martin" ;;
+user.primary_email) echo "zoe@example.com" ;;
+user.other_email) echo "zoe@work.example;zm@example.org" ;;
+esac
+`
+	if err := os.WriteFile(notmuch, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write fake notmuch: %v", err)
+	}
+
+	from, aliases, err := NewManager(t.TempDir(), notmuch, "mbsync", "msmtp").UserIdentity(context.Background())
+	if err != nil {
+		t.Fatalf("UserIdentity failed: %v", err)
+	}
+	if from != "Zoë Martin <zoe@example.com>" {
+		t.Errorf("Expected From Zoë Martin <zoe@example.com>, got %q", from)
+	}
+	if len(aliases) != 2 || aliases[0] != "zoe@work.example" || aliases[1] != "zm@example.org" {
+		t.Errorf("Expected two aliases, got %v", aliases)
+	}
+}
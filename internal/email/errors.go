@@ -0,0 +1,102 @@
+package email
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errors reported by backends for the failures users can fix themselves.
+// Use errors.Is to test for them; Hint explains how to fix each one.
+var (
+	// ErrToolNotFound means an external tool binary could not be found
+	ErrToolNotFound = errors.New("external tool not found")
+
+	// ErrMaildirMissing means the configured mail directory does not exist
+	ErrMaildirMissing = errors.New("mail directory does not exist")
+
+	// ErrDatabaseMissing means notmuch has no database or configuration yet
+	ErrDatabaseMissing = errors.New("notmuch database not found")
+
+	// ErrDatabaseLocked means another process holds the notmuch write lock
+	ErrDatabaseLocked = errors.New("notmuch database is locked")
+
+	// ErrQuerySyntax means notmuch could not parse a search query
+	ErrQuerySyntax = errors.New("invalid search query")
+
+	// ErrOffline means a sync could not reach the mail server because the
+	// network is down
+	ErrOffline = errors.New("network unreachable")
+)
+
+// classifyNotmuchError recognises notmuch failures from their stderr output
+// and records the matching error kind on the ToolError
+func classifyNotmuchError(err error) error {
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || toolErr.Kind != nil {
+		return err
+	}
+
+	stderr := strings.ToLower(toolErr.Stderr)
+	switch {
+	case strings.Contains(stderr, "already locked") || strings.Contains(stderr, "write lock"):
+		toolErr.Kind = ErrDatabaseLocked
+	case strings.Contains(stderr, "parsing query") || strings.Contains(stderr, "syntax") ||
+		strings.Contains(stderr, "unknown prefix"):
+		toolErr.Kind = ErrQuerySyntax
+	case strings.Contains(stderr, "cannot load config") ||
+		strings.Contains(stderr, "database") && (strings.Contains(stderr, "no such file") ||
+			strings.Contains(stderr, "not found") || strings.Contains(stderr, "could not open") ||
+			strings.Contains(stderr, "cannot open") || strings.Contains(stderr, "does not exist")):
+		toolErr.Kind = ErrDatabaseMissing
+	}
+	return err
+}
+
+// classifySyncError recognises network failures of a sync tool from its
+// stderr output and records them as ErrOffline on the ToolError
+func classifySyncError(err error) error {
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || toolErr.Kind != nil {
+		return err
+	}
+
+	stderr := strings.ToLower(toolErr.Stderr)
+	for _, symptom := range []string{
+		"cannot resolve", "could not resolve", "name resolution", "name or service not known",
+		"nodename nor servname", "network is unreachable", "no route to host", "connection timed out",
+	} {
+		if strings.Contains(stderr, symptom) {
+			toolErr.Kind = ErrOffline
+			break
+		}
+	}
+	return err
+}
+
+// Hint returns an actionable fix for a recognised error, or "" if there is none
+func Hint(err error) string {
+	var toolErr *ToolError
+	isToolErr := errors.As(err, &toolErr)
+
+	switch {
+	case errors.Is(err, ErrToolNotFound):
+		tool := "the tool"
+		if isToolErr {
+			tool = toolErr.Tool
+		}
+		return "install " + tool + " or set its path under external_tools in the mel config"
+	case errors.Is(err, ErrMaildirMissing):
+		return "create the directory, run your sync tool, or set email.maildir in the mel config"
+	case errors.Is(err, ErrDatabaseMissing):
+		return "run `notmuch setup` and then `notmuch new` to index your mail"
+	case errors.Is(err, ErrDatabaseLocked):
+		return "another notmuch process is writing (usually `notmuch new`); try again in a moment"
+	case errors.Is(err, ErrQuerySyntax):
+		return "check the search query, and quote phrases such as subject:\"weekly report\""
+	case errors.Is(err, ErrOffline):
+		return "check your network connection; mail is synced again once it is back"
+	case isToolErr && toolErr.TimedOut():
+		return toolErr.Tool + " took too long; check it runs from a shell, then retry"
+	}
+	return ""
+}
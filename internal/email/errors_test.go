@@ -0,0 +1,55 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNotmuchErrorClassification(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   error
+	}{
+		{"locked", "A Xapian exception occurred opening database: Unable to get write lock on /m/.notmuch/xapian: already locked", ErrDatabaseLocked},
+		{"syntax", "notmuch search: A Xapian exception occurred parsing query: Syntax: <expression> AND <expression>", ErrQuerySyntax},
+		{"missing", "Error: Cannot open database at /home/u/Mail/.notmuch: No such file or directory.", ErrDatabaseMissing},
+		{"no config", "Error: cannot load config file.", ErrDatabaseMissing},
+	}
+
+	for _, tt := range tests {
+		notmuch, _ := fakeNotmuch(t)
+		script := "#!/bin/sh\necho '" + tt.stderr + "' >&2\nexit 1\n"
+		if err := os.WriteFile(notmuch, []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		manager := NewManager(t.TempDir(), notmuch, "mbsync", "msmtp")
+		_, err := manager.CountMessages(context.Background(), "*")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+		if Hint(err) == "" {
+			t.Errorf("%s: expected a hint", tt.name)
+		}
+	}
+}
+
+func TestToolNotFound(t *testing.T) {
+	manager := NewManager(t.TempDir(), filepath.Join(t.TempDir(), "notmuch"), "mbsync", "msmtp")
+
+	_, err := manager.SearchEmails(context.Background(), "tag:inbox", SearchOptions{})
+	if !errors.Is(err, ErrToolNotFound) {
+		t.Fatalf("Expected ErrToolNotFound, got %v", err)
+	}
+	if hint := Hint(err); hint != "install notmuch or set its path under external_tools in the mel config" {
+		t.Errorf("Unexpected hint %q", hint)
+	}
+
+	if _, err := ScanFolders(filepath.Join(t.TempDir(), "Mail")); !errors.Is(err, ErrMaildirMissing) {
+		t.Errorf("Expected ErrMaildirMissing, got %v", err)
+	}
+}
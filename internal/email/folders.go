@@ -0,0 +1,225 @@
+package email
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FolderUpdate is one step of a progressive folder listing. The first
+// update carries the folder list without counts so it can be displayed
+// right away; later updates carry the counts of one folder each.
+type FolderUpdate struct {
+	Folders []*MailFolder // Complete folder list, only set on the listing update
+	Counts  *FolderCounts // Counts of a single folder
+	Err     error         // Error from the walk or from counting
+}
+
+// FolderCounts holds the unread and total message counts of a folder
+type FolderCounts struct {
+	Name   string
+	Unread int
+	Total  int
+}
+
+// CollectFolders drains a progressive listing and applies the counts to the
+// folders. Folders are returned even if counting failed, along with the error.
+func CollectFolders(updates <-chan FolderUpdate) ([]*MailFolder, error) {
+	var folders []*MailFolder
+	var firstErr error
+	byName := make(map[string]*MailFolder)
+
+	for update := range updates {
+		if update.Err != nil && firstErr == nil {
+			firstErr = update.Err
+		}
+		if update.Folders != nil {
+			folders = update.Folders
+			for _, folder := range folders {
+				byName[folder.Name] = folder
+			}
+		}
+		if update.Counts != nil {
+			update.Counts.ApplyTo(byName[update.Counts.Name])
+		}
+	}
+
+	return folders, firstErr
+}
+
+// ApplyTo copies the counts to a folder; a nil folder is ignored
+func (c *FolderCounts) ApplyTo(folder *MailFolder) {
+	if folder == nil {
+		return
+	}
+	folder.UnreadCount = c.Unread
+	folder.MessageCount = c.Total
+}
+
+// ScanFolders walks a mail directory and returns its folders without counts.
+// Backends fill in the unread and message counts from their own index.
+func ScanFolders(maildirPath string) ([]*MailFolder, error) {
+	folders := []*MailFolder{}
+	err := WalkFolders(maildirPath, func(folder *MailFolder) {
+		folders = append(folders, folder)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	SortFolders(folders)
+	return folders, nil
+}
+
+// WalkFolders walks a mail directory and calls visit for every folder as soon
+// as it is found, so callers can start work before the walk completes.
+// A directory is a folder when it holds the cur/new/tmp triple; message
+// storage is never descended into, so the cost depends on the number of
+// folders rather than the number of messages.
+//
+// Folder names use "/" as the hierarchy separator whatever the layout:
+// a maildir at the root is INBOX, dot folders at the root are Maildir++
+// folders (.Lists.golang is Lists/golang) and dot folders below the root
+// are mbsync Legacy subfolders (Lists/.golang is Lists/golang).
+func WalkFolders(maildirPath string, visit func(*MailFolder)) error {
+	// Check if mail directory exists
+	if _, err := os.Stat(maildirPath); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrMaildirMissing, maildirPath)
+	}
+
+	if err := walkFolderTree(maildirPath, "", "", false, visit); err != nil {
+		return fmt.Errorf("failed to scan mail directory: %w", err)
+	}
+	return nil
+}
+
+// walkFolderTree lists one directory level and recurses into the
+// subdirectories that are not message storage. Hidden directories are
+// only considered when they are maildirs themselves.
+func walkFolderTree(root, relPath, name string, hidden bool, visit func(*MailFolder)) error {
+	dir := filepath.Join(root, relPath)
+	subdirs, err := listSubdirs(dir)
+	if err != nil {
+		if relPath == "" {
+			return err
+		}
+		// Skip unreadable directories but keep scanning the others
+		return nil
+	}
+
+	isMaildir := hasMaildirStorage(subdirs)
+	if hidden && !isMaildir {
+		// .notmuch, .git and friends
+		return nil
+	}
+	if isMaildir {
+		folderName := name
+		if relPath == "" {
+			// Maildir++ keeps the inbox at the root
+			folderName = "INBOX"
+		}
+		visit(&MailFolder{
+			Name:      folderName,
+			Path:      dir,
+			IsSpecial: IsSpecialFolder(folderName),
+		})
+	}
+
+	for _, sub := range subdirs {
+		// Never descend into message storage
+		if isMaildir && isMaildirStorageFolder(sub) {
+			continue
+		}
+
+		childName := path.Join(name, sub)
+		childHidden := strings.HasPrefix(sub, ".")
+		if childHidden {
+			trimmed := strings.TrimPrefix(sub, ".")
+			if trimmed == "" {
+				continue
+			}
+			if relPath == "" {
+				// Maildir++: the hierarchy is flattened with dots
+				childName = strings.ReplaceAll(trimmed, ".", "/")
+			} else {
+				// mbsync Legacy: subfolders are dot directories in their parent
+				childName = path.Join(name, trimmed)
+			}
+		}
+
+		if err := walkFolderTree(root, filepath.Join(relPath, sub), childName, childHidden, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listSubdirs returns the names of the subdirectories of dir. ReadDir does
+// not stat entries, so this stays cheap even next to many message files.
+func listSubdirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var subdirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			subdirs = append(subdirs, entry.Name())
+		}
+	}
+	return subdirs, nil
+}
+
+// hasMaildirStorage reports whether a directory listing contains cur, new and tmp
+func hasMaildirStorage(subdirs []string) bool {
+	found := 0
+	for _, name := range subdirs {
+		if isMaildirStorageFolder(name) {
+			found++
+		}
+	}
+	return found == 3
+}
+
+// SortFolders sorts folders: special folders first, then alphabetically
+func SortFolders(folders []*MailFolder) {
+	sort.Slice(folders, func(i, j int) bool {
+		if folders[i].IsSpecial && !folders[j].IsSpecial {
+			return true
+		}
+		if !folders[i].IsSpecial && folders[j].IsSpecial {
+			return false
+		}
+		return strings.ToLower(folders[i].Name) < strings.ToLower(folders[j].Name)
+	})
+}
+
+// IsSpecialFolder checks if a folder is a special system folder
+func IsSpecialFolder(folderName string) bool {
+	upperName := strings.ToUpper(folderName)
+	specialFolders := []string{"INBOX", "SENT", "DRAFTS", "TRASH", "SPAM", "ARCHIVE", "JUNK"}
+
+	for _, special := range specialFolders {
+		if upperName == special {
+			return true
+		}
+	}
+	return false
+}
+
+// isMaildirStorageFolder checks if a folder is a Maildir storage folder
+func isMaildirStorageFolder(folderName string) bool {
+	// Maildir storage folders that should not be displayed
+	storageFolders := []string{"cur", "new", "tmp"}
+
+	for _, storage := range storageFolders {
+		if folderName == storage {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package email
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeMaildirs creates empty maildir folders under root
+func makeMaildirs(tb testing.TB, root string, names ...string) {
+	tb.Helper()
+	for _, name := range names {
+		for _, sub := range []string{"cur", "new", "tmp"} {
+			if err := os.MkdirAll(filepath.Join(root, name, sub), 0o755); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestScanFolders(t *testing.T) {
+	root := t.TempDir()
+	makeMaildirs(t, root, "INBOX", "Work", "Work/Projects", "Lists/golang", ".notmuch/xapian")
+
+	// Directories inside message storage must never be reported
+	if err := os.MkdirAll(filepath.Join(root, "INBOX", "cur", "stray", "cur"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A directory missing tmp/ is not a maildir
+	if err := os.MkdirAll(filepath.Join(root, "Partial", "cur"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	folders, err := ScanFolders(root)
+	if err != nil {
+		t.Fatalf("ScanFolders() failed: %v", err)
+	}
+
+	var names []string
+	for _, folder := range folders {
+		names = append(names, folder.Name)
+	}
+	want := []string{"INBOX", "Lists/golang", "Work", "Work/Projects"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("Expected folders %v, got %v", want, names)
+	}
+	if !folders[0].IsSpecial || folders[0].Path != filepath.Join(root, "INBOX") {
+		t.Errorf("Unexpected INBOX folder %+v", folders[0])
+	}
+}
+
+func TestScanFoldersLayouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		dirs   []string
+		layout Layout
+		want   []string
+	}{
+		{
+			name:   "maildir++",
+			dirs:   []string{"", ".Sent", ".Lists.golang"},
+			layout: LayoutMaildirPlusPlus,
+			want:   []string{"INBOX", "Sent", "Lists/golang"},
+		},
+		{
+			name:   "mbsync legacy",
+			dirs:   []string{"INBOX", "Lists", "Lists/.golang", "Lists/.golang/.nuts"},
+			layout: LayoutLegacy,
+			want:   []string{"INBOX", "Lists", "Lists/golang", "Lists/golang/nuts"},
+		},
+		{
+			name:   "mbsync verbatim",
+			dirs:   []string{"INBOX", "Lists/golang"},
+			layout: LayoutFilesystem,
+			want:   []string{"INBOX", "Lists/golang"},
+		},
+	}
+
+	for _, tt := range tests {
+		root := t.TempDir()
+		makeMaildirs(t, root, tt.dirs...)
+		// Not a maildir, so it must be neither listed nor mistaken for Maildir++
+		if err := os.MkdirAll(filepath.Join(root, ".notmuch", "xapian"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		folders, err := ScanFolders(root)
+		if err != nil {
+			t.Fatalf("%s: ScanFolders() failed: %v", tt.name, err)
+		}
+
+		byName := make(map[string]*MailFolder)
+		for _, folder := range folders {
+			byName[folder.Name] = folder
+		}
+		if len(folders) != len(tt.want) {
+			t.Errorf("%s: expected %d folders, got %d", tt.name, len(tt.want), len(folders))
+		}
+
+		layout := DetectLayout(root)
+		if layout != tt.layout {
+			t.Errorf("%s: detected layout %s", tt.name, layout)
+		}
+		for _, name := range tt.want {
+			folder, ok := byName[name]
+			if !ok {
+				t.Errorf("%s: missing folder %s", tt.name, name)
+				continue
+			}
+			// The layout maps names back to the directories that were found
+			if got := filepath.Join(root, layout.FolderDir(name)); got != folder.Path {
+				t.Errorf("%s: FolderDir(%s) = %s, want %s", tt.name, name, got, folder.Path)
+			}
+		}
+	}
+}
+
+func TestFolderDirs(t *testing.T) {
+	root := t.TempDir()
+	makeMaildirs(t, root, "INBOX", "Lists/.golang")
+	dirs := NewFolderDirs(root)
+
+	if dir := dirs.Resolve("Lists/golang"); dir != "Lists/.golang" {
+		t.Errorf("Resolve(Lists/golang) = %q, want Lists/.golang", dir)
+	}
+
+	// A folder missing from the last listing, or gone from disk, rescans
+	makeMaildirs(t, root, "Work")
+	if dir := dirs.Resolve("Work"); dir != "Work" {
+		t.Errorf("Resolve(Work) = %q, want Work", dir)
+	}
+	if err := os.Rename(filepath.Join(root, "Lists", ".golang"), filepath.Join(root, "Lists", "golang")); err != nil {
+		t.Fatal(err)
+	}
+	if dir := dirs.Resolve("Lists/golang"); dir != "Lists/golang" {
+		t.Errorf("Expected the renamed directory, got %q", dir)
+	}
+}
+
+func TestScanFoldersMissingDirectory(t *testing.T) {
+	if _, err := ScanFolders(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing mail directory")
+	}
+}
+
+// generateMaildir builds a mailbox with the given number of folders, each
+// holding the given number of empty message files split between cur and new
+func generateMaildir(b *testing.B, folders, messages int) string {
+	b.Helper()
+
+	root := b.TempDir()
+	for i := 0; i < folders; i++ {
+		name := fmt.Sprintf("Folder%03d", i)
+		if i%10 == 0 {
+			// Nest some folders to exercise the hierarchy
+			name = filepath.Join("Archive", name)
+		}
+		makeMaildirs(b, root, name)
+
+		for j := 0; j < messages; j++ {
+			sub := "cur"
+			if j%20 == 0 {
+				sub = "new"
+			}
+			path := filepath.Join(root, name, sub, fmt.Sprintf("%d.%d.bench:2,S", i, j))
+			if err := os.WriteFile(path, nil, 0o600); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func BenchmarkScanFolders(b *testing.B) {
+	sizes := []struct{ folders, messages int }{
+		{30, 100},
+		{300, 1000},
+	}
+
+	for _, size := range sizes {
+		root := generateMaildir(b, size.folders, size.messages)
+		name := fmt.Sprintf("folders=%d/messages=%d", size.folders, size.folders*size.messages)
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				folders, err := ScanFolders(root)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(folders) != size.folders {
+					b.Fatalf("Expected %d folders, got %d", size.folders, len(folders))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package email
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Layout describes how folder names map to directories in a mail directory
+type Layout int
+
+const (
+	// LayoutFilesystem nests folders as plain directories: Lists/golang
+	// (mbsync SubFolders Verbatim)
+	LayoutFilesystem Layout = iota
+
+	// LayoutMaildirPlusPlus keeps INBOX at the root and flattens other
+	// folders with dots: .Lists.golang (Dovecot, offlineimap, mbsync
+	// SubFolders Maildir++)
+	LayoutMaildirPlusPlus
+
+	// LayoutLegacy stores subfolders as dot directories inside their
+	// parent: Lists/.golang (mbsync SubFolders Legacy)
+	LayoutLegacy
+)
+
+// String returns the name of the layout
+func (l Layout) String() string {
+	switch l {
+	case LayoutMaildirPlusPlus:
+		return "maildir++"
+	case LayoutLegacy:
+		return "legacy"
+	default:
+		return "filesystem"
+	}
+}
+
+// DetectLayout guesses the layout of a mail directory from its top two levels
+func DetectLayout(maildirPath string) Layout {
+	subdirs, err := listSubdirs(maildirPath)
+	if err != nil {
+		return LayoutFilesystem
+	}
+
+	// An inbox at the root or a dot maildir next to it means Maildir++
+	if hasMaildirStorage(subdirs) || hasDotMaildir(maildirPath, subdirs) {
+		return LayoutMaildirPlusPlus
+	}
+
+	// Dot maildirs one level down mean mbsync Legacy subfolders
+	for _, name := range subdirs {
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		dir := filepath.Join(maildirPath, name)
+		children, err := listSubdirs(dir)
+		if err != nil {
+			continue
+		}
+		if hasDotMaildir(dir, children) {
+			return LayoutLegacy
+		}
+	}
+
+	return LayoutFilesystem
+}
+
+// FolderDir returns the directory of a folder relative to the mail directory.
+// This is also the value notmuch expects in folder: queries.
+func (l Layout) FolderDir(folderName string) string {
+	parts := strings.Split(strings.Trim(folderName, "/"), "/")
+
+	switch l {
+	case LayoutMaildirPlusPlus:
+		if strings.EqualFold(folderName, "INBOX") {
+			return ""
+		}
+		return "." + strings.Join(parts, ".")
+	case LayoutLegacy:
+		for i := 1; i < len(parts); i++ {
+			parts[i] = "." + parts[i]
+		}
+		return path.Join(parts...)
+	default:
+		return path.Join(parts...)
+	}
+}
+
+// hasDotMaildir reports whether any hidden subdirectory of dir is a maildir
+func hasDotMaildir(dir string, subdirs []string) bool {
+	for _, name := range subdirs {
+		if !strings.HasPrefix(name, ".") {
+			continue
+		}
+		children, err := listSubdirs(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if hasMaildirStorage(children) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveFolderDir resolves a folder name to its directory relative to the
+// mail directory, falling back to the detected layout for folders not on
+// disk yet
+func ResolveFolderDir(maildirPath, folderName string) string {
+	if folders, err := ScanFolders(maildirPath); err == nil {
+		for _, folder := range folders {
+			if folder.Name == folderName {
+				return relativeFolderDir(maildirPath, folder.Path)
+			}
+		}
+	}
+	return DetectLayout(maildirPath).FolderDir(folderName)
+}
+
+// FolderDirs resolves the folder names of a mail directory like
+// ResolveFolderDir, remembering the folders of the last listing so the tree
+// is only rescanned for a folder that is missing from it or gone from disk
+type FolderDirs struct {
+	root string
+
+	mu   sync.Mutex
+	dirs map[string]string // Directories relative to root by folder name
+}
+
+// NewFolderDirs creates a folder resolver for a mail directory
+func NewFolderDirs(maildirPath string) *FolderDirs {
+	return &FolderDirs{root: maildirPath}
+}
+
+// Remember replaces the known folders with those of a folder listing
+func (f *FolderDirs) Remember(folders []*MailFolder) {
+	dirs := make(map[string]string, len(folders))
+	for _, folder := range folders {
+		dirs[folder.Name] = relativeFolderDir(f.root, folder.Path)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.dirs = dirs
+}
+
+// Resolve resolves a folder name to its directory relative to the mail
+// directory
+func (f *FolderDirs) Resolve(folderName string) string {
+	f.mu.Lock()
+	dir, ok := f.dirs[folderName]
+	f.mu.Unlock()
+	if ok {
+		if _, err := os.Stat(filepath.Join(f.root, filepath.FromSlash(dir))); err == nil {
+			return dir
+		}
+	}
+
+	folders, err := ScanFolders(f.root)
+	if err != nil {
+		return DetectLayout(f.root).FolderDir(folderName)
+	}
+	f.Remember(folders)
+	f.mu.Lock()
+	dir, ok = f.dirs[folderName]
+	f.mu.Unlock()
+	if ok {
+		return dir
+	}
+	return DetectLayout(f.root).FolderDir(folderName)
+}
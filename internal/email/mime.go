@@ -0,0 +1,410 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// maxPartDepth limits MIME nesting to protect against malicious messages
+const maxPartDepth = 32
+
+// Attachment describes a part of a message that is not rendered as text
+type Attachment struct {
+	PartID      int    `json:"part_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	ContentID   string `json:"content_id,omitempty"`
+	Size        int    `json:"size"`
+	Inline      bool   `json:"inline"`
+	Data        []byte `json:"-"` // Decoded content, empty when the backend does not provide it
+}
+
+// headerDecoder decodes RFC 2047 encoded-words in any supported charset
+var headerDecoder = &mime.WordDecoder{CharsetReader: CharsetReader}
+
+// CharsetReader returns a reader converting from the given charset to UTF-8.
+// It supports the WHATWG encodings, including ISO-8859-x, windows-125x,
+// Shift_JIS, EUC-JP, ISO-2022-JP, GB2312/GBK, Big5 and KOI8.
+func CharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return input, nil
+	}
+	return transform.NewReader(input, enc.NewDecoder()), nil
+}
+
+// lookupCharset finds the encoding for a charset label; nil means UTF-8 or ASCII
+func lookupCharset(charset string) (encoding.Encoding, error) {
+	label := strings.ToLower(strings.Trim(strings.TrimSpace(charset), `"`))
+	switch label {
+	case "", "utf-8", "utf8", "us-ascii", "ascii", "7bit", "8bit":
+		return nil, nil
+	}
+
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		// Some mailers use "cp1252" style names the WHATWG index does not know
+		if strings.HasPrefix(label, "cp") {
+			if enc, err = htmlindex.Get("windows-" + strings.TrimPrefix(label, "cp")); err == nil {
+				return enc, nil
+			}
+		}
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc, nil
+}
+
+// decodeCharset converts text in the given charset to UTF-8.
+// Undecodable input is returned with invalid sequences replaced.
+func decodeCharset(data []byte, charset string) string {
+	enc, err := lookupCharset(charset)
+	if err == nil && enc != nil {
+		if decoded, _, err := transform.Bytes(enc.NewDecoder(), data); err == nil {
+			return string(decoded)
+		}
+	}
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
+
+// DecodeHeader decodes RFC 2047 encoded-words, returning the raw value on failure
+func DecodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// DecodeAddressList parses an address header into display strings
+func DecodeAddressList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	parser := &mail.AddressParser{WordDecoder: headerDecoder}
+	addresses, err := parser.ParseList(value)
+	if err != nil {
+		return splitAddressList(DecodeHeader(value))
+	}
+
+	result := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		result = append(result, FormatAddress(addr))
+	}
+	return result
+}
+
+// FormatAddress renders an address for display, without RFC 2047 encoding
+func FormatAddress(addr *mail.Address) string {
+	if addr.Name == "" {
+		return addr.Address
+	}
+	if strings.ContainsAny(addr.Name, `,;:"<>@()[]\`) {
+		return fmt.Sprintf("%q <%s>", addr.Name, addr.Address)
+	}
+	return fmt.Sprintf("%s <%s>", addr.Name, addr.Address)
+}
+
+// ParseMessageIDs extracts all <message-id> tokens from a header, without brackets
+func ParseMessageIDs(value string) []string {
+	var ids []string
+	for {
+		start := strings.IndexByte(value, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(value[start:], '>')
+		if end < 0 {
+			break
+		}
+		if id := strings.TrimSpace(value[start+1 : start+end]); id != "" {
+			ids = append(ids, id)
+		}
+		value = value[start+end+1:]
+	}
+	return ids
+}
+
+// ParseMessage parses an RFC 5322 message and its MIME structure.
+// Transfer encodings and charsets are decoded, so text parts hold UTF-8
+// and attachments hold their binary content.
+func ParseMessage(r io.Reader) (*Message, error) {
+	msg, err := mail.ReadMessage(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	headers := make(map[string]string, len(msg.Header))
+	for name, values := range msg.Header {
+		if len(values) > 0 {
+			headers[name] = DecodeHeader(values[0])
+		}
+	}
+
+	result := &Message{
+		ID:         ParseMessageID(msg.Header.Get("Message-ID")),
+		From:       strings.Join(DecodeAddressList(msg.Header.Get("From")), ", "),
+		To:         DecodeAddressList(msg.Header.Get("To")),
+		Cc:         DecodeAddressList(msg.Header.Get("Cc")),
+		Subject:    DecodeHeader(msg.Header.Get("Subject")),
+		Headers:    headers,
+		InReplyTo:  ParseMessageID(msg.Header.Get("In-Reply-To")),
+		References: ParseMessageIDs(msg.Header.Get("References")),
+		Matched:    true,
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		result.Timestamp = date
+	}
+
+	counter := 0
+	part, err := parsePart(msg.Header, msg.Body, &counter, 0)
+	if err != nil {
+		return nil, err
+	}
+	result.Parts = []*MessagePart{part}
+	result.setBodies()
+
+	return result, nil
+}
+
+// ParseMessageID returns the first Message-ID of a header without brackets,
+// tolerating missing brackets
+func ParseMessageID(value string) string {
+	if ids := ParseMessageIDs(value); len(ids) > 0 {
+		return ids[0]
+	}
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+// partHeader is the subset of header access shared by mail.Header and textproto.MIMEHeader
+type partHeader interface {
+	Get(key string) string
+}
+
+// parsePart parses a MIME entity, recursing into multipart and message/rfc822 content
+func parsePart(header partHeader, body io.Reader, counter *int, depth int) (*MessagePart, error) {
+	*counter++
+	part := &MessagePart{ID: *counter}
+
+	mediaType, params := parseContentType(header.Get("Content-Type"))
+	part.ContentType = mediaType
+	part.Charset = params["charset"]
+	part.ContentTransferEncoding = strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding")))
+	part.ContentID = strings.Trim(strings.TrimSpace(header.Get("Content-ID")), "<>")
+
+	if disposition, dispParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		part.ContentDisposition = disposition
+		part.Filename = DecodeHeader(dispParams["filename"])
+	}
+	if part.Filename == "" && params["name"] != "" {
+		part.Filename = DecodeHeader(params["name"])
+	}
+
+	if depth >= maxPartDepth {
+		return part, nil
+	}
+
+	switch {
+	case part.IsMultipart() && params["boundary"] != "":
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			child, err := reader.NextRawPart()
+			if err != nil {
+				// End of parts, or a truncated multipart: keep the parts parsed so far
+				break
+			}
+			parsed, err := parsePart(child.Header, child, counter, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			part.Parts = append(part.Parts, parsed)
+		}
+		return part, nil
+	case part.ContentType == "message/rfc822" && part.ContentDisposition != "attachment":
+		data, _ := io.ReadAll(decodeTransfer(body, part.ContentTransferEncoding))
+		part.Size = len(data)
+		embedded, err := mail.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			part.Data = data
+			return part, nil
+		}
+		embeddedHeaders := make(map[string]string)
+		for _, name := range []string{"From", "To", "Cc", "Subject", "Date"} {
+			embeddedHeaders[name] = DecodeHeader(embedded.Header.Get(name))
+		}
+		part.Content = formatEmbeddedHeaders(embeddedHeaders)
+		child, err := parsePart(embedded.Header, embedded.Body, counter, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		part.Parts = []*MessagePart{child}
+		return part, nil
+	}
+
+	// Corrupt encodings keep whatever could be decoded
+	data, _ := io.ReadAll(decodeTransfer(body, part.ContentTransferEncoding))
+	part.Size = len(data)
+
+	if strings.HasPrefix(part.ContentType, "text/") && !part.IsAttachment() {
+		part.Content = decodeCharset(data, part.Charset)
+	} else {
+		part.Data = data
+	}
+	return part, nil
+}
+
+// parseContentType parses a Content-Type header, defaulting to text/plain.
+// Malformed parameters are tolerated by keeping just the media type.
+func parseContentType(value string) (string, map[string]string) {
+	if strings.TrimSpace(value) == "" {
+		return "text/plain", map[string]string{}
+	}
+
+	mediaType, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.SplitN(value, ";", 2)[0]))
+		params = lenientParams(value)
+	}
+	if !strings.Contains(mediaType, "/") {
+		mediaType = "text/plain"
+	}
+	return mediaType, params
+}
+
+// lenientParams extracts key=value parameters from a malformed header
+func lenientParams(value string) map[string]string {
+	params := make(map[string]string)
+	fields := strings.Split(value, ";")
+	for _, field := range fields[1:] {
+		key, val, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(val), `"`)
+	}
+	return params
+}
+
+// decodeTransfer wraps a body reader with its Content-Transfer-Encoding decoder
+func decodeTransfer(body io.Reader, encoding string) io.Reader {
+	switch encoding {
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: body})
+	}
+	return body
+}
+
+// base64Cleaner drops whitespace and stray characters so that sloppy base64
+// bodies (long lines, trailing garbage) still decode.
+type base64Cleaner struct {
+	r io.Reader
+}
+
+// Read implements io.Reader
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			switch {
+			case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9', b == '+', b == '/', b == '=':
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept > 0 {
+			return kept, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// setBodies derives the text, HTML and attachment views of a message from its parts
+func (m *Message) setBodies() {
+	m.TextBody = ""
+	m.HTMLBody = ""
+	m.Attachments = nil
+
+	var walk func(parts []*MessagePart, embedded bool)
+	walk = func(parts []*MessagePart, embedded bool) {
+		for _, part := range parts {
+			switch {
+			case part.IsMultipart():
+				walk(part.Parts, embedded)
+			case part.ContentType == "message/rfc822" && len(part.Parts) > 0:
+				walk(part.Parts, true)
+			case part.IsAttachment() || !strings.HasPrefix(part.ContentType, "text/"):
+				m.Attachments = append(m.Attachments, &Attachment{
+					PartID:      part.ID,
+					Filename:    part.Filename,
+					ContentType: part.ContentType,
+					ContentID:   part.ContentID,
+					Size:        part.Size,
+					Inline:      strings.EqualFold(part.ContentDisposition, "inline"),
+					Data:        part.Data,
+				})
+			case embedded:
+				// Text of forwarded messages belongs to Body only
+			case part.ContentType == "text/plain" && m.TextBody == "":
+				m.TextBody = part.Content
+			case part.ContentType == "text/html" && m.HTMLBody == "":
+				m.HTMLBody = part.Content
+			}
+		}
+	}
+	walk(m.Parts, false)
+
+	m.Body = textBody(m.Parts)
+}
+
+// HTML to text conversion patterns
+var (
+	htmlHiddenPattern = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlBreakPattern  = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlBlockPattern  = regexp.MustCompile(`(?i)</?(p|div|tr|h[1-6]|blockquote|ul|ol|table)[^>]*>`)
+	htmlItemPattern   = regexp.MustCompile(`(?i)<li[^>]*>`)
+	htmlTagPattern    = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// HTMLToText renders an HTML body as plain text for the terminal
+func HTMLToText(source string) string {
+	text := htmlHiddenPattern.ReplaceAllString(source, "")
+	text = htmlBreakPattern.ReplaceAllString(text, "\n")
+	text = htmlBlockPattern.ReplaceAllString(text, "\n")
+	text = htmlItemPattern.ReplaceAllString(text, "\n• ")
+	text = htmlTagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(strings.Join(strings.Fields(line), " "))
+	}
+	text = blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text) + "\n"
+}
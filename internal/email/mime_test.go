@@ -0,0 +1,168 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+// crlf converts a fixture to wire format
+func crlf(s string) string {
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+func TestParseMessageMultipart(t *testing.T) {
+	raw := crlf(`Message-ID: <multi@example.com>
+In-Reply-To: <parent@example.com>
+References: <root@example.com> <parent@example.com>
+From: =?ISO-8859-1?Q?Andr=E9?= <andre@example.com>
+To: =?Shift_JIS?B?grGC8YLJgr+CzQ==?= <taro@example.jp>, bob@example.com
+Subject: =?UTF-8?B?UmFwcG9ydCDDqXTDqQ==?=
+Date: Fri, 01 Mar 2024 09:00:00 +0100
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain; charset=iso-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+Voici le rapport de l'=E9t=E9.
+--inner
+Content-Type: text/html; charset=utf-8
+
+<p>Voici le <b>rapport</b> de l&#39;&eacute;t&eacute;.</p>
+--inner--
+
+--outer
+Content-Type: application/pdf; name="=?UTF-8?Q?r=C3=A9sum=C3=A9.pdf?="
+Content-Disposition: attachment
+Content-Transfer-Encoding: base64
+
+JVBERi0x
+LjQgZmFrZQ==
+--outer--
+`)
+
+	msg, err := ParseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseMessage() failed: %v", err)
+	}
+
+	if msg.ID != "multi@example.com" || msg.InReplyTo != "parent@example.com" || len(msg.References) != 2 {
+		t.Errorf("Unexpected threading headers: %q %q %v", msg.ID, msg.InReplyTo, msg.References)
+	}
+	if msg.From != "André <andre@example.com>" {
+		t.Errorf("Expected ISO-8859-1 encoded-word to be decoded, got %q", msg.From)
+	}
+	if len(msg.To) != 2 || msg.To[0] != "こんにちは <taro@example.jp>" {
+		t.Errorf("Expected Shift_JIS encoded-word to be decoded, got %v", msg.To)
+	}
+	if msg.Subject != "Rapport été" {
+		t.Errorf("Expected base64 encoded-word subject, got %q", msg.Subject)
+	}
+	if msg.Timestamp.IsZero() {
+		t.Error("Expected date to be parsed")
+	}
+
+	if msg.TextBody != "Voici le rapport de l'été." {
+		t.Errorf("Expected quoted-printable latin-1 text, got %q", msg.TextBody)
+	}
+	if !strings.Contains(msg.HTMLBody, "<b>rapport</b>") {
+		t.Errorf("Expected HTML part, got %q", msg.HTMLBody)
+	}
+	if msg.Body != msg.TextBody {
+		t.Errorf("Expected body to prefer the text part, got %q", msg.Body)
+	}
+
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("Expected 1 attachment, got %d", len(msg.Attachments))
+	}
+	attachment := msg.Attachments[0]
+	if attachment.Filename != "résumé.pdf" || attachment.ContentType != "application/pdf" {
+		t.Errorf("Unexpected attachment %+v", attachment)
+	}
+	if string(attachment.Data) != "%PDF-1.4 fake" || attachment.Size != 13 {
+		t.Errorf("Expected base64 attachment to be decoded, got %q", attachment.Data)
+	}
+}
+
+func TestParseMessageCharsets(t *testing.T) {
+	tests := []struct {
+		name     string
+		charset  string
+		encoding string
+		body     string
+		want     string
+	}{
+		{"windows-1252", "windows-1252", "quoted-printable", "=93quoted=94 =80", "“quoted” €"},
+		{"iso-8859-15", "ISO-8859-15", "8bit", "\xa4", "€"},
+		{"shift_jis", "Shift_JIS", "base64", "grGC8YLJgr+CzQ==", "こんにちは"},
+		{"gb2312", "gb2312", "8bit", "\xc4\xe3\xba\xc3", "你好"},
+		{"cp1251 alias", "cp1251", "8bit", "\xcf\xf0\xe8\xe2\xe5\xf2", "Привет"},
+		{"unknown charset", "x-unknown", "8bit", "plain", "plain"},
+	}
+
+	for _, tt := range tests {
+		raw := "Subject: test\r\nContent-Type: text/plain; charset=" + tt.charset +
+			"\r\nContent-Transfer-Encoding: " + tt.encoding + "\r\n\r\n" + tt.body
+
+		msg, err := ParseMessage(strings.NewReader(raw))
+		if err != nil {
+			t.Fatalf("%s: ParseMessage() failed: %v", tt.name, err)
+		}
+		if msg.TextBody != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, msg.TextBody, tt.want)
+		}
+	}
+}
+
+func TestParseMessageHTMLOnlyAndEmbedded(t *testing.T) {
+	raw := crlf(`Subject: Fwd: hello
+Content-Type: multipart/mixed; boundary=b
+
+--b
+Content-Type: text/html
+
+<html><head><style>p{}</style></head><body><p>Hi&nbsp;there</p><ul><li>one</li></ul></body></html>
+--b
+Content-Type: message/rfc822
+
+From: Alice <alice@example.com>
+Subject: hello
+
+Original text
+--b--
+`)
+
+	msg, err := ParseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseMessage() failed: %v", err)
+	}
+
+	if msg.TextBody != "" {
+		t.Errorf("Expected no text part, got %q", msg.TextBody)
+	}
+	if !strings.Contains(msg.Body, "Hi there") || !strings.Contains(msg.Body, "• one") {
+		t.Errorf("Expected HTML to be rendered as text, got %q", msg.Body)
+	}
+	if strings.Contains(msg.Body, "p{}") {
+		t.Errorf("Expected style content to be dropped, got %q", msg.Body)
+	}
+	if !strings.Contains(msg.Body, "Subject: hello") || !strings.Contains(msg.Body, "Original text") {
+		t.Errorf("Expected forwarded message in body, got %q", msg.Body)
+	}
+}
+
+func TestParseMessageMalformed(t *testing.T) {
+	raw := "Subject: broken\r\nContent-Type: text/plain; charset=\"utf-8; format=flowed\r\nContent-Transfer-Encoding: base64\r\n\r\naGVsbG8gd29y\r\nbGQ=\r\n-- garbage"
+
+	msg, err := ParseMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ParseMessage() failed: %v", err)
+	}
+	if !strings.HasPrefix(msg.TextBody, "hello world") {
+		t.Errorf("Expected lenient decoding, got %q", msg.TextBody)
+	}
+}
@@ -0,0 +1,210 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GetMailFolders scans the mail directory and returns all available folders
+func (m *Manager) GetMailFolders(ctx context.Context) ([]*MailFolder, error) {
+	return CollectFolders(m.StreamMailFolders(ctx))
+}
+
+// StreamMailFolders lists folders progressively. A single `notmuch count
+// --batch` process is fed queries while the directory walk is still running;
+// the folder list is sent as soon as the walk completes and counts follow as
+// notmuch reports them. The channel is closed when everything has been sent;
+// cancelling ctx stops the count and the walk early.
+func (m *Manager) StreamMailFolders(ctx context.Context) <-chan FolderUpdate {
+	updates := make(chan FolderUpdate)
+	go m.streamMailFolders(ctx, updates)
+	return updates
+}
+
+// streamMailFolders runs the walk and the batched count side by side
+func (m *Manager) streamMailFolders(ctx context.Context, updates chan<- FolderUpdate) {
+	defer close(updates)
+
+	counter, err := m.startFolderCounter(ctx)
+	if err != nil {
+		// Still show the folders, just without counts
+		folders, walkErr := ScanFolders(m.maildirPath)
+		if walkErr != nil {
+			updates <- FolderUpdate{Err: walkErr}
+			return
+		}
+		m.folders.Remember(folders)
+		updates <- FolderUpdate{Folders: folders}
+		updates <- FolderUpdate{Err: err}
+		return
+	}
+
+	// Counts are only forwarded once the folder list has been sent
+	listed := make(chan struct{})
+	counted := make(chan error, 1)
+	go func() {
+		err := counter.read(updates, listed)
+		// Keep notmuch from blocking on a full pipe if reading stopped early
+		io.Copy(io.Discard, counter.process.Stdout)
+		counted <- err
+	}()
+
+	folders := []*MailFolder{}
+	walkErr := WalkFolders(m.maildirPath, func(folder *MailFolder) {
+		folders = append(folders, folder)
+		// Stop feeding notmuch once the caller has given up
+		if ctx.Err() == nil {
+			dir := path.Join(m.folderPrefix, relativeFolderDir(m.maildirPath, folder.Path))
+			counter.queue(folder.Name, m.listingQuery(folder.Name, dir))
+		}
+	})
+	counter.closeQueries()
+
+	if walkErr != nil {
+		updates <- FolderUpdate{Err: walkErr}
+	} else {
+		SortFolders(folders)
+		m.folders.Remember(folders)
+		updates <- FolderUpdate{Folders: folders}
+	}
+	close(listed)
+
+	readErr := <-counted
+	if err := classifyNotmuchError(counter.process.Wait()); err != nil {
+		updates <- FolderUpdate{Err: fmt.Errorf("failed to count folders: %w", err)}
+	} else if readErr != nil {
+		updates <- FolderUpdate{Err: fmt.Errorf("failed to read folder counts: %w", readErr)}
+	}
+}
+
+// folderCounter wraps a running `notmuch count --batch` process. Each folder
+// is sent as two queries, total then unread, and notmuch answers with one
+// count per line in the same order.
+type folderCounter struct {
+	process *Process
+
+	mu      sync.Mutex
+	pending []string // Folders whose counts have not been read yet
+}
+
+// startFolderCounter starts the batched count process
+func (m *Manager) startFolderCounter(ctx context.Context) (*folderCounter, error) {
+	cmd := Command{Path: m.notmuchPath, Args: []string{"count", "--batch"}, Timeout: m.runner.Timeouts.Query}
+	process, err := m.runner.Start(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start notmuch count: %w", err)
+	}
+	return &folderCounter{process: process}, nil
+}
+
+// queue sends the count queries of a folder to notmuch
+func (c *folderCounter) queue(folderName, query string) {
+	c.mu.Lock()
+	c.pending = append(c.pending, folderName)
+	c.mu.Unlock()
+
+	// A write error means notmuch exited; Wait reports why
+	fmt.Fprintf(c.process.Stdin, "%s\n%s and tag:unread\n", query, query)
+}
+
+// closeQueries signals notmuch that no more queries will be sent
+func (c *folderCounter) closeQueries() {
+	c.process.Stdin.Close()
+}
+
+// read parses counts as notmuch prints them and forwards one update per
+// folder. Counts read before listed is closed are held rather than sent:
+// blocking on them would leave notmuch stuck on a full pipe, and the walk
+// stuck feeding it queries.
+func (c *folderCounter) read(updates chan<- FolderUpdate, listed <-chan struct{}) error {
+	scanner := bufio.NewScanner(c.process.Stdout)
+	var held []FolderUpdate
+
+	for {
+		total, ok, err := scanCount(scanner)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		unread, ok, err := scanCount(scanner)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("missing unread count")
+		}
+
+		c.mu.Lock()
+		if len(c.pending) == 0 {
+			c.mu.Unlock()
+			return fmt.Errorf("unexpected count output")
+		}
+		name := c.pending[0]
+		c.pending = c.pending[1:]
+		c.mu.Unlock()
+
+		held = append(held, FolderUpdate{Counts: &FolderCounts{Name: name, Unread: unread, Total: total}})
+		select {
+		case <-listed:
+			for _, update := range held {
+				updates <- update
+			}
+			held = held[:0]
+		default:
+		}
+	}
+
+	// notmuch is done, so the walk can finish and close listed
+	<-listed
+	for _, update := range held {
+		updates <- update
+	}
+	return nil
+}
+
+// scanCount reads the next count line, reporting false at end of output
+func scanCount(scanner *bufio.Scanner) (int, bool, error) {
+	if !scanner.Scan() {
+		return 0, false, scanner.Err()
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid count %q: %w", scanner.Text(), err)
+	}
+	return count, true, nil
+}
+
+// folderDir resolves a folder name to its directory relative to the notmuch
+// database, which is the mail directory unless SetDatabasePath was called
+func (m *Manager) folderDir(folderName string) string {
+	dir := m.folders.Resolve(folderName)
+	if m.folderPrefix == "" {
+		return dir
+	}
+	return path.Join(m.folderPrefix, dir)
+}
+
+// relativeFolderDir returns a folder path relative to the mail directory,
+// using "" for a maildir at the root as notmuch does
+func relativeFolderDir(maildirPath, folderPath string) string {
+	rel, err := filepath.Rel(maildirPath, folderPath)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// folderQuery builds a notmuch query matching a folder directory, quoting it
+// so directories containing spaces or query syntax are matched literally
+func folderQuery(folderDir string) string {
+	return `folder:"` + strings.ReplaceAll(folderDir, `"`, `""`) + `"`
+}
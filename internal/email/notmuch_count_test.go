@@ -0,0 +1,172 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeNotmuch writes a notmuch stand-in that answers `count --batch` with the
+// length of each query and records every invocation in a log file
+func fakeNotmuch(t *testing.T) (path, log string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake notmuch needs a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	path = filepath.Join(dir, "notmuch")
+	log = filepath.Join(dir, "calls.log")
+	script := `#!/bin/sh
+echo "$@" >> "` + log + `"
+while IFS= read -r query; do
+	echo ${#query}
+done
+`
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write fake notmuch: %v", err)
+	}
+	return path, log
+}
+
+func TestStreamMailFoldersBatched(t *testing.T) {
+	notmuch, log := fakeNotmuch(t)
+
+	root := t.TempDir()
+	makeMaildirs(t, root, "INBOX", "Lists/go", "Sent Items")
+
+	manager := NewManager(root, notmuch, "mbsync", "msmtp")
+	updates := manager.StreamMailFolders(context.Background())
+
+	first := <-updates
+	if first.Folders == nil {
+		t.Fatalf("Expected the folder list before any counts, got %+v", first)
+	}
+
+	counted := 0
+	for update := range updates {
+		if update.Err != nil {
+			t.Fatalf("Unexpected error: %v", update.Err)
+		}
+		if update.Counts == nil {
+			continue
+		}
+		counted++
+		query := folderQuery(update.Counts.Name)
+		if update.Counts.Total != len(query) || update.Counts.Unread != len(query+" and tag:unread") {
+			t.Errorf("Counts of %s paired with the wrong queries: %+v", update.Counts.Name, update.Counts)
+		}
+	}
+	if len(first.Folders) != 3 || counted != 3 {
+		t.Errorf("Expected 3 folders with counts, got %d folders and %d counts", len(first.Folders), counted)
+	}
+
+	calls, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(calls) != "count --batch\n" {
+		t.Errorf("Expected a single batched notmuch call, got %q", calls)
+	}
+}
+
+func TestFolderCounterReadsBeforeListed(t *testing.T) {
+	const folders = 100
+	stdout, output := io.Pipe()
+	counter := &folderCounter{process: &Process{Stdout: stdout}}
+	for i := 0; i < folders; i++ {
+		counter.pending = append(counter.pending, fmt.Sprintf("folder%d", i))
+	}
+
+	// The pipe is unbuffered, so the folder list only goes out once every
+	// count has been read, as when notmuch output outgrows the pipe buffer
+	listed := make(chan struct{})
+	go func() {
+		for i := 0; i < folders; i++ {
+			fmt.Fprintf(output, "%d\n%d\n", i+1, i)
+		}
+		output.Close()
+		close(listed)
+	}()
+
+	updates := make(chan FolderUpdate, folders)
+	if err := counter.read(updates, listed); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	close(updates)
+
+	i := 0
+	for update := range updates {
+		if update.Counts.Name != fmt.Sprintf("folder%d", i) || update.Counts.Total != i+1 || update.Counts.Unread != i {
+			t.Errorf("Unexpected update %d: %+v", i, update.Counts)
+		}
+		i++
+	}
+	if i != folders {
+		t.Errorf("Expected %d updates, got %d", folders, i)
+	}
+}
+
+func TestGetMailFoldersWithoutNotmuch(t *testing.T) {
+	root := t.TempDir()
+	makeMaildirs(t, root, "INBOX")
+
+	manager := NewManager(root, filepath.Join(root, "missing-notmuch"), "mbsync", "msmtp")
+	folders, err := manager.GetMailFolders(context.Background())
+	if err == nil {
+		t.Error("Expected an error when notmuch cannot be started")
+	}
+	if len(folders) != 1 || folders[0].Name != "INBOX" {
+		t.Errorf("Expected folders to be listed without counts, got %v", folders)
+	}
+}
+
+func TestFolderQuery(t *testing.T) {
+	if got := folderQuery(`Sent "old"`); got != `folder:"Sent ""old"""` {
+		t.Errorf("Unexpected folder query %s", got)
+	}
+}
+
+func TestManagerFolderDir(t *testing.T) {
+	root := t.TempDir()
+	makeMaildirs(t, root, "", ".Lists.golang")
+
+	manager := NewManager(root, "notmuch", "mbsync", "msmtp")
+	tests := map[string]string{
+		"INBOX":        "",
+		"Lists/golang": ".Lists.golang",
+		"Drafts":       ".Drafts", // Not on disk yet, mapped by layout
+	}
+	for name, want := range tests {
+		if got := manager.folderDir(name); got != want {
+			t.Errorf("folderDir(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestManagerFolderDirInDatabase(t *testing.T) {
+	root := t.TempDir()
+	makeMaildirs(t, root, "work/INBOX", "work/Lists/golang")
+
+	manager := NewManager(filepath.Join(root, "work"), "notmuch", "mbsync", "msmtp")
+	if err := manager.SetDatabasePath(root); err != nil {
+		t.Fatalf("SetDatabasePath failed: %v", err)
+	}
+	tests := map[string]string{
+		"INBOX":        "work/INBOX",
+		"Lists/golang": "work/Lists/golang",
+	}
+	for name, want := range tests {
+		if got := manager.folderDir(name); got != want {
+			t.Errorf("folderDir(%s) = %q, want %q", name, got, want)
+		}
+	}
+
+	if err := manager.SetDatabasePath(filepath.Join(root, "home")); err == nil {
+		t.Errorf("Expected an error for a maildir outside the database")
+	}
+}
@@ -0,0 +1,64 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// StoreMessage delivers a message to a folder with notmuch insert, which
+// indexes it and, with maildir.synchronize_flags, sets the matching flags
+func (m *Manager) StoreMessage(ctx context.Context, folderName string, message []byte, tags []string) error {
+	args := []string{"insert", "--folder=" + m.folderDir(folderName), "--create-folder"}
+	for _, tag := range tags {
+		args = append(args, "+"+tag)
+	}
+	// Our own messages are neither unread nor new in the inbox
+	args = append(args, "-unread", "-inbox")
+
+	_, err := m.runner.Run(ctx, Command{
+		Path:    m.notmuchPath,
+		Args:    args,
+		Stdin:   bytes.NewReader(message),
+		Timeout: m.runner.Timeouts.Tag,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store message in %s: %w", folderName, classifyNotmuchError(err))
+	}
+	return nil
+}
+
+// RemoveMessage deletes the files of a message. Rescanning the mail
+// directory on every draft save would be slow, so the message is tagged
+// deleted instead, which the Drafts listing leaves out, and the next sync's
+// notmuch new drops it from the index.
+func (m *Manager) RemoveMessage(ctx context.Context, messageID string) error {
+	// Excluded messages, such as one tagged deleted by an earlier attempt,
+	// still have files to remove
+	output, err := m.notmuch(ctx, m.runner.Timeouts.Query, "search", "--output=files", "--exclude=false", "--", idQuery(messageID))
+	if err != nil {
+		return fmt.Errorf("failed to find message files: %w", err)
+	}
+
+	files := strings.TrimSpace(string(output))
+	if files == "" {
+		return nil
+	}
+	// Tag before the files go, as notmuch may touch them when tagging
+	if _, err := m.notmuch(ctx, m.runner.Timeouts.Tag, "tag", "+deleted", "--", idQuery(messageID)); err != nil {
+		return fmt.Errorf("failed to tag removed message: %w", err)
+	}
+	for _, file := range strings.Split(files, "\n") {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove message file: %w", err)
+		}
+	}
+	return nil
+}
+
+// idQuery builds a notmuch query matching a Message-ID literally
+func idQuery(messageID string) string {
+	return `id:"` + strings.ReplaceAll(messageID, `"`, `""`) + `"`
+}
@@ -0,0 +1,64 @@
+package email
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStoreAndRemoveMessageWithNotmuch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake notmuch needs a POSIX shell")
+	}
+
+	root := t.TempDir()
+	makeMaildirs(t, root, "INBOX", ".Drafts")
+	draft := filepath.Join(root, ".Drafts", "cur", "1.host:2,DS")
+	if err := os.WriteFile(draft, []byte("draft"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	notmuch := filepath.Join(dir, "notmuch")
+	log := filepath.Join(dir, "calls.log")
+	stdin := filepath.Join(dir, "stdin")
+	script := `#!/bin/sh
+echo "$@" >> "` + log + `"
+case "$1" in
+insert) cat > "` + stdin + `" ;;
+search) echo "` + draft + `" ;;
+esac
+`
+	if err := os.WriteFile(notmuch, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write fake notmuch: %v", err)
+	}
+
+	manager := NewManager(root, notmuch, "mbsync", "msmtp")
+	ctx := context.Background()
+	if err := manager.StoreMessage(ctx, "Drafts", []byte("Subject: hi\r\n\r\nbody\r\n"), []string{"draft"}); err != nil {
+		t.Fatalf("StoreMessage failed: %v", err)
+	}
+	if data, _ := os.ReadFile(stdin); string(data) != "Subject: hi\r\n\r\nbody\r\n" {
+		t.Errorf("Expected the message on notmuch insert's stdin, got %q", data)
+	}
+
+	if err := manager.RemoveMessage(ctx, `odd"id@example.com`); err != nil {
+		t.Fatalf("RemoveMessage failed: %v", err)
+	}
+	if _, err := os.Stat(draft); !os.IsNotExist(err) {
+		t.Errorf("Expected the draft file to be removed")
+	}
+
+	calls, _ := os.ReadFile(log)
+	want := []string{
+		"insert --folder=.Drafts --create-folder +draft -unread -inbox",
+		`search --output=files --exclude=false -- id:"odd""id@example.com"`,
+		`tag +deleted -- id:"odd""id@example.com"`,
+	}
+	if got := strings.Split(strings.TrimSpace(string(calls)), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected notmuch calls %q, got %q", want, got)
+	}
+}
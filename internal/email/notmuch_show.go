@@ -0,0 +1,354 @@
+package email
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MessagePart represents a single MIME part of a message
+type MessagePart struct {
+	ID                      int            `json:"id"`
+	ContentType             string         `json:"content_type"`
+	Charset                 string         `json:"charset,omitempty"`
+	ContentDisposition      string         `json:"content_disposition,omitempty"`
+	ContentTransferEncoding string         `json:"content_transfer_encoding,omitempty"`
+	Filename                string         `json:"filename,omitempty"`
+	Size                    int            `json:"size,omitempty"`
+	ContentID               string         `json:"content_id,omitempty"`
+	Content                 string         `json:"content,omitempty"` // Decoded text of text/* parts
+	Data                    []byte         `json:"-"`                 // Decoded content of other parts
+	Parts                   []*MessagePart `json:"parts,omitempty"`
+}
+
+// IsMultipart reports whether the part is a multipart container
+func (p *MessagePart) IsMultipart() bool {
+	return strings.HasPrefix(strings.ToLower(p.ContentType), "multipart/")
+}
+
+// IsAttachment reports whether the part should be shown as an attachment
+func (p *MessagePart) IsAttachment() bool {
+	return strings.EqualFold(p.ContentDisposition, "attachment") || (p.Filename != "" && !p.IsMultipart())
+}
+
+// notmuchShowMessage represents a single message in notmuch show JSON output
+type notmuchShowMessage struct {
+	ID           string            `json:"id"`
+	Match        bool              `json:"match"`
+	Excluded     bool              `json:"excluded"`
+	Filename     json.RawMessage   `json:"filename"`
+	Timestamp    int64             `json:"timestamp"`
+	DateRelative string            `json:"date_relative"`
+	Tags         []string          `json:"tags"`
+	Headers      map[string]string `json:"headers"`
+	Body         []notmuchShowPart `json:"body"`
+	Crypto       json.RawMessage   `json:"crypto"`
+	Duplicate    int               `json:"duplicate"`
+}
+
+// notmuchShowPart represents a MIME part in notmuch show JSON output.
+// Content is either a string (leaf parts), a list of parts (multipart/*)
+// or a list of embedded messages (message/rfc822).
+type notmuchShowPart struct {
+	ID                      json.RawMessage `json:"id"`
+	ContentType             string          `json:"content-type"`
+	ContentCharset          string          `json:"content-charset"`
+	ContentDisposition      string          `json:"content-disposition"`
+	ContentTransferEncoding string          `json:"content-transfer-encoding"`
+	ContentLength           int             `json:"content-length"`
+	Filename                string          `json:"filename"`
+	Content                 json.RawMessage `json:"content"`
+}
+
+// notmuchShowEmbedded represents an embedded message/rfc822 part
+type notmuchShowEmbedded struct {
+	Headers map[string]string `json:"headers"`
+	Body    []notmuchShowPart `json:"body"`
+}
+
+// notmuchShowNode is a [message, replies] pair from the notmuch thread tree
+type notmuchShowNode struct {
+	Message *notmuchShowMessage
+	Replies []*notmuchShowNode
+}
+
+// UnmarshalJSON decodes the two-element [message, replies] array notmuch emits
+func (n *notmuchShowNode) UnmarshalJSON(data []byte) error {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return fmt.Errorf("invalid thread node: %w", err)
+	}
+	if len(pair) != 2 {
+		return fmt.Errorf("invalid thread node: expected 2 elements, got %d", len(pair))
+	}
+
+	// Non-matching messages may be emitted as null when the entire thread is not requested
+	if string(pair[0]) != "null" {
+		n.Message = &notmuchShowMessage{}
+		if err := json.Unmarshal(pair[0], n.Message); err != nil {
+			return fmt.Errorf("invalid message: %w", err)
+		}
+	}
+
+	if err := json.Unmarshal(pair[1], &n.Replies); err != nil {
+		return fmt.Errorf("invalid replies: %w", err)
+	}
+	return nil
+}
+
+// parseNotmuchThread parses notmuch show --format=json output into a Thread
+func (m *Manager) parseNotmuchThread(threadID string, output []byte) (*Thread, error) {
+	if len(output) == 0 {
+		return nil, fmt.Errorf("empty notmuch show output")
+	}
+
+	// notmuch show emits a list of threads, each a list of top-level nodes
+	var threadSet [][]*notmuchShowNode
+	if err := json.Unmarshal(output, &threadSet); err != nil {
+		return nil, fmt.Errorf("failed to parse notmuch show output: %w", err)
+	}
+
+	if len(threadSet) == 0 {
+		return nil, fmt.Errorf("thread not found")
+	}
+
+	thread := &Thread{ID: strings.TrimPrefix(threadID, "thread:")}
+	for _, roots := range threadSet {
+		for _, node := range roots {
+			thread.Roots = append(thread.Roots, m.convertNotmuchNode(node, nil, 0, thread)...)
+		}
+	}
+
+	if len(thread.Messages) == 0 {
+		return nil, fmt.Errorf("thread contains no messages")
+	}
+
+	thread.Summarize()
+	return thread, nil
+}
+
+// convertNotmuchNode converts a notmuch thread node and its replies into messages.
+// Messages are appended to thread.Messages in depth-first (display) order.
+// Null nodes are skipped and their replies are attached to the nearest parent.
+func (m *Manager) convertNotmuchNode(node *notmuchShowNode, parent *Message, depth int, thread *Thread) []*Message {
+	if node.Message == nil {
+		var promoted []*Message
+		for _, reply := range node.Replies {
+			promoted = append(promoted, m.convertNotmuchNode(reply, parent, depth, thread)...)
+		}
+		return promoted
+	}
+
+	msg := m.convertNotmuchMessage(node.Message)
+	msg.ThreadID = thread.ID
+	msg.Depth = depth
+	if parent != nil {
+		msg.ParentID = parent.ID
+		msg.InReplyTo = parent.ID
+	}
+	thread.Messages = append(thread.Messages, msg)
+
+	for _, reply := range node.Replies {
+		msg.Replies = append(msg.Replies, m.convertNotmuchNode(reply, msg, depth+1, thread)...)
+	}
+
+	return []*Message{msg}
+}
+
+// convertNotmuchMessage converts a notmuch show message into our Message model
+func (m *Manager) convertNotmuchMessage(nm *notmuchShowMessage) *Message {
+	headers := nm.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+
+	parts := make([]*MessagePart, 0, len(nm.Body))
+	for i := range nm.Body {
+		parts = append(parts, convertNotmuchPart(&nm.Body[i]))
+	}
+
+	msg := &Message{
+		ID:        nm.ID,
+		From:      headers["From"],
+		To:        splitAddressList(headers["To"]),
+		Cc:        splitAddressList(headers["Cc"]),
+		Subject:   headers["Subject"],
+		Timestamp: time.Unix(nm.Timestamp, 0),
+		Unread:    hasTag(nm.Tags, "unread"),
+		Starred:   hasTag(nm.Tags, "starred") || hasTag(nm.Tags, "flagged"),
+		Labels:    nm.Tags,
+		Headers:   headers,
+		Filenames: parseNotmuchFilenames(nm.Filename),
+		Parts:     parts,
+		Matched:   nm.Match,
+		Excluded:  nm.Excluded,
+	}
+	msg.setBodies()
+
+	return msg
+}
+
+// convertNotmuchPart converts a notmuch show part (recursively) into a MessagePart
+func convertNotmuchPart(np *notmuchShowPart) *MessagePart {
+	part := &MessagePart{
+		ContentType:             strings.ToLower(np.ContentType),
+		Charset:                 np.ContentCharset,
+		ContentDisposition:      np.ContentDisposition,
+		ContentTransferEncoding: np.ContentTransferEncoding,
+		Filename:                np.Filename,
+		Size:                    np.ContentLength,
+	}
+
+	// Part IDs are integers, except for encrypted parts in some notmuch versions
+	if err := json.Unmarshal(np.ID, &part.ID); err != nil {
+		part.ID = 0
+	}
+
+	if len(np.Content) == 0 || string(np.Content) == "null" {
+		return part
+	}
+
+	switch np.Content[0] {
+	case '"':
+		if err := json.Unmarshal(np.Content, &part.Content); err == nil && part.Size == 0 {
+			part.Size = len(part.Content)
+		}
+	case '[':
+		if part.ContentType == "message/rfc822" {
+			var embedded []notmuchShowEmbedded
+			if err := json.Unmarshal(np.Content, &embedded); err == nil {
+				for _, e := range embedded {
+					part.Content += formatEmbeddedHeaders(e.Headers)
+					for i := range e.Body {
+						part.Parts = append(part.Parts, convertNotmuchPart(&e.Body[i]))
+					}
+				}
+			}
+			return part
+		}
+
+		var children []notmuchShowPart
+		if err := json.Unmarshal(np.Content, &children); err == nil {
+			for i := range children {
+				part.Parts = append(part.Parts, convertNotmuchPart(&children[i]))
+			}
+		}
+	}
+
+	return part
+}
+
+// parseNotmuchFilenames handles both the legacy string and the list filename formats
+func parseNotmuchFilenames(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var filenames []string
+	if err := json.Unmarshal(raw, &filenames); err == nil {
+		return filenames
+	}
+
+	var filename string
+	if err := json.Unmarshal(raw, &filename); err == nil && filename != "" {
+		return []string{filename}
+	}
+	return nil
+}
+
+// formatEmbeddedHeaders renders the main headers of an embedded message
+func formatEmbeddedHeaders(headers map[string]string) string {
+	var b strings.Builder
+	for _, name := range []string{"From", "To", "Cc", "Subject", "Date"} {
+		if value := headers[name]; value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", name, value)
+		}
+	}
+	return b.String()
+}
+
+// textBody extracts the readable text of a message from its parts.
+// text/plain is preferred over text/html inside multipart/alternative.
+func textBody(parts []*MessagePart) string {
+	var texts []string
+	for _, part := range parts {
+		if text := partText(part); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// partText returns the displayable text of a single part
+func partText(part *MessagePart) string {
+	switch {
+	case part.ContentType == "multipart/alternative":
+		var html string
+		for _, child := range part.Parts {
+			if child.ContentType == "text/plain" && child.Content != "" {
+				return child.Content
+			}
+			if html == "" {
+				html = partText(child)
+			}
+		}
+		return html
+	case part.IsMultipart():
+		return textBody(part.Parts)
+	case part.ContentType == "message/rfc822":
+		return part.Content + "\n" + textBody(part.Parts)
+	case part.IsAttachment():
+		return ""
+	case part.ContentType == "text/html":
+		return HTMLToText(part.Content)
+	case strings.HasPrefix(part.ContentType, "text/"):
+		return part.Content
+	}
+	return ""
+}
+
+// splitAddressList splits a comma-separated address header, respecting quotes
+func splitAddressList(header string) []string {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+
+	var (
+		addresses []string
+		current   strings.Builder
+		inQuotes  bool
+		inAngle   bool
+	)
+	for _, r := range header {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == '<' && !inQuotes:
+			inAngle = true
+		case r == '>' && !inQuotes:
+			inAngle = false
+		case r == ',' && !inQuotes && !inAngle:
+			if addr := strings.TrimSpace(current.String()); addr != "" {
+				addresses = append(addresses, addr)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if addr := strings.TrimSpace(current.String()); addr != "" {
+		addresses = append(addresses, addr)
+	}
+	return addresses
+}
+
+// hasTag checks whether a tag list contains the given tag
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package email
+
+import (
+	"testing"
+)
+
+const notmuchShowFixture = `[[[{"id": "root@example.com", "match": true, "excluded": false,
+  "filename": ["/home/user/Mail/INBOX/cur/1:2,S"], "timestamp": 1700000000,
+  "date_relative": "2023-11-14", "tags": ["inbox"],
+  "headers": {"Subject": "Lunch?", "From": "Alice <alice@example.com>",
+    "To": "Bob <bob@example.com>, \"Doe, Carol\" <carol@example.com>", "Date": "Tue, 14 Nov 2023 22:13:20 +0000"},
+  "body": [{"id": 1, "content-type": "multipart/alternative", "content": [
+    {"id": 2, "content-type": "text/plain", "content": "Shall we grab lunch?\n"},
+    {"id": 3, "content-type": "text/html", "content": "<p>Shall we grab lunch?</p>"}]}]},
+  [[{"id": "reply@example.com", "match": true, "excluded": true,
+    "filename": "/home/user/Mail/INBOX/cur/2:2,", "timestamp": 1700003600,
+    "date_relative": "2023-11-14", "tags": ["inbox", "unread", "flagged"],
+    "headers": {"Subject": "Re: Lunch?", "From": "Bob <bob@example.com>",
+      "To": "Alice <alice@example.com>", "Cc": "carol@example.com", "Date": "Tue, 14 Nov 2023 23:13:20 +0000"},
+    "body": [{"id": 1, "content-type": "multipart/mixed", "content": [
+      {"id": 2, "content-type": "text/plain", "content": "Sure, see menu.\n"},
+      {"id": 3, "content-type": "application/pdf", "content-disposition": "attachment",
+        "filename": "menu.pdf", "content-transfer-encoding": "base64", "content-length": 2048}]}]},
+    []]]]]]`
+
+func TestParseNotmuchThread(t *testing.T) {
+	m := NewManager("", "notmuch", "mbsync", "msmtp")
+
+	thread, err := m.parseNotmuchThread("thread:0000000000000001", []byte(notmuchShowFixture))
+	if err != nil {
+		t.Fatalf("parseNotmuchThread() failed: %v", err)
+	}
+
+	if thread.ID != "0000000000000001" {
+		t.Errorf("Expected thread ID without prefix, got '%s'", thread.ID)
+	}
+	if thread.Subject != "Lunch?" {
+		t.Errorf("Expected subject 'Lunch?', got '%s'", thread.Subject)
+	}
+	if thread.MessageCount != 2 || len(thread.Messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(thread.Messages))
+	}
+	if thread.UnreadCount != 1 {
+		t.Errorf("Expected 1 unread message, got %d", thread.UnreadCount)
+	}
+	if len(thread.Participants) != 2 {
+		t.Errorf("Expected 2 participants, got %v", thread.Participants)
+	}
+	if thread.LatestMessage == nil || thread.LatestMessage.ID != "reply@example.com" {
+		t.Errorf("Expected latest message to be the reply, got %+v", thread.LatestMessage)
+	}
+
+	if len(thread.Roots) != 1 || len(thread.Roots[0].Replies) != 1 {
+		t.Fatalf("Expected one root with one reply, got %d roots", len(thread.Roots))
+	}
+
+	root := thread.Messages[0]
+	if root.Body != "Shall we grab lunch?\n" {
+		t.Errorf("Expected text/plain alternative as body, got %q", root.Body)
+	}
+	if len(root.To) != 2 || root.To[1] != `"Doe, Carol" <carol@example.com>` {
+		t.Errorf("Expected quoted address to stay intact, got %v", root.To)
+	}
+	if root.Unread || root.Starred {
+		t.Error("Expected root message to be read and not starred")
+	}
+
+	reply := thread.Messages[1]
+	if reply.ParentID != root.ID || reply.Depth != 1 {
+		t.Errorf("Expected reply to hang under root, got parent '%s' depth %d", reply.ParentID, reply.Depth)
+	}
+	if !reply.Unread || !reply.Starred {
+		t.Error("Expected reply to be unread and starred")
+	}
+	if root.Excluded || !reply.Excluded {
+		t.Error("Expected only the reply to be marked excluded")
+	}
+	if len(reply.Filenames) != 1 || reply.Filenames[0] != "/home/user/Mail/INBOX/cur/2:2," {
+		t.Errorf("Expected legacy string filename to be parsed, got %v", reply.Filenames)
+	}
+	if len(reply.Cc) != 1 {
+		t.Errorf("Expected 1 Cc recipient, got %v", reply.Cc)
+	}
+	if reply.Body != "Sure, see menu.\n" {
+		t.Errorf("Expected attachment to be excluded from body, got %q", reply.Body)
+	}
+
+	attachment := reply.Parts[0].Parts[1]
+	if !attachment.IsAttachment() || attachment.Filename != "menu.pdf" || attachment.Size != 2048 {
+		t.Errorf("Expected menu.pdf attachment, got %+v", attachment)
+	}
+}
+
+func TestParseNotmuchThreadEmpty(t *testing.T) {
+	m := NewManager("", "notmuch", "mbsync", "msmtp")
+
+	if _, err := m.parseNotmuchThread("abc", []byte("[]")); err == nil {
+		t.Error("Expected error for empty thread set")
+	}
+	if _, err := m.parseNotmuchThread("abc", []byte("not json")); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
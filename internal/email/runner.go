@@ -0,0 +1,211 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Timeouts bounds how long each kind of external tool operation may run.
+// A zero duration leaves the operation bounded by its context only.
+type Timeouts struct {
+	Query time.Duration // notmuch search, show and count
+	Tag   time.Duration // notmuch tag
+	Sync  time.Duration // mailbox synchronization
+}
+
+// DefaultTimeouts returns the timeouts used when none are configured
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Query: 30 * time.Second,
+		Tag:   15 * time.Second,
+		Sync:  10 * time.Minute,
+	}
+}
+
+// Command describes a single run of an external tool
+type Command struct {
+	Path    string
+	Args    []string
+	Stdin   io.Reader     // Optional standard input
+	Env     []string      // Extra environment variables, as KEY=value
+	Timeout time.Duration // Zero means no timeout beyond the context
+}
+
+// Runner executes external tools. Every run is bound to a context, gets its
+// operation's timeout and has stderr captured into a ToolError on failure.
+type Runner struct {
+	Timeouts Timeouts
+}
+
+// NewRunner creates a new runner with the given timeouts
+func NewRunner(timeouts Timeouts) *Runner {
+	return &Runner{Timeouts: timeouts}
+}
+
+// ToolError is returned when an external tool fails, times out or is cancelled
+type ToolError struct {
+	Tool     string   // Base name of the binary
+	Args     []string // Arguments the tool was run with
+	Stderr   string   // Trimmed standard error output
+	ExitCode int      // Exit code, or -1 if the tool did not exit on its own
+	Err      error    // Underlying error; the context error on timeout or cancel
+	Kind     error    // One of the Err* sentinels when the failure was recognised
+}
+
+// Error describes the failure with the tool's own message when there is one
+func (e *ToolError) Error() string {
+	name := e.Tool
+	if len(e.Args) > 0 {
+		name += " " + e.Args[0]
+	}
+
+	var msg string
+	switch {
+	case e.Kind == ErrToolNotFound:
+		return fmt.Sprintf("%s not found: %v", e.Tool, e.Err)
+	case e.TimedOut():
+		msg = name + " timed out"
+	case e.Canceled():
+		msg = name + " was cancelled"
+	default:
+		msg = fmt.Sprintf("%s failed: %v", name, e.Err)
+	}
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error and the recognised kind, so both
+// errors.Is(err, context.Canceled) and errors.Is(err, ErrDatabaseLocked) work
+func (e *ToolError) Unwrap() []error {
+	if e.Kind != nil {
+		return []error{e.Err, e.Kind}
+	}
+	return []error{e.Err}
+}
+
+// TimedOut reports whether the tool was stopped by its timeout
+func (e *ToolError) TimedOut() bool {
+	return errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// Canceled reports whether the tool was stopped because its caller gave up
+func (e *ToolError) Canceled() bool {
+	return errors.Is(e.Err, context.Canceled)
+}
+
+// Run executes a command and returns its standard output
+func (r *Runner) Run(ctx context.Context, c Command) ([]byte, error) {
+	ctx, cancel := withTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := newCmd(ctx, c)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), newToolError(ctx, c, err, &stderr)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Process is a running command whose input and output are streamed
+type Process struct {
+	Stdin  io.WriteCloser
+	Stdout io.Reader
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	cmd    *exec.Cmd
+	c      Command
+	stderr bytes.Buffer
+}
+
+// Start starts a command with pipes to its standard input and output.
+// Wait must be called to release its resources.
+func (r *Runner) Start(ctx context.Context, c Command) (*Process, error) {
+	ctx, cancel := withTimeout(ctx, c.Timeout)
+
+	p := &Process{ctx: ctx, cancel: cancel, c: c}
+	p.cmd = newCmd(ctx, c)
+	p.cmd.Stderr = &p.stderr
+
+	var err error
+	if p.Stdin, err = p.cmd.StdinPipe(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open %s stdin: %w", filepath.Base(c.Path), err)
+	}
+	if p.Stdout, err = p.cmd.StdoutPipe(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open %s stdout: %w", filepath.Base(c.Path), err)
+	}
+	if err := p.cmd.Start(); err != nil {
+		cancel()
+		return nil, newToolError(ctx, c, err, &p.stderr)
+	}
+	return p, nil
+}
+
+// Wait waits for the process to exit, once its output has been read
+func (p *Process) Wait() error {
+	defer p.cancel()
+	if err := p.cmd.Wait(); err != nil {
+		return newToolError(p.ctx, p.c, err, &p.stderr)
+	}
+	return nil
+}
+
+// withTimeout derives a context bounded by the timeout, if any
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// newCmd builds the exec.Cmd for a command, killed when ctx is done
+func newCmd(ctx context.Context, c Command) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
+	cmd.Stdin = c.Stdin
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	// Don't wait forever on pipes held open by grandchildren after a kill
+	cmd.WaitDelay = 2 * time.Second
+	return cmd
+}
+
+// newToolError wraps a failed run, preferring the context error when the
+// tool was killed because of a timeout or cancellation
+func newToolError(ctx context.Context, c Command, err error, stderr *bytes.Buffer) *ToolError {
+	toolErr := &ToolError{
+		Tool:     filepath.Base(c.Path),
+		Args:     c.Args,
+		Stderr:   strings.TrimSpace(stderr.String()),
+		ExitCode: -1,
+		Err:      err,
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		toolErr.ExitCode = exitErr.ExitCode()
+	}
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		toolErr.Kind = ErrToolNotFound
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		toolErr.Err = ctxErr
+	}
+	return toolErr
+}
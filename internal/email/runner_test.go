@@ -0,0 +1,94 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shell returns a command running a POSIX shell script
+func shell(t *testing.T, script string, timeout time.Duration) Command {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("runner tests need a POSIX shell")
+	}
+	return Command{Path: "/bin/sh", Args: []string{"-c", script}, Timeout: timeout}
+}
+
+func TestRunnerCapturesStderr(t *testing.T) {
+	runner := NewRunner(DefaultTimeouts())
+
+	output, err := runner.Run(context.Background(), shell(t, "echo out; echo 'database is locked' >&2; exit 3", 0))
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) {
+		t.Fatalf("Expected a ToolError, got %v", err)
+	}
+	if toolErr.ExitCode != 3 || toolErr.Stderr != "database is locked" || toolErr.Tool != "sh" {
+		t.Errorf("Unexpected tool error %+v", toolErr)
+	}
+	if !strings.Contains(err.Error(), "database is locked") {
+		t.Errorf("Expected stderr in the error message, got %q", err.Error())
+	}
+	if string(output) != "out\n" {
+		t.Errorf("Expected stdout to be returned on failure, got %q", output)
+	}
+}
+
+func TestRunnerTimeout(t *testing.T) {
+	runner := NewRunner(DefaultTimeouts())
+
+	start := time.Now()
+	_, err := runner.Run(context.Background(), shell(t, "exec sleep 10", 50*time.Millisecond))
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || !toolErr.TimedOut() {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected the error to wrap context.DeadlineExceeded")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Expected the tool to be killed promptly")
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	runner := NewRunner(DefaultTimeouts())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := runner.Run(ctx, shell(t, "exec sleep 10", 0))
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || !toolErr.Canceled() {
+		t.Fatalf("Expected a cancellation, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "was cancelled") {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+}
+
+func TestRunnerStartStreams(t *testing.T) {
+	runner := NewRunner(DefaultTimeouts())
+
+	process, err := runner.Start(context.Background(), shell(t, "tr a-z A-Z", 0))
+	if err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	process.Stdin.Write([]byte("hello\n"))
+	process.Stdin.Close()
+
+	buf := make([]byte, 16)
+	n, _ := process.Stdout.Read(buf)
+	if string(buf[:n]) != "HELLO\n" {
+		t.Errorf("Expected streamed output, got %q", buf[:n])
+	}
+	if err := process.Wait(); err != nil {
+		t.Errorf("Wait() failed: %v", err)
+	}
+}
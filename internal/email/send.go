@@ -0,0 +1,163 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Exit codes from sysexits.h that msmtp and sendmail use for failures that
+// may go away on their own, such as a network outage
+var temporarySendExitCodes = map[int]bool{
+	68: true, // EX_NOHOST
+	69: true, // EX_UNAVAILABLE
+	74: true, // EX_IOERR
+	75: true, // EX_TEMPFAIL
+}
+
+// SendTimeout bounds a single delivery to the mail transfer agent
+const SendTimeout = 2 * time.Minute
+
+// SendOptions controls how a message is handed to the mail transfer agent
+type SendOptions struct {
+	// Account is the msmtp account to send from; empty uses msmtp's default.
+	// It is ignored by sendmail-compatible commands.
+	Account string
+}
+
+// SendError is returned when the mail transfer agent rejects a message
+type SendError struct {
+	Command  string // Base name of the command that failed
+	Account  string // msmtp account, if any
+	ExitCode int    // Exit status, or -1 if the command did not exit on its own
+	Stderr   string // Trimmed standard error output
+	Err      error  // Underlying ToolError
+}
+
+// Error describes the failure with the command's own message
+func (e *SendError) Error() string {
+	msg := "failed to send message"
+	if e.Account != "" {
+		msg += " from account " + e.Account
+	}
+	if e.ExitCode >= 0 {
+		msg += fmt.Sprintf(" (%s exited with status %d)", e.Command, e.ExitCode)
+	}
+	if e.Stderr != "" {
+		return msg + ": " + e.Stderr
+	}
+	return fmt.Sprintf("%s: %v", msg, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether sending again later may succeed
+func (e *SendError) Temporary() bool {
+	var toolErr *ToolError
+	if errors.As(e.Err, &toolErr) && toolErr.TimedOut() {
+		return true
+	}
+	return temporarySendExitCodes[e.ExitCode]
+}
+
+// Sender hands fully formed RFC 5322 messages to msmtp or to a
+// sendmail-compatible command, which take the recipients from the headers
+type Sender struct {
+	msmtpPath string
+	sendmail  []string // Command line used instead of msmtp when set
+	runner    *Runner
+}
+
+// NewSender creates a sender. When sendmailCommand is not empty it is used
+// instead of msmtp; it must read the message on stdin and take recipients
+// from its headers, e.g. "sendmail -t -oi".
+func NewSender(msmtpPath, sendmailCommand string) *Sender {
+	return &Sender{
+		msmtpPath: msmtpPath,
+		sendmail:  strings.Fields(sendmailCommand),
+		runner:    NewRunner(DefaultTimeouts()),
+	}
+}
+
+// Send delivers a message. Bcc headers are stripped by the transfer agent.
+func (s *Sender) Send(ctx context.Context, message []byte, opts SendOptions) error {
+	if err := checkSendable(message); err != nil {
+		return err
+	}
+
+	cmd := Command{Stdin: bytes.NewReader(message), Timeout: SendTimeout}
+	if len(s.sendmail) > 0 {
+		cmd.Path = s.sendmail[0]
+		cmd.Args = s.sendmail[1:]
+	} else {
+		cmd.Path = s.msmtpPath
+		if opts.Account != "" {
+			cmd.Args = append(cmd.Args, "-a", opts.Account)
+		}
+		// Take the envelope sender and the recipients from the headers
+		cmd.Args = append(cmd.Args, "--read-envelope-from", "--read-recipients")
+	}
+
+	if _, err := s.runner.Run(ctx, cmd); err != nil {
+		sendErr := &SendError{Account: opts.Account, ExitCode: -1, Err: err}
+		var toolErr *ToolError
+		if errors.As(err, &toolErr) {
+			sendErr.Command = toolErr.Tool
+			sendErr.ExitCode = toolErr.ExitCode
+			sendErr.Stderr = toolErr.Stderr
+		}
+		return sendErr
+	}
+	return nil
+}
+
+// checkSendable makes sure a message has a sender and at least one recipient
+// before it is handed over, so mistakes are reported before anything runs
+func checkSendable(message []byte) error {
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("failed to parse outgoing message: %w", err)
+	}
+
+	if msg.Header.Get("From") == "" {
+		return fmt.Errorf("outgoing message has no From header")
+	}
+	for _, field := range []string{"To", "Cc", "Bcc"} {
+		if strings.TrimSpace(msg.Header.Get(field)) != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("outgoing message has no recipients")
+}
+
+// SenderAddress returns the bare address of the From header of a message,
+// to tell which account it belongs to
+func SenderAddress(message []byte) (string, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse message: %w", err)
+	}
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return "", fmt.Errorf("invalid From header: %w", err)
+	}
+	return from.Address, nil
+}
+
+// SetSender replaces the sender used by SendMessage, for instance with a
+// configured sendmail-compatible command
+func (m *Manager) SetSender(sender *Sender) {
+	m.sender = sender
+}
+
+// SendMessage sends a fully formed message through msmtp or the configured sender
+func (m *Manager) SendMessage(ctx context.Context, message []byte, opts SendOptions) error {
+	return m.sender.Send(ctx, message, opts)
+}
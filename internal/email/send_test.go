@@ -0,0 +1,99 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const outgoing = "From: Bob <bob@example.com>\r\nTo: alice@example.com\r\nSubject: Hi\r\n\r\nHello\r\n"
+
+// fakeMTA writes a transfer agent stand-in that saves its arguments and the
+// message it reads, then exits with the given status and stderr
+func fakeMTA(t *testing.T, exitCode int, stderr string) (path, dir string) {
+	t.Helper()
+	notmuch, _ := fakeNotmuch(t) // Skips on platforms without a shell
+	dir = filepath.Dir(notmuch)
+	path = filepath.Join(dir, "msmtp")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" > \"" + dir + "/args\"\n" +
+		"cat > \"" + dir + "/message\"\n" +
+		"echo '" + stderr + "' >&2\n" +
+		"exit " + strconv.Itoa(exitCode) + "\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path, dir
+}
+
+func TestSendThroughMsmtp(t *testing.T) {
+	msmtp, dir := fakeMTA(t, 0, "")
+	manager := NewManager(t.TempDir(), "notmuch", "mbsync", msmtp)
+
+	if err := manager.SendMessage(context.Background(), []byte(outgoing), SendOptions{Account: "work"}); err != nil {
+		t.Fatalf("SendMessage() failed: %v", err)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if strings.TrimSpace(string(args)) != "-a work --read-envelope-from --read-recipients" {
+		t.Errorf("Unexpected msmtp arguments %q", args)
+	}
+	message, _ := os.ReadFile(filepath.Join(dir, "message"))
+	if string(message) != outgoing {
+		t.Errorf("Expected the message on stdin, got %q", message)
+	}
+}
+
+func TestSendThroughSendmailCommand(t *testing.T) {
+	sendmail, dir := fakeMTA(t, 0, "")
+	sender := NewSender("msmtp", sendmail+" -t -oi")
+
+	if err := sender.Send(context.Background(), []byte(outgoing), SendOptions{Account: "ignored"}); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if strings.TrimSpace(string(args)) != "-t -oi" {
+		t.Errorf("Unexpected sendmail arguments %q", args)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	msmtp, _ := fakeMTA(t, 75, "msmtp: cannot connect to smtp.example.com")
+	sender := NewSender(msmtp, "")
+
+	err := sender.Send(context.Background(), []byte(outgoing), SendOptions{Account: "work"})
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) {
+		t.Fatalf("Expected a SendError, got %v", err)
+	}
+	if sendErr.ExitCode != 75 || !sendErr.Temporary() || sendErr.Stderr != "msmtp: cannot connect to smtp.example.com" {
+		t.Errorf("Unexpected send error %+v", sendErr)
+	}
+	if err.Error() != "failed to send message from account work (msmtp exited with status 75): msmtp: cannot connect to smtp.example.com" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+
+	msmtp, _ = fakeMTA(t, 77, "msmtp: authentication failed")
+	err = NewSender(msmtp, "").Send(context.Background(), []byte(outgoing), SendOptions{})
+	if !errors.As(err, &sendErr) || sendErr.Temporary() {
+		t.Errorf("Expected a permanent failure, got %v", err)
+	}
+
+	if err := sender.Send(context.Background(), []byte("From: a@example.com\r\n\r\nbody"), SendOptions{}); err == nil {
+		t.Error("Expected an error for a message without recipients")
+	}
+}
+
+func TestSenderAddress(t *testing.T) {
+	address, err := SenderAddress([]byte(outgoing))
+	if err != nil || address != "bob@example.com" {
+		t.Errorf("Expected bob@example.com, got %q (%v)", address, err)
+	}
+	if _, err := SenderAddress([]byte("To: alice@example.com\r\n\r\nbody")); err == nil {
+		t.Error("Expected an error for a message without sender")
+	}
+}
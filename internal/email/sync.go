@@ -0,0 +1,217 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSyncRunning is returned when a sync is requested while the account is
+// already syncing
+var ErrSyncRunning = errors.New("a sync is already running")
+
+// SyncState is the state of a sync of a whole account or of one folder
+type SyncState struct {
+	Running     bool
+	LastSuccess time.Time // Zero until a sync succeeded
+	LastError   error     // Error of the last sync, nil once one succeeds
+}
+
+// IndexFunc makes newly synced mail visible, such as by running notmuch new
+type IndexFunc func(ctx context.Context) error
+
+// SyncStage is a step of the sync pipeline
+type SyncStage int
+
+const (
+	// StagePreSync runs the pre-sync hook
+	StagePreSync SyncStage = iota
+
+	// StageSync fetches new mail with the sync driver
+	StageSync
+
+	// StageIndex indexes the new mail, such as with notmuch new
+	StageIndex
+
+	// StagePostNew runs the post-new hook, such as afew tagging the new mail
+	StagePostNew
+)
+
+// String describes a stage for the status bar
+func (s SyncStage) String() string {
+	switch s {
+	case StagePreSync:
+		return "pre-sync hook"
+	case StageSync:
+		return "syncing"
+	case StageIndex:
+		return "indexing"
+	case StagePostNew:
+		return "post-new hook"
+	}
+	return "unknown"
+}
+
+// ProgressFunc is told each stage of a sync as it starts
+type ProgressFunc func(stage SyncStage)
+
+// SyncHooks are shell commands run around a sync. Both run with sh -c and
+// Env added to their environment, along with MEL_FOLDER naming the folder
+// synced, empty for the whole account.
+type SyncHooks struct {
+	PreSync string   // Run before syncing; the sync is skipped if it fails
+	PostNew string   // Run once the new mail is indexed
+	Env     []string // KEY=value variables describing the account
+}
+
+// Syncer fetches new mail for one account with its sync driver, then
+// indexes it. Only one sync of the account runs at a time; the state of the
+// account and of every folder synced so far is kept for display. It is safe
+// for concurrent use.
+type Syncer struct {
+	driver SyncDriver
+	index  IndexFunc
+	hooks  SyncHooks
+	runner *Runner
+
+	mu      sync.Mutex
+	running bool
+	states  map[string]*SyncState // By folder name, "" for the whole account
+}
+
+// NewSyncer creates a syncer running a sync driver. index runs after each
+// successful sync and may be nil when the backend reads the maildir directly.
+func NewSyncer(driver SyncDriver, index IndexFunc) *Syncer {
+	return &Syncer{
+		driver: driver,
+		index:  index,
+		runner: NewRunner(DefaultTimeouts()),
+		states: make(map[string]*SyncState),
+	}
+}
+
+// SetHooks sets the shell commands run before syncing and after indexing
+func (s *Syncer) SetHooks(hooks SyncHooks) {
+	s.hooks = hooks
+}
+
+// SyncsFolders reports whether a single folder can be synced, rather than
+// only the whole account
+func (s *Syncer) SyncsFolders() bool {
+	return s.driver.SyncsFolders()
+}
+
+// Sync syncs the whole account, or only one folder with a folder name, as a
+// pipeline: pre-sync hook, sync, index, post-new hook. Stages without a hook
+// or index are skipped. progress, which may be nil, is told each stage.
+func (s *Syncer) Sync(ctx context.Context, folderName string, progress ProgressFunc) error {
+	cmd, err := s.driver.Command(folderName)
+	if err != nil {
+		return err
+	}
+	if !s.start(folderName) {
+		return ErrSyncRunning
+	}
+
+	if progress == nil {
+		progress = func(SyncStage) {}
+	}
+	err = s.run(ctx, cmd, folderName, progress)
+	s.finish(folderName, err)
+	return err
+}
+
+// run goes through the stages of the pipeline, stopping at the first failure
+func (s *Syncer) run(ctx context.Context, cmd Command, folderName string, progress ProgressFunc) error {
+	if s.hooks.PreSync != "" {
+		progress(StagePreSync)
+		if err := s.runHook(ctx, s.hooks.PreSync, folderName); err != nil {
+			return fmt.Errorf("failed to run the pre-sync hook: %w", err)
+		}
+	}
+
+	progress(StageSync)
+	cmd.Timeout = s.runner.Timeouts.Sync
+	if _, err := s.runner.Run(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to sync emails: %w", classifySyncError(err))
+	}
+
+	if s.index != nil {
+		progress(StageIndex)
+		if err := s.index(ctx); err != nil {
+			return err
+		}
+	}
+
+	if s.hooks.PostNew != "" {
+		progress(StagePostNew)
+		if err := s.runHook(ctx, s.hooks.PostNew, folderName); err != nil {
+			return fmt.Errorf("failed to run the post-new hook: %w", err)
+		}
+	}
+	return nil
+}
+
+// runHook runs a hook command with the shell
+func (s *Syncer) runHook(ctx context.Context, command, folderName string) error {
+	_, err := s.runner.Run(ctx, Command{
+		Path:    "sh",
+		Args:    []string{"-c", command},
+		Env:     append(append([]string{}, s.hooks.Env...), "MEL_FOLDER="+folderName),
+		Timeout: s.runner.Timeouts.Sync,
+	})
+	return err
+}
+
+// State returns the state of the account, or of one of its folders
+func (s *Syncer) State(folderName string) SyncState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.states[folderName]; ok {
+		return *state
+	}
+	return SyncState{}
+}
+
+// start marks a folder, or the account, as syncing unless a sync is running
+// already
+func (s *Syncer) start(folderName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return false
+	}
+	s.running = true
+	s.state(folderName).Running = true
+	return true
+}
+
+// finish records the outcome of a sync
+func (s *Syncer) finish(folderName string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = false
+	state := s.state(folderName)
+	state.Running = false
+	if errors.Is(err, context.Canceled) {
+		// A cancelled sync tells nothing about the account
+		return
+	}
+	state.LastError = err
+	if err == nil {
+		state.LastSuccess = time.Now()
+	}
+}
+
+// state returns the state of a folder, creating it if needed; s.mu must be
+// held
+func (s *Syncer) state(folderName string) *SyncState {
+	state, ok := s.states[folderName]
+	if !ok {
+		state = &SyncState{}
+		s.states[folderName] = state
+	}
+	return state
+}
@@ -0,0 +1,172 @@
+package email
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// SyncDriver builds the command fetching an account's mail with one sync
+// tool
+type SyncDriver interface {
+	// Command returns the command syncing the whole account, or only one
+	// folder with a folder name
+	Command(folderName string) (Command, error)
+
+	// SyncsFolders reports whether a single folder can be synced
+	SyncsFolders() bool
+}
+
+// MbsyncDriver syncs with mbsync: "mbsync channel" or "mbsync channel:folder"
+type MbsyncDriver struct {
+	Path    string
+	Channel string // Channel or group; empty syncs every channel with -a
+}
+
+// Command returns the mbsync command for the channel or one of its folders
+func (d MbsyncDriver) Command(folderName string) (Command, error) {
+	switch {
+	case d.Channel == "" && folderName != "":
+		return Command{}, fmt.Errorf("syncing a single folder needs an mbsync channel for the account")
+	case d.Channel == "":
+		return Command{Path: d.Path, Args: []string{"-a"}}, nil
+	case folderName == "":
+		return Command{Path: d.Path, Args: []string{d.Channel}}, nil
+	default:
+		return Command{Path: d.Path, Args: []string{d.Channel + ":" + folderName}}, nil
+	}
+}
+
+// SyncsFolders reports whether a channel is set, which folders are synced
+// through
+func (d MbsyncDriver) SyncsFolders() bool {
+	return d.Channel != ""
+}
+
+// OfflineimapDriver syncs with offlineimap, once rather than in its own
+// refresh loop
+type OfflineimapDriver struct {
+	Path    string
+	Account string // offlineimap account; empty syncs every account
+}
+
+// Command returns the offlineimap command for the account or one of its
+// folders
+func (d OfflineimapDriver) Command(folderName string) (Command, error) {
+	args := []string{"-o"}
+	if d.Account != "" {
+		args = append(args, "-a", d.Account)
+	}
+	if folderName != "" {
+		args = append(args, "-f", folderName)
+	}
+	return Command{Path: d.Path, Args: args}, nil
+}
+
+// SyncsFolders reports that offlineimap syncs single folders with -f
+func (d OfflineimapDriver) SyncsFolders() bool {
+	return true
+}
+
+// SyncVars are the values a custom sync command can use
+type SyncVars struct {
+	Account string // Account name
+	Maildir string // Root of the account's maildir
+	Folder  string // Folder to sync; empty for the whole account
+}
+
+// CommandDriver syncs with a custom command, such as imapsync or lieer's
+// gmi. Each argument is a text/template template over SyncVars, and
+// arguments rendering empty are left out, so "{{if .Folder}}-f={{.Folder}}{{end}}"
+// only applies to single folder syncs.
+type CommandDriver struct {
+	args    []*template.Template
+	vars    SyncVars
+	folders bool
+}
+
+// NewCommandDriver parses a custom sync command for an account
+func NewCommandDriver(command, account, maildir string) (*CommandDriver, error) {
+	fields := splitTemplateFields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("sync command is empty")
+	}
+
+	d := &CommandDriver{
+		vars:    SyncVars{Account: account, Maildir: maildir},
+		folders: strings.Contains(command, ".Folder"),
+	}
+	for _, field := range fields {
+		arg, err := template.New("sync").Parse(field)
+		if err == nil {
+			// Catch unknown variables now rather than on the first sync
+			err = arg.Execute(io.Discard, d.vars)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid sync command: %w", err)
+		}
+		d.args = append(d.args, arg)
+	}
+	return d, nil
+}
+
+// Command renders the custom command for the account or one of its folders
+func (d *CommandDriver) Command(folderName string) (Command, error) {
+	if folderName != "" && !d.folders {
+		return Command{}, fmt.Errorf("the sync command does not use {{.Folder}} to sync a single folder")
+	}
+
+	vars := d.vars
+	vars.Folder = folderName
+	var args []string
+	for _, arg := range d.args {
+		var rendered strings.Builder
+		if err := arg.Execute(&rendered, vars); err != nil {
+			return Command{}, fmt.Errorf("failed to render sync command: %w", err)
+		}
+		if rendered.Len() > 0 {
+			args = append(args, rendered.String())
+		}
+	}
+	if len(args) == 0 {
+		return Command{}, fmt.Errorf("sync command is empty")
+	}
+	return Command{Path: args[0], Args: args[1:]}, nil
+}
+
+// SyncsFolders reports whether the command uses the folder name
+func (d *CommandDriver) SyncsFolders() bool {
+	return d.folders
+}
+
+// splitTemplateFields splits a command line on spaces outside of template
+// actions, so an action such as "{{if .Folder}}" stays in one argument
+func splitTemplateFields(command string) []string {
+	var fields []string
+	var field strings.Builder
+	depth := 0
+	for i := 0; i < len(command); i++ {
+		switch {
+		case strings.HasPrefix(command[i:], "{{"):
+			depth++
+			field.WriteString("{{")
+			i++
+		case strings.HasPrefix(command[i:], "}}") && depth > 0:
+			depth--
+			field.WriteString("}}")
+			i++
+		case depth == 0 && (command[i] == ' ' || command[i] == '\t' || command[i] == '\n'):
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteByte(command[i])
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
@@ -0,0 +1,191 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeMbsync writes an mbsync stand-in that appends its arguments to a log
+// file and fails when the first one is "broken" or "offline"
+func fakeMbsync(t *testing.T) (path, log string) {
+	t.Helper()
+	notmuch, _ := fakeNotmuch(t) // Skips on platforms without a shell
+	dir := filepath.Dir(notmuch)
+	path = filepath.Join(dir, "mbsync")
+	log = filepath.Join(dir, "mbsync.log")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" >> \"" + log + "\"\n" +
+		"if [ \"$1\" = broken ]; then echo 'IMAP error: connection refused' >&2; exit 1; fi\n" +
+		"if [ \"$1\" = offline ]; then echo \"IMAP error: Cannot resolve server 'imap.example.com': Temporary failure in name resolution\" >&2; exit 1; fi\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path, log
+}
+
+func TestSyncTargets(t *testing.T) {
+	mbsync, log := fakeMbsync(t)
+	ctx := context.Background()
+
+	indexed := 0
+	index := func(context.Context) error { indexed++; return nil }
+	syncer := NewSyncer(MbsyncDriver{Path: mbsync, Channel: "work"}, index)
+	if err := syncer.Sync(ctx, "", nil); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := syncer.Sync(ctx, "INBOX", nil); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := NewSyncer(MbsyncDriver{Path: mbsync}, nil).Sync(ctx, "", nil); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(log)
+	if got := strings.Fields(string(data)); strings.Join(got, " ") != "work work:INBOX -a" {
+		t.Errorf("Unexpected mbsync runs %q", got)
+	}
+	if indexed != 2 {
+		t.Errorf("Expected the index to run after each sync, got %d", indexed)
+	}
+	if state := syncer.State("INBOX"); state.Running || state.LastSuccess.IsZero() || state.LastError != nil {
+		t.Errorf("Expected a successful folder sync, got %+v", state)
+	}
+	if state := syncer.State("Sent"); !state.LastSuccess.IsZero() {
+		t.Errorf("Expected no state for a folder never synced, got %+v", state)
+	}
+
+	if err := NewSyncer(MbsyncDriver{Path: mbsync}, nil).Sync(ctx, "INBOX", nil); err == nil {
+		t.Error("Expected an error syncing a folder without a channel")
+	}
+}
+
+func TestSyncFailure(t *testing.T) {
+	mbsync, _ := fakeMbsync(t)
+	indexed := false
+	syncer := NewSyncer(MbsyncDriver{Path: mbsync, Channel: "broken"}, func(context.Context) error { indexed = true; return nil })
+
+	err := syncer.Sync(context.Background(), "", nil)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("Expected the mbsync error, got %v", err)
+	}
+	if indexed {
+		t.Error("Expected no indexing after a failed sync")
+	}
+	if state := syncer.State(""); state.Running || !errors.Is(state.LastError, err) || !state.LastSuccess.IsZero() {
+		t.Errorf("Expected the failure recorded, got %+v", state)
+	}
+
+	if errors.Is(err, ErrOffline) {
+		t.Errorf("Expected a server error, not ErrOffline: %v", err)
+	}
+	offline := NewSyncer(MbsyncDriver{Path: mbsync, Channel: "offline"}, nil).Sync(context.Background(), "", nil)
+	if !errors.Is(offline, ErrOffline) || Hint(offline) == "" {
+		t.Errorf("Expected ErrOffline with a hint, got %v", offline)
+	}
+
+	// Only one sync of an account runs at a time
+	syncer.start("")
+	if err := syncer.Sync(context.Background(), "INBOX", nil); !errors.Is(err, ErrSyncRunning) {
+		t.Errorf("Expected ErrSyncRunning, got %v", err)
+	}
+}
+
+func TestSyncHooks(t *testing.T) {
+	mbsync, log := fakeMbsync(t)
+	index := func(context.Context) error { return appendLine(log, "index") }
+	syncer := NewSyncer(MbsyncDriver{Path: mbsync, Channel: "work"}, index)
+	syncer.SetHooks(SyncHooks{
+		PreSync: `echo "pre $MEL_ACCOUNT $MEL_FOLDER" >> "` + log + `"`,
+		PostNew: `echo "post $MEL_ACCOUNT" >> "` + log + `"`,
+		Env:     []string{"MEL_ACCOUNT=work"},
+	})
+
+	var stages []string
+	progress := func(stage SyncStage) { stages = append(stages, stage.String()) }
+	if err := syncer.Sync(context.Background(), "INBOX", progress); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(log)
+	if got := strings.TrimSpace(string(data)); got != "pre work INBOX\nwork:INBOX\nindex\npost work" {
+		t.Errorf("Expected the hooks around the sync and index, got %q", got)
+	}
+	if got := strings.Join(stages, ", "); got != "pre-sync hook, syncing, indexing, post-new hook" {
+		t.Errorf("Unexpected stages %q", got)
+	}
+
+	// A failing pre-sync hook skips the sync
+	os.Remove(log)
+	syncer.SetHooks(SyncHooks{PreSync: "echo 'vpn is down' >&2; exit 1"})
+	err := syncer.Sync(context.Background(), "", nil)
+	if err == nil || !strings.Contains(err.Error(), "vpn is down") {
+		t.Errorf("Expected the pre-sync hook error, got %v", err)
+	}
+	if _, err := os.Stat(log); err == nil {
+		t.Error("Expected no sync after a failed pre-sync hook")
+	}
+}
+
+// appendLine appends a line to a file
+func appendLine(path, line string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(line + "\n")
+	return err
+}
+
+func TestSyncDrivers(t *testing.T) {
+	gmi, err := NewCommandDriver("gmi sync -C {{.Maildir}}", "home", "/home/jane/Mail/home")
+	if err != nil {
+		t.Fatalf("NewCommandDriver failed: %v", err)
+	}
+	imapsync, err := NewCommandDriver("imapsync --user1 {{.Account}} {{if .Folder}}--folder={{.Folder}}{{end}}", "work", "/m")
+	if err != nil {
+		t.Fatalf("NewCommandDriver failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		driver SyncDriver
+		folder string
+		want   string
+	}{
+		{"offlineimap", OfflineimapDriver{Path: "offlineimap", Account: "work"}, "", "offlineimap -o -a work"},
+		{"offlineimap folder", OfflineimapDriver{Path: "offlineimap", Account: "work"}, "INBOX", "offlineimap -o -a work -f INBOX"},
+		{"offlineimap every account", OfflineimapDriver{Path: "offlineimap"}, "", "offlineimap -o"},
+		{"mbsync folder", MbsyncDriver{Path: "mbsync", Channel: "work"}, "INBOX", "mbsync work:INBOX"},
+		{"gmi", gmi, "", "gmi sync -C /home/jane/Mail/home"},
+		{"imapsync", imapsync, "", "imapsync --user1 work"},
+		{"imapsync folder", imapsync, "Lists/go", "imapsync --user1 work --folder=Lists/go"},
+	}
+
+	for _, tt := range tests {
+		cmd, err := tt.driver.Command(tt.folder)
+		if err != nil {
+			t.Errorf("%s: Command failed: %v", tt.name, err)
+			continue
+		}
+		if got := strings.Join(append([]string{cmd.Path}, cmd.Args...), " "); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	if gmi.SyncsFolders() || !imapsync.SyncsFolders() {
+		t.Errorf("Expected only commands using the folder to sync single folders")
+	}
+	if _, err := gmi.Command("INBOX"); err == nil {
+		t.Error("Expected an error syncing a folder with a command not using it")
+	}
+	for _, command := range []string{"gmi {{.Account", "gmi -C {{.Mailbox}}", ""} {
+		if _, err := NewCommandDriver(command, "home", "/m"); err == nil {
+			t.Errorf("Expected an error for the sync command %q", command)
+		}
+	}
+}
@@ -11,15 +11,18 @@ const (
 // IconSet holds all the icons for a specific mode
 type IconSet struct {
 	// Email and communication
-	Email   string
-	Inbox   string
-	Sent    string
-	Drafts  string
-	Trash   string
-	Starred string
-	Archive string
-	Folder  string
-	Spam    string
+	Account   string
+	Email     string
+	Inbox     string
+	Sent      string
+	Drafts    string
+	Outbox    string
+	Scheduled string
+	Trash     string
+	Starred   string
+	Archive   string
+	Folder    string
+	Spam      string
 
 	// Actions
 	Compose  string
@@ -30,10 +33,12 @@ type IconSet struct {
 	Delete   string
 
 	// Status indicators
-	Unread string
-	Read   string
-	Star   string
-	Unstar string
+	Unread    string
+	Read      string
+	Star      string
+	Unstar    string
+	Syncing   string
+	SyncError string
 
 	// Navigation
 	Next     string
@@ -150,6 +155,8 @@ func (s *Service) SetCustomIcon(iconName, value string) {
 // setCustomIconValue sets a custom icon value
 func (s *Service) setCustomIconValue(iconSet *IconSet, iconName, value string) {
 	switch iconName {
+	case "account":
+		iconSet.Account = value
 	case "email":
 		iconSet.Email = value
 	case "inbox":
@@ -158,6 +165,10 @@ func (s *Service) setCustomIconValue(iconSet *IconSet, iconName, value string) {
 		iconSet.Sent = value
 	case "drafts":
 		iconSet.Drafts = value
+	case "outbox":
+		iconSet.Outbox = value
+	case "scheduled":
+		iconSet.Scheduled = value
 	case "trash":
 		iconSet.Trash = value
 	case "starred":
@@ -188,6 +199,10 @@ func (s *Service) setCustomIconValue(iconSet *IconSet, iconName, value string) {
 		iconSet.Star = value
 	case "unstar":
 		iconSet.Unstar = value
+	case "syncing":
+		iconSet.Syncing = value
+	case "syncError":
+		iconSet.SyncError = value
 	case "next":
 		iconSet.Next = value
 	case "previous":
@@ -218,6 +233,8 @@ func (s *Service) setCustomIconValue(iconSet *IconSet, iconName, value string) {
 // getIconValue retrieves an icon value from an icon set
 func (s *Service) getIconValue(iconSet *IconSet, iconName string) string {
 	switch iconName {
+	case "account":
+		return iconSet.Account
 	case "email":
 		return iconSet.Email
 	case "inbox":
@@ -226,6 +243,10 @@ func (s *Service) getIconValue(iconSet *IconSet, iconName string) string {
 		return iconSet.Sent
 	case "drafts":
 		return iconSet.Drafts
+	case "outbox":
+		return iconSet.Outbox
+	case "scheduled":
+		return iconSet.Scheduled
 	case "trash":
 		return iconSet.Trash
 	case "starred":
@@ -256,6 +277,10 @@ func (s *Service) getIconValue(iconSet *IconSet, iconName string) string {
 		return iconSet.Star
 	case "unstar":
 		return iconSet.Unstar
+	case "syncing":
+		return iconSet.Syncing
+	case "syncError":
+		return iconSet.SyncError
 	case "next":
 		return iconSet.Next
 	case "previous":
@@ -289,10 +314,13 @@ func (s *Service) getIconValue(iconSet *IconSet, iconName string) string {
 func createEmojiSet() *IconSet {
 	return &IconSet{
 		// Email and communication
+		Account:      "👤",
 		Email:        "📧",
 		Inbox:        "📥",
 		Sent:         "📤",
 		Drafts:       "📁",
+		Outbox:       "📮",
+		Scheduled:    "⏰",
 		Trash:        "🗑️",
 		Starred:      "⭐",
 		Archive:      "📦",
@@ -308,6 +336,8 @@ func createEmojiSet() *IconSet {
 		Read:         "○",
 		Star:         "⭐",
 		Unstar:       "☆",
+		Syncing:      "🔄",
+		SyncError:    "⚠️",
 		Next:         "▶",
 		Previous:     "◀",
 		Top:          "⬆️",
@@ -326,15 +356,18 @@ func createEmojiSet() *IconSet {
 // createASCIISet creates the ASCII icon set with Neotree-style icons
 func createASCIISet() *IconSet {
 	return &IconSet{
-		Email:   "📧",
-		Inbox:   "📁",
-		Sent:    "📤",
-		Drafts:  "📝",
-		Trash:   "🗑",
-		Starred: "⭐",
-		Archive: "📦",
-		Folder:  "📁",
-		Spam:    "🚫",
+		Account:   "👤",
+		Email:     "📧",
+		Inbox:     "📁",
+		Sent:      "📤",
+		Drafts:    "📝",
+		Outbox:    "📮",
+		Scheduled: "⏰",
+		Trash:     "🗑",
+		Starred:   "⭐",
+		Archive:   "📦",
+		Folder:    "📁",
+		Spam:      "🚫",
 
 		// Actions - using Neotree-style action icons
 		Compose:  "✏",
@@ -345,10 +378,12 @@ func createASCIISet() *IconSet {
 		Delete:   "✗",
 
 		// Status indicators - using Neotree-style status icons
-		Unread: "●",
-		Read:   "○",
-		Star:   "★",
-		Unstar: "☆",
+		Unread:    "●",
+		Read:      "○",
+		Star:      "★",
+		Unstar:    "☆",
+		Syncing:   "⟳",
+		SyncError: "!",
 
 		// Navigation - using Neotree-style navigation icons
 		Next:     "▶",
@@ -0,0 +1,100 @@
+package maildir
+
+import (
+	"sort"
+	"strings"
+)
+
+// Maildir info flags, see https://cr.yp.to/proto/maildir.html
+const (
+	FlagPassed  = 'P'
+	FlagReplied = 'R'
+	FlagSeen    = 'S'
+	FlagTrashed = 'T'
+	FlagDraft   = 'D'
+	FlagFlagged = 'F'
+)
+
+// infoSeparator separates the unique name from the flags in a cur/ filename
+const infoSeparator = ":2,"
+
+// parseFilename splits a maildir filename into its unique part and flags
+func parseFilename(name string) (base, flags string) {
+	idx := strings.LastIndex(name, infoSeparator)
+	if idx < 0 {
+		return name, ""
+	}
+	return name[:idx], name[idx+len(infoSeparator):]
+}
+
+// formatFilename builds a cur/ filename from a unique part and flags.
+// Flags are written in ASCII order as required by the specification.
+func formatFilename(base, flags string) string {
+	return base + infoSeparator + normalizeFlags(flags)
+}
+
+// normalizeFlags sorts and deduplicates flags
+func normalizeFlags(flags string) string {
+	seen := make(map[rune]bool)
+	var runes []rune
+	for _, r := range flags {
+		if !seen[r] {
+			seen[r] = true
+			runes = append(runes, r)
+		}
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return string(runes)
+}
+
+// hasFlag checks whether a flag is set
+func hasFlag(flags string, flag rune) bool {
+	return strings.ContainsRune(flags, flag)
+}
+
+// setFlag sets or clears a flag
+func setFlag(flags string, flag rune, on bool) string {
+	if on {
+		if hasFlag(flags, flag) {
+			return flags
+		}
+		return normalizeFlags(flags + string(flag))
+	}
+	return strings.ReplaceAll(flags, string(flag), "")
+}
+
+// tagFlags maps notmuch-style tags to the maildir flags that represent them.
+// The "unread" tag is the inverse of the Seen flag.
+var tagFlags = map[string]rune{
+	"flagged": FlagFlagged,
+	"starred": FlagFlagged,
+	"replied": FlagReplied,
+	"passed":  FlagPassed,
+	"draft":   FlagDraft,
+	"deleted": FlagTrashed,
+	"trashed": FlagTrashed,
+}
+
+// flagTags returns the tags represented by a message's flags
+func flagTags(flags string, inNew bool) []string {
+	var tags []string
+	if inNew || !hasFlag(flags, FlagSeen) {
+		tags = append(tags, "unread")
+	}
+	if hasFlag(flags, FlagFlagged) {
+		tags = append(tags, "flagged")
+	}
+	if hasFlag(flags, FlagReplied) {
+		tags = append(tags, "replied")
+	}
+	if hasFlag(flags, FlagPassed) {
+		tags = append(tags, "passed")
+	}
+	if hasFlag(flags, FlagDraft) {
+		tags = append(tags, "draft")
+	}
+	if hasFlag(flags, FlagTrashed) {
+		tags = append(tags, "deleted")
+	}
+	return tags
+}
@@ -0,0 +1,610 @@
+package maildir
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+	"github.com/romaintb/mel/internal/threading"
+)
+
+// Backend reads and writes a Maildir tree directly, without notmuch.
+// Read, starred and deleted state are stored in the maildir info flags,
+// so changes are picked up by mbsync on the next sync.
+type Backend struct {
+	root    string
+	folders *email.FolderDirs // Folder directories from the last listing
+
+	mu    sync.Mutex
+	cache map[string]*entry // Parsed messages by path
+}
+
+// Ensure Backend implements email.Backend
+var _ email.Backend = (*Backend)(nil)
+
+// New creates a new maildir backend rooted at the given directory
+func New(root string) *Backend {
+	return &Backend{
+		root:    root,
+		folders: email.NewFolderDirs(root),
+		cache:   make(map[string]*entry),
+	}
+}
+
+// GetMailFolders returns all folders with counts computed from the file flags
+func (b *Backend) GetMailFolders(ctx context.Context) ([]*email.MailFolder, error) {
+	return email.CollectFolders(b.StreamMailFolders(ctx))
+}
+
+// StreamMailFolders sends the folder list, then the counts of each folder
+func (b *Backend) StreamMailFolders(ctx context.Context) <-chan email.FolderUpdate {
+	updates := make(chan email.FolderUpdate)
+
+	go func() {
+		defer close(updates)
+
+		folders, err := email.ScanFolders(b.root)
+		if err != nil {
+			updates <- email.FolderUpdate{Err: err}
+			return
+		}
+		b.folders.Remember(folders)
+		updates <- email.FolderUpdate{Folders: folders}
+
+		for _, folder := range folders {
+			if ctx.Err() != nil {
+				updates <- email.FolderUpdate{Err: ctx.Err()}
+				return
+			}
+			if counts, err := countFolder(folder); err == nil {
+				updates <- email.FolderUpdate{Counts: counts}
+			}
+		}
+	}()
+
+	return updates
+}
+
+// countFolder counts the messages of a folder from the file flags
+func countFolder(folder *email.MailFolder) (*email.FolderCounts, error) {
+	names, err := listMessages(folder.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := &email.FolderCounts{Name: folder.Name}
+	for _, path := range names {
+		_, flags := parseFilename(filepath.Base(path))
+		if hasFlag(flags, FlagTrashed) {
+			continue
+		}
+		counts.Total++
+		if isNewPath(path) || !hasFlag(flags, FlagSeen) {
+			counts.Unread++
+		}
+	}
+	return counts, nil
+}
+
+// GetThreadsFromFolder returns the threads with at least one message in a folder
+func (b *Backend) GetThreadsFromFolder(ctx context.Context, folderName string) ([]*email.Thread, error) {
+	result, err := b.SearchEmails(ctx, fmt.Sprintf("folder:%q", folderName), email.SearchOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list threads in folder %s: %w", folderName, err)
+	}
+	return result.Threads, nil
+}
+
+// GetThread retrieves a thread with all messages and their bodies
+func (b *Backend) GetThread(ctx context.Context, threadID string) (*email.Thread, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	idx, err := b.loadIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	thread, ok := idx.threads[strings.TrimPrefix(threadID, "thread:")]
+	if !ok {
+		return nil, fmt.Errorf("thread not found: %s", threadID)
+	}
+
+	for _, msg := range thread.Messages {
+		parsed, err := readMessage(msg.Filenames[0])
+		if err != nil {
+			continue
+		}
+		msg.Parts = parsed.Parts
+		msg.Body = parsed.Body
+		msg.TextBody = parsed.TextBody
+		msg.HTMLBody = parsed.HTMLBody
+		msg.Attachments = parsed.Attachments
+	}
+
+	return thread, nil
+}
+
+// TagThread applies notmuch-style tags to a thread by changing maildir flags
+func (b *Backend) TagThread(ctx context.Context, threadID string, add, remove []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	idx, err := b.loadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	entries, ok := idx.entries[strings.TrimPrefix(threadID, "thread:")]
+	if !ok {
+		return fmt.Errorf("thread not found: %s", threadID)
+	}
+
+	for _, e := range entries {
+		flags := e.flags
+		markSeen := false
+		for _, tag := range add {
+			if tag == "unread" {
+				flags = setFlag(flags, FlagSeen, false)
+				continue
+			}
+			flag, ok := tagFlags[tag]
+			if !ok {
+				return fmt.Errorf("tag %q is not supported by the maildir backend", tag)
+			}
+			flags = setFlag(flags, flag, true)
+		}
+		for _, tag := range remove {
+			if tag == "unread" {
+				flags = setFlag(flags, FlagSeen, true)
+				markSeen = true
+				continue
+			}
+			flag, ok := tagFlags[tag]
+			if !ok {
+				return fmt.Errorf("tag %q is not supported by the maildir backend", tag)
+			}
+			flags = setFlag(flags, flag, false)
+		}
+
+		// Messages leave new/ once a client has looked at them
+		if flags == e.flags && !(markSeen && e.inNew) {
+			continue
+		}
+		if err := b.rename(e, filepath.Join(folderPath(e.path), "cur"), flags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarkThreadRead marks all messages in a thread as read
+func (b *Backend) MarkThreadRead(ctx context.Context, threadID string) error {
+	if err := b.TagThread(ctx, threadID, nil, []string{"unread"}); err != nil {
+		return fmt.Errorf("failed to mark thread as read: %w", err)
+	}
+	return nil
+}
+
+// ArchiveThread moves the inbox messages of a thread to the sibling Archive folder
+func (b *Backend) ArchiveThread(ctx context.Context, threadID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	idx, err := b.loadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	entries, ok := idx.entries[strings.TrimPrefix(threadID, "thread:")]
+	if !ok {
+		return fmt.Errorf("thread not found: %s", threadID)
+	}
+
+	layout := email.DetectLayout(b.root)
+	for _, e := range entries {
+		if !strings.EqualFold(path.Base(e.folder), "INBOX") {
+			continue
+		}
+
+		// The Archive folder sits next to the inbox, wherever the layout puts it
+		folder := path.Join(path.Dir(e.folder), "Archive")
+		archive := filepath.Join(b.root, layout.FolderDir(folder))
+		if err := ensureMaildir(archive); err != nil {
+			return fmt.Errorf("failed to archive thread: %w", err)
+		}
+		if err := b.rename(e, filepath.Join(archive, "cur"), e.flags); err != nil {
+			return fmt.Errorf("failed to archive thread: %w", err)
+		}
+		e.folder = folder
+	}
+
+	return nil
+}
+
+// DeleteThread marks all messages in a thread as trashed
+func (b *Backend) DeleteThread(ctx context.Context, threadID string) error {
+	if err := b.TagThread(ctx, threadID, []string{"deleted"}, nil); err != nil {
+		return fmt.Errorf("failed to delete thread: %w", err)
+	}
+	return nil
+}
+
+// StarThread stars/unstars a thread using the Flagged flag
+func (b *Backend) StarThread(ctx context.Context, threadID string, starred bool) error {
+	var err error
+	if starred {
+		err = b.TagThread(ctx, threadID, []string{"flagged"}, nil)
+	} else {
+		err = b.TagThread(ctx, threadID, nil, []string{"flagged"})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to star/unstar thread: %w", err)
+	}
+	return nil
+}
+
+// GetUnreadCount returns the total unread count
+func (b *Backend) GetUnreadCount(ctx context.Context) (int, error) {
+	folders, err := b.GetMailFolders(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get unread count: %w", err)
+	}
+
+	count := 0
+	for _, folder := range folders {
+		count += folder.UnreadCount
+	}
+	return count, nil
+}
+
+// CountMessages returns the number of messages matching a query
+func (b *Backend) CountMessages(ctx context.Context, rawQuery string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	idx, err := b.loadIndex(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	q := parseQuery(rawQuery)
+	count := 0
+	for threadID, entries := range idx.entries {
+		for _, e := range entries {
+			if q.match(e, threadID, bodyLoader(e)) {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// CountThreads returns the number of threads matching a query
+func (b *Backend) CountThreads(ctx context.Context, rawQuery string) (int, error) {
+	result, err := b.SearchEmails(ctx, rawQuery, email.SearchOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return result.Total, nil
+}
+
+// SearchEmails returns the threads containing messages that match a query
+func (b *Backend) SearchEmails(ctx context.Context, rawQuery string, opts email.SearchOptions) (*email.SearchResult, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	idx, err := b.loadIndex(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search emails: %w", err)
+	}
+
+	q := parseQuery(rawQuery)
+	var threads []*email.Thread
+	for _, thread := range idx.order {
+		// body: terms read message files, so stop when the caller gives up
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("failed to search emails: %w", err)
+		}
+		matched := 0
+		for _, e := range idx.entries[thread.ID] {
+			if q.match(e, thread.ID, bodyLoader(e)) {
+				matched++
+			}
+		}
+		if matched == 0 {
+			continue
+		}
+
+		thread.MatchedCount = matched
+		threads = append(threads, thread)
+	}
+
+	// Threads come newest first from the threading engine
+	if opts.OldestFirst {
+		sort.SliceStable(threads, func(i, j int) bool {
+			return threads[i].Timestamp.Before(threads[j].Timestamp)
+		})
+	}
+
+	total := len(threads)
+	if opts.Offset > 0 {
+		if opts.Offset >= len(threads) {
+			threads = nil
+		} else {
+			threads = threads[opts.Offset:]
+		}
+	}
+	if opts.Limit > 0 && len(threads) > opts.Limit {
+		threads = threads[:opts.Limit]
+	}
+
+	return &email.SearchResult{
+		Threads: threads,
+		Query:   rawQuery,
+		Total:   total,
+		Offset:  opts.Offset,
+		Limit:   opts.Limit,
+	}, nil
+}
+
+// StoreMessage writes a message file to a folder's cur/ directory, going
+// through tmp/ so other clients never see a partial file. Tags are stored as
+// flags; tags without a flag, such as "sent", are implied by the folder.
+func (b *Backend) StoreMessage(ctx context.Context, folderName string, message []byte, tags []string) error {
+	dir := filepath.Join(b.root, filepath.FromSlash(b.folders.Resolve(folderName)))
+	if err := ensureMaildir(dir); err != nil {
+		return fmt.Errorf("failed to store message in %s: %w", folderName, err)
+	}
+
+	flags := string(FlagSeen)
+	for _, tag := range tags {
+		if flag, ok := tagFlags[tag]; ok {
+			flags += string(flag)
+		}
+	}
+
+	base := uniqueName()
+	tmpPath := filepath.Join(dir, "tmp", base)
+	if err := os.WriteFile(tmpPath, message, 0o600); err != nil {
+		return fmt.Errorf("failed to store message in %s: %w", folderName, err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, "cur", formatFilename(base, flags))); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to store message in %s: %w", folderName, err)
+	}
+	return nil
+}
+
+// RemoveMessage deletes every file of a message. Messages without a
+// Message-ID are identified by their path, as in newMessage.
+func (b *Backend) RemoveMessage(ctx context.Context, messageID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.loadIndex(ctx); err != nil {
+		return err
+	}
+	for path, e := range b.cache {
+		if e.key() != messageID {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove message file: %w", err)
+		}
+		delete(b.cache, path)
+	}
+	return nil
+}
+
+// index is the threaded view of the maildir
+type index struct {
+	order   []*email.Thread          // Threads, newest first
+	threads map[string]*email.Thread // Threads by ID
+	entries map[string][]*entry      // Message files by thread ID
+}
+
+// loadIndex parses every message in the maildir and threads them.
+// Parsed headers are cached by path, so only new or renamed files are read.
+// The caller must hold b.mu.
+func (b *Backend) loadIndex(ctx context.Context) (*index, error) {
+	folders, err := email.ScanFolders(b.root)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var entries []*entry
+	for _, folder := range folders {
+		// Parsing a large maildir for the first time can be slow
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		paths, err := listMessages(folder.Path)
+		if err != nil {
+			continue
+		}
+		for _, path := range paths {
+			seen[path] = true
+			e, ok := b.cache[path]
+			if !ok {
+				if e, err = readEntry(path, folder.Name); err != nil {
+					continue
+				}
+				b.cache[path] = e
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	// Forget files that were removed or renamed by another client
+	for path := range b.cache {
+		if !seen[path] {
+			delete(b.cache, path)
+		}
+	}
+
+	return buildIndex(entries), nil
+}
+
+// rename moves a message file to a new directory and flag set, updating the cache.
+// The caller must hold b.mu.
+func (b *Backend) rename(e *entry, dir, flags string) error {
+	base, _ := parseFilename(filepath.Base(e.path))
+	newPath := filepath.Join(dir, formatFilename(base, flags))
+
+	if err := os.Rename(e.path, newPath); err != nil {
+		return fmt.Errorf("failed to update message flags: %w", err)
+	}
+
+	delete(b.cache, e.path)
+	e.path = newPath
+	e.flags = normalizeFlags(flags)
+	e.inNew = false
+	b.cache[newPath] = e
+	return nil
+}
+
+// buildIndex threads the message files. Copies of the same message stored
+// in several folders become a single message with several filenames.
+func buildIndex(entries []*entry) *index {
+	files := make(map[*email.Message][]*entry)
+	byID := make(map[string]*email.Message)
+	var messages []*email.Message
+
+	for _, e := range entries {
+		if msg, ok := byID[e.messageID]; ok && e.messageID != "" {
+			msg.Filenames = append(msg.Filenames, e.path)
+			files[msg] = append(files[msg], e)
+			continue
+		}
+
+		msg := newMessage(e)
+		byID[e.messageID] = msg
+		files[msg] = []*entry{e}
+		messages = append(messages, msg)
+	}
+
+	idx := &index{
+		order:   threading.Thread(messages),
+		threads: make(map[string]*email.Thread),
+		entries: make(map[string][]*entry),
+	}
+	for _, thread := range idx.order {
+		idx.threads[thread.ID] = thread
+		for _, msg := range thread.Messages {
+			idx.entries[thread.ID] = append(idx.entries[thread.ID], files[msg]...)
+		}
+	}
+	return idx
+}
+
+// newMessage converts a message file into a header-only email.Message
+func newMessage(e *entry) *email.Message {
+	return &email.Message{
+		ID:         e.key(),
+		From:       e.from,
+		To:         e.to,
+		Cc:         e.cc,
+		Subject:    e.subject,
+		Timestamp:  e.date,
+		Unread:     e.unread(),
+		Starred:    hasFlag(e.flags, FlagFlagged),
+		Labels:     flagTags(e.flags, e.inNew),
+		Filenames:  []string{e.path},
+		Headers:    flattenHeader(e),
+		Matched:    true,
+		InReplyTo:  e.inReplyTo,
+		References: e.references,
+	}
+}
+
+// flattenHeader converts a mail.Header into a single-valued header map
+func flattenHeader(e *entry) map[string]string {
+	headers := make(map[string]string, len(e.header))
+	for name, values := range e.header {
+		if len(values) > 0 {
+			headers[name] = email.DecodeHeader(values[0])
+		}
+	}
+	return headers
+}
+
+// bodyLoader returns a function that lazily loads a message body
+func bodyLoader(e *entry) func() string {
+	return func() string {
+		msg, err := readMessage(e.path)
+		if err != nil {
+			return ""
+		}
+		return msg.Body
+	}
+}
+
+// listMessages lists the message files in the cur/ and new/ directories of a folder
+func listMessages(dir string) ([]string, error) {
+	var paths []string
+	found := false
+	for _, sub := range []string{"cur", "new"} {
+		files, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, file := range files {
+			if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+			paths = append(paths, filepath.Join(dir, sub, file.Name()))
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("not a maildir folder: %s", dir)
+	}
+	return paths, nil
+}
+
+// ensureMaildir creates the cur/, new/ and tmp/ directories of a folder
+func ensureMaildir(dir string) error {
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return fmt.Errorf("failed to create maildir %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// uniqueName returns a maildir file name that no other delivery uses:
+// time, process and random parts, then the host name
+func uniqueName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	// Slashes and colons are not allowed in maildir names
+	host = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+
+	random := make([]byte, 6)
+	rand.Read(random)
+	now := time.Now()
+	return fmt.Sprintf("%d.M%dP%dR%x.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), random, host)
+}
+
+// folderPath returns the folder directory of a message path
+func folderPath(messagePath string) string {
+	return filepath.Dir(filepath.Dir(messagePath))
+}
+
+// isNewPath reports whether a message path is inside new/
+func isNewPath(path string) bool {
+	return filepath.Base(filepath.Dir(path)) == "new"
+}
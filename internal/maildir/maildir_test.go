@@ -0,0 +1,269 @@
+package maildir
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+// writeMessage writes a raw message into a maildir subdirectory
+func writeMessage(t *testing.T, dir, sub, name, raw string) string {
+	t.Helper()
+
+	if err := ensureMaildir(dir); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, sub, name)
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(raw, "\n", "\r\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newFixture builds a small maildir with a two-message conversation
+func newFixture(t *testing.T) (*Backend, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	inbox := filepath.Join(root, "INBOX")
+	sent := filepath.Join(root, "Sent")
+
+	writeMessage(t, inbox, "cur", "1700000000.1.host:2,S", `Message-ID: <root@example.com>
+From: Alice <alice@example.com>
+To: bob@example.com
+Subject: =?UTF-8?Q?Caf=C3=A9?= plans
+Date: Tue, 14 Nov 2023 22:13:20 +0000
+
+Coffee tomorrow?
+`)
+	writeMessage(t, sent, "cur", "1700003600.2.host:2,S", `Message-ID: <reply@example.com>
+In-Reply-To: <root@example.com>
+References: <root@example.com>
+From: bob@example.com
+To: Alice <alice@example.com>
+Subject: Re: =?UTF-8?Q?Caf=C3=A9?= plans
+Date: Tue, 14 Nov 2023 23:13:20 +0000
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Sure, 9 o'clock works =E2=98=95
+`)
+	writeMessage(t, inbox, "new", "1700007200.3.host", `Message-ID: <other@example.com>
+From: Carol <carol@example.com>
+To: bob@example.com
+Subject: Unrelated
+Date: Wed, 15 Nov 2023 00:13:20 +0000
+
+Hello
+`)
+
+	return New(root), root
+}
+
+func TestGetMailFolders(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newFixture(t)
+
+	folders, err := backend.GetMailFolders(ctx)
+	if err != nil {
+		t.Fatalf("GetMailFolders() failed: %v", err)
+	}
+
+	counts := make(map[string][2]int)
+	for _, folder := range folders {
+		counts[folder.Name] = [2]int{folder.UnreadCount, folder.MessageCount}
+	}
+
+	if counts["INBOX"] != [2]int{1, 2} {
+		t.Errorf("Expected INBOX to have 1 unread of 2, got %v", counts["INBOX"])
+	}
+	if counts["Sent"] != [2]int{0, 1} {
+		t.Errorf("Expected Sent to have 0 unread of 1, got %v", counts["Sent"])
+	}
+}
+
+func TestThreadsAcrossFolders(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newFixture(t)
+
+	threads, err := backend.GetThreadsFromFolder(ctx, "INBOX")
+	if err != nil {
+		t.Fatalf("GetThreadsFromFolder() failed: %v", err)
+	}
+	if len(threads) != 2 {
+		t.Fatalf("Expected 2 threads in INBOX, got %d", len(threads))
+	}
+	if threads[0].Subject != "Unrelated" || threads[0].UnreadCount != 1 {
+		t.Errorf("Expected newest unread thread first, got %+v", threads[0])
+	}
+
+	conversation := threads[1]
+	if conversation.MessageCount != 2 || conversation.Subject != "Café plans" {
+		t.Errorf("Expected decoded 2-message conversation, got %+v", conversation)
+	}
+
+	thread, err := backend.GetThread(ctx, conversation.ID)
+	if err != nil {
+		t.Fatalf("GetThread() failed: %v", err)
+	}
+	if len(thread.Roots) != 1 || len(thread.Roots[0].Replies) != 1 {
+		t.Fatalf("Expected reply to hang under root, got %d roots", len(thread.Roots))
+	}
+	if body := thread.Messages[1].Body; !strings.Contains(body, "works ☕") {
+		t.Errorf("Expected quoted-printable body to be decoded, got %q", body)
+	}
+}
+
+func TestMarkReadAndStarRenameFiles(t *testing.T) {
+	ctx := context.Background()
+	backend, root := newFixture(t)
+
+	result, err := backend.SearchEmails(ctx, "subject:Unrelated", email.SearchOptions{})
+	if err != nil || len(result.Threads) != 1 {
+		t.Fatalf("Expected one search result, got %v (%v)", result, err)
+	}
+	id := result.Threads[0].ID
+
+	if err := backend.MarkThreadRead(ctx, id); err != nil {
+		t.Fatalf("MarkThreadRead() failed: %v", err)
+	}
+	if err := backend.StarThread(ctx, id, true); err != nil {
+		t.Fatalf("StarThread() failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "INBOX", "cur", "1700007200.3.host:2,FS")); err != nil {
+		t.Errorf("Expected message to move to cur/ with flags FS: %v", err)
+	}
+
+	unread, err := backend.GetUnreadCount(ctx)
+	if err != nil || unread != 0 {
+		t.Errorf("Expected no unread messages, got %d (%v)", unread, err)
+	}
+
+	if err := backend.TagThread(ctx, id, []string{"custom"}, nil); err == nil {
+		t.Error("Expected error for tags that have no maildir flag")
+	}
+}
+
+func TestArchiveMaildirPlusPlus(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writeMessage(t, root, "cur", "1700000000.1.host:2,S", `Message-ID: <a@example.com>
+Subject: Archive me
+Date: Tue, 14 Nov 2023 22:13:20 +0000
+
+Body
+`)
+	writeMessage(t, filepath.Join(root, ".Lists.golang"), "cur", "1700000001.2.host:2,", `Message-ID: <b@example.com>
+Subject: Generics
+Date: Tue, 14 Nov 2023 22:13:21 +0000
+
+Body
+`)
+	backend := New(root)
+
+	threads, err := backend.GetThreadsFromFolder(ctx, "Lists/golang")
+	if err != nil || len(threads) != 1 {
+		t.Fatalf("Expected one thread in Lists/golang, got %d (%v)", len(threads), err)
+	}
+
+	inbox, err := backend.GetThreadsFromFolder(ctx, "INBOX")
+	if err != nil || len(inbox) != 1 {
+		t.Fatalf("Expected one thread in the root inbox, got %d (%v)", len(inbox), err)
+	}
+	if err := backend.ArchiveThread(ctx, inbox[0].ID); err != nil {
+		t.Fatalf("ArchiveThread() failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".Archive", "cur", "1700000000.1.host:2,S")); err != nil {
+		t.Errorf("Expected message to move to the .Archive maildir: %v", err)
+	}
+
+	archived, err := backend.GetThreadsFromFolder(ctx, "Archive")
+	if err != nil || len(archived) != 1 {
+		t.Errorf("Expected archived thread in Archive, got %d (%v)", len(archived), err)
+	}
+}
+
+func TestQueryMatching(t *testing.T) {
+	ctx := context.Background()
+	backend, _ := newFixture(t)
+
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"*", 3},
+		{"tag:unread", 1},
+		{"not tag:unread", 2},
+		{"folder:Sent", 1},
+		{"from:alice", 1},
+		{"body:coffee", 1},
+		{"plans and -folder:Sent", 1},
+		{`subject:"café plans"`, 2},
+		{"from:alice or folder:Sent", 2},
+		{"tag:unread or not tag:unread", 3},
+		{"(from:carol or folder:Sent) and tag:unread", 1},
+		{"-(from:alice or folder:Sent)", 1},
+		{"date:today or from:alice", 1},
+	}
+
+	for _, tt := range tests {
+		count, err := backend.CountMessages(ctx, tt.query)
+		if err != nil {
+			t.Fatalf("CountMessages(%q) failed: %v", tt.query, err)
+		}
+		if count != tt.count {
+			t.Errorf("CountMessages(%q) = %d, want %d", tt.query, count, tt.count)
+		}
+	}
+}
+
+func TestFilenameFlags(t *testing.T) {
+	base, flags := parseFilename("1700000000.1.host:2,SF")
+	if base != "1700000000.1.host" || flags != "SF" {
+		t.Errorf("Unexpected parse result %q %q", base, flags)
+	}
+	if name := formatFilename(base, setFlag(flags, FlagReplied, true)); name != "1700000000.1.host:2,FRS" {
+		t.Errorf("Expected sorted flags, got %q", name)
+	}
+	if flags := setFlag("FS", FlagFlagged, false); flags != "S" {
+		t.Errorf("Expected F to be cleared, got %q", flags)
+	}
+}
+
+func TestStoreAndRemoveMessage(t *testing.T) {
+	b, root := newFixture(t)
+	ctx := context.Background()
+
+	raw := "Message-ID: <draft@example.com>\r\nFrom: bob@example.com\r\nSubject: Draft\r\n\r\nNot done yet\r\n"
+	if err := b.StoreMessage(ctx, "Drafts", []byte(raw), []string{"draft"}); err != nil {
+		t.Fatalf("StoreMessage failed: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(root, "Drafts", "cur", "*"))
+	if len(files) != 1 || !strings.HasSuffix(files[0], ":2,DS") {
+		t.Fatalf("Expected one draft with the D and S flags, got %v", files)
+	}
+	if tmp, _ := os.ReadDir(filepath.Join(root, "Drafts", "tmp")); len(tmp) != 0 {
+		t.Errorf("Expected tmp/ to be empty after delivery, got %d files", len(tmp))
+	}
+
+	threads, err := b.GetThreadsFromFolder(ctx, "Drafts")
+	if err != nil || len(threads) != 1 || threads[0].UnreadCount != 0 {
+		t.Fatalf("Expected one read draft thread, got %v (%v)", threads, err)
+	}
+
+	if err := b.RemoveMessage(ctx, "draft@example.com"); err != nil {
+		t.Fatalf("RemoveMessage failed: %v", err)
+	}
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected the draft file to be removed")
+	}
+	if threads, _ := b.GetThreadsFromFolder(ctx, "Drafts"); len(threads) != 0 {
+		t.Errorf("Expected no drafts after removal, got %d", len(threads))
+	}
+}
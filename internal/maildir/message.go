@@ -0,0 +1,96 @@
+package maildir
+
+import (
+	"bufio"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+// entry is a single message file in the maildir, with its parsed headers
+type entry struct {
+	path       string // Full path to the message file
+	folder     string // Folder name relative to the maildir root
+	inNew      bool   // Whether the file lives in new/ (never seen by a client)
+	flags      string
+	messageID  string
+	inReplyTo  string
+	references []string
+	from       string
+	to         []string
+	cc         []string
+	subject    string
+	date       time.Time
+	header     mail.Header
+}
+
+// unread reports whether the message has not been seen yet
+func (e *entry) unread() bool {
+	return e.inNew || !hasFlag(e.flags, FlagSeen)
+}
+
+// key returns the identifier used for threading, falling back to the path
+func (e *entry) key() string {
+	if e.messageID != "" {
+		return e.messageID
+	}
+	return e.path
+}
+
+// readEntry parses the headers of a message file
+func readEntry(path, folder string) (*entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open message: %w", err)
+	}
+	defer file.Close()
+
+	// Only the header block is read; the body is loaded on demand
+	msg, err := mail.ReadMessage(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message %s: %w", path, err)
+	}
+
+	_, flags := parseFilename(filepath.Base(path))
+	e := &entry{
+		path:       path,
+		folder:     folder,
+		inNew:      filepath.Base(filepath.Dir(path)) == "new",
+		flags:      flags,
+		messageID:  email.ParseMessageID(msg.Header.Get("Message-ID")),
+		inReplyTo:  email.ParseMessageID(msg.Header.Get("In-Reply-To")),
+		references: email.ParseMessageIDs(msg.Header.Get("References")),
+		from:       email.DecodeHeader(msg.Header.Get("From")),
+		to:         email.DecodeAddressList(msg.Header.Get("To")),
+		cc:         email.DecodeAddressList(msg.Header.Get("Cc")),
+		subject:    email.DecodeHeader(msg.Header.Get("Subject")),
+		header:     msg.Header,
+	}
+
+	if date, err := msg.Header.Date(); err == nil {
+		e.date = date
+	} else if info, err := file.Stat(); err == nil {
+		e.date = info.ModTime()
+	}
+
+	return e, nil
+}
+
+// readMessage fully parses a message file, including its MIME parts
+func readMessage(path string) (*email.Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open message: %w", err)
+	}
+	defer file.Close()
+
+	msg, err := email.ParseMessage(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message %s: %w", path, err)
+	}
+	return msg, nil
+}
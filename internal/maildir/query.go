@@ -0,0 +1,243 @@
+package maildir
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// queryTerm is a single condition of a search query
+type queryTerm struct {
+	field string // Empty for free text
+	value string
+}
+
+// query is a boolean expression parsed from a notmuch-like query string:
+// either a single term, or nodes that must all match, or with any set, of
+// which one must match. Supported prefixes are folder:, tag:, from:, to:,
+// subject:, body:, id: and thread:. Terms are AND-ed unless joined by "or",
+// parentheses group, and "not" or a leading "-" negate the next term or
+// group. Other prefixes (date:, ...) are accepted and ignored.
+type query struct {
+	term   *queryTerm
+	nodes  []*query
+	any    bool
+	negate bool
+}
+
+// parseQuery parses a notmuch-like query string. Unbalanced parentheses
+// are closed at the end of the query or dropped rather than reported.
+func parseQuery(raw string) *query {
+	p := &queryParser{tokens: tokenizeQuery(raw)}
+	q := p.parseOr()
+	for !p.done() {
+		// A stray ")" ended the expression early; AND what follows
+		p.pos++
+		q = &query{nodes: []*query{q, p.parseOr()}}
+	}
+	return q
+}
+
+// queryParser walks the tokens of a query
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+// done reports whether every token has been parsed
+func (p *queryParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// operand reports whether the next token starts a term or a group
+func (p *queryParser) operand() bool {
+	if p.done() {
+		return false
+	}
+	switch strings.ToLower(p.tokens[p.pos]) {
+	case ")", "and", "or":
+		return false
+	}
+	return true
+}
+
+// parseOr parses alternatives separated by "or"
+func (p *queryParser) parseOr() *query {
+	q := &query{any: true, nodes: []*query{p.parseAnd()}}
+	for !p.done() && strings.EqualFold(p.tokens[p.pos], "or") {
+		p.pos++
+		q.nodes = append(q.nodes, p.parseAnd())
+	}
+	if len(q.nodes) == 1 {
+		return q.nodes[0]
+	}
+	return q
+}
+
+// parseAnd parses operands joined by "and" or by juxtaposition, up to an
+// "or" or a closing parenthesis
+func (p *queryParser) parseAnd() *query {
+	q := &query{}
+	for !p.done() {
+		switch {
+		case strings.EqualFold(p.tokens[p.pos], "and"):
+			p.pos++
+		case p.operand():
+			q.nodes = append(q.nodes, p.parseOperand())
+		default:
+			return q
+		}
+	}
+	return q
+}
+
+// parseOperand parses a term or a parenthesised group, possibly negated
+func (p *queryParser) parseOperand() *query {
+	token := p.tokens[p.pos]
+	p.pos++
+
+	switch {
+	case token == "*":
+		return &query{}
+	case token == "-" || strings.EqualFold(token, "not"):
+		if !p.operand() {
+			return &query{}
+		}
+		q := p.parseOperand()
+		q.negate = !q.negate
+		return q
+	case token == "(":
+		q := p.parseOr()
+		if !p.done() {
+			p.pos++ // Closing parenthesis
+		}
+		return q
+	}
+
+	q := &query{term: &queryTerm{}}
+	if strings.HasPrefix(token, "-") {
+		q.negate = true
+		token = token[1:]
+	}
+	if idx := strings.IndexByte(token, ':'); idx > 0 {
+		q.term.field = strings.ToLower(token[:idx])
+		q.term.value = strings.Trim(token[idx+1:], `"`)
+	} else {
+		q.term.value = strings.Trim(token, `"`)
+	}
+	return q
+}
+
+// tokenizeQuery splits a query on whitespace and parentheses, keeping quoted
+// values together
+func tokenizeQuery(raw string) []string {
+	var (
+		tokens   []string
+		current  strings.Builder
+		inQuotes bool
+	)
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case (r == ' ' || r == '\t') && !inQuotes:
+			flush()
+		case (r == '(' || r == ')') && !inQuotes:
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// match reports whether a message matches the query.
+// threadID is the ID of the thread the message belongs to.
+func (q *query) match(e *entry, threadID string, body func() string) bool {
+	matched, known := q.eval(e, threadID, body)
+	return matched || !known
+}
+
+// eval evaluates the query on a message. known is false when the query only
+// holds ignored terms, which neither match nor exclude a message.
+func (q *query) eval(e *entry, threadID string, body func() string) (matched, known bool) {
+	if q.term != nil {
+		matched, known = matchTerm(*q.term, e, threadID, body)
+	} else {
+		matched = !q.any
+		for _, node := range q.nodes {
+			nodeMatched, nodeKnown := node.eval(e, threadID, body)
+			if !nodeKnown {
+				continue
+			}
+			known = true
+			if nodeMatched == q.any {
+				matched = nodeMatched
+				break
+			}
+		}
+	}
+	return matched != q.negate, known
+}
+
+// matchTerm evaluates a single term, reporting false for known when its
+// prefix is not supported
+func matchTerm(term queryTerm, e *entry, threadID string, body func() string) (matched, known bool) {
+	value := strings.ToLower(term.value)
+
+	switch term.field {
+	case "":
+		return containsFold(e.subject, value) || containsFold(e.from, value) ||
+			containsFold(strings.Join(e.to, ", "), value), true
+	case "folder", "path":
+		folder := strings.ToLower(filepath.ToSlash(e.folder))
+		if strings.HasSuffix(value, "/**") {
+			return strings.HasPrefix(folder+"/", strings.TrimSuffix(value, "**")), true
+		}
+		return folder == value, true
+	case "tag", "is":
+		return matchTag(e, value), true
+	case "from":
+		return containsFold(e.from, value), true
+	case "to":
+		return containsFold(strings.Join(e.to, ", "), value) || containsFold(strings.Join(e.cc, ", "), value), true
+	case "subject":
+		return containsFold(e.subject, value), true
+	case "body":
+		return containsFold(body(), value), true
+	case "id", "mid":
+		return strings.EqualFold(e.messageID, term.value), true
+	case "thread":
+		return strings.EqualFold(threadID, term.value), true
+	}
+	return false, false
+}
+
+// matchTag evaluates a tag: term against the message flags and location
+func matchTag(e *entry, tag string) bool {
+	if tag == "inbox" {
+		return strings.EqualFold(filepath.Base(e.folder), "INBOX")
+	}
+	for _, t := range flagTags(e.flags, e.inNew) {
+		if t == tag {
+			return true
+		}
+	}
+	if flag, ok := tagFlags[tag]; ok {
+		return hasFlag(e.flags, flag)
+	}
+	return false
+}
+
+// containsFold reports whether s contains the lowercase substring sub
+func containsFold(s, sub string) bool {
+	return strings.Contains(strings.ToLower(s), sub)
+}
@@ -0,0 +1,229 @@
+// Package outbox keeps messages that could not be sent in a spool
+// directory, with their delivery state, and retries them later, so mail can
+// be written offline.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+	"github.com/romaintb/mel/internal/spool"
+)
+
+// Retry delays grow from minRetryDelay, doubling after each failure
+const (
+	minRetryDelay = time.Minute
+	maxRetryDelay = time.Hour
+)
+
+// Item is a queued message and its delivery state
+type Item struct {
+	ID          string    `json:"-"`
+	Subject     string    `json:"subject"`
+	To          []string  `json:"to"`
+	Queued      time.Time `json:"queued"`
+	Attempts    int       `json:"attempts"`
+	LastAttempt time.Time `json:"last_attempt"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error"`
+
+	// Failed is set when the transfer agent rejected the message for good;
+	// failed items are only sent again on request
+	Failed bool `json:"failed"`
+}
+
+// SendFunc delivers a queued message; an error keeps it in the queue
+type SendFunc func(ctx context.Context, message []byte) error
+
+// Outbox is a queue of messages waiting to be sent
+type Outbox struct {
+	dir spool.Dir
+	mu  sync.Mutex // Serializes flushes so no message is sent twice
+}
+
+// New creates an outbox stored in dir, which is created on first use
+func New(dir string) *Outbox {
+	return &Outbox{dir: spool.Dir(dir)}
+}
+
+// Enqueue adds a message that could not be sent, recording why. Messages
+// rejected for good are marked failed; others are retried after a delay.
+func (o *Outbox) Enqueue(message []byte, sendErr error) (*Item, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := os.MkdirAll(string(o.dir), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create outbox: %w", err)
+	}
+
+	now := time.Now()
+	item := &Item{ID: spool.NewID(now), Queued: now}
+	item.Subject, item.To = spool.Describe(message)
+	item.recordFailure(sendErr, now)
+
+	if err := spool.WriteFile(o.dir.MessagePath(item.ID), message); err != nil {
+		return nil, fmt.Errorf("failed to queue message: %w", err)
+	}
+	if err := o.save(item); err != nil {
+		os.Remove(o.dir.MessagePath(item.ID))
+		return nil, err
+	}
+	return item, nil
+}
+
+// List returns the queued messages, oldest first
+func (o *Outbox) List() ([]*Item, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.list()
+}
+
+// Remove drops a message from the queue without sending it
+func (o *Outbox) Remove(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, path := range []string{o.dir.MessagePath(id), o.dir.StatePath(id)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove queued message: %w", err)
+		}
+	}
+	return nil
+}
+
+// FlushMode selects the queued messages a flush sends
+type FlushMode int
+
+const (
+	// FlushDue sends the messages whose retry delay is over
+	FlushDue FlushMode = iota
+
+	// FlushPending sends every message not failed for good, without waiting
+	// for the retry delay, such as once a sync shows the network is back
+	FlushPending
+
+	// FlushAll sends every message now, including failed ones
+	FlushAll
+)
+
+// Flush sends the queued messages selected by mode and returns how many
+// were sent. With an id, only that message is. Messages that fail again stay
+// queued.
+func (o *Outbox) Flush(ctx context.Context, send SendFunc, mode FlushMode, id string) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	items, err := o.list()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	now := time.Now()
+	for _, item := range items {
+		if id != "" && item.ID != id {
+			continue
+		}
+		if mode != FlushAll && item.Failed || mode == FlushDue && now.Before(item.NextAttempt) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+
+		message, err := os.ReadFile(o.dir.MessagePath(item.ID))
+		if err != nil {
+			return sent, fmt.Errorf("failed to read queued message: %w", err)
+		}
+
+		if err := send(ctx, message); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return sent, err
+			}
+			item.recordFailure(err, time.Now())
+			if err := o.save(item); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		sent++
+		for _, path := range []string{o.dir.MessagePath(item.ID), o.dir.StatePath(item.ID)} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return sent, fmt.Errorf("failed to remove sent message from outbox: %w", err)
+			}
+		}
+	}
+	return sent, nil
+}
+
+// recordFailure notes a failed attempt and schedules the next one
+func (i *Item) recordFailure(err error, now time.Time) {
+	i.Attempts++
+	i.LastAttempt = now
+	i.LastError = err.Error()
+
+	// Only failures that may go away on their own are worth retrying
+	var sendErr *email.SendError
+	i.Failed = !errors.As(err, &sendErr) || !sendErr.Temporary()
+
+	delay := minRetryDelay
+	for n := 1; n < i.Attempts && delay < maxRetryDelay; n++ {
+		delay *= 2
+	}
+	i.NextAttempt = now.Add(min(delay, maxRetryDelay))
+}
+
+// list reads the state of every queued message; o.mu must be held
+func (o *Outbox) list() ([]*Item, error) {
+	entries, err := os.ReadDir(string(o.dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	var items []*Item
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(o.dir.StatePath(id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read outbox: %w", err)
+		}
+		item := &Item{}
+		if err := json.Unmarshal(data, item); err != nil {
+			return nil, fmt.Errorf("failed to parse outbox entry %s: %w", id, err)
+		}
+		item.ID = id
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Queued.Before(items[j].Queued)
+	})
+	return items, nil
+}
+
+// save writes the state of a queued message
+func (o *Outbox) save(item *Item) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox entry: %w", err)
+	}
+	if err := spool.WriteFile(o.dir.StatePath(item.ID), data); err != nil {
+		return fmt.Errorf("failed to save outbox entry: %w", err)
+	}
+	return nil
+}
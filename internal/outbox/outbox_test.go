@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+const testMessage = "From: alice@example.com\r\nTo: Bob <bob@example.org>\r\nSubject: =?UTF-8?Q?Caf=C3=A9?=\r\n\r\nHi\r\n"
+
+// temporaryError is what msmtp reports when the network is down
+var temporaryError = &email.SendError{Command: "msmtp", ExitCode: 69, Stderr: "cannot connect"}
+
+func TestEnqueueAndList(t *testing.T) {
+	o := New(t.TempDir())
+
+	if items, err := o.List(); err != nil || len(items) != 0 {
+		t.Fatalf("Expected an empty outbox, got %v (%v)", items, err)
+	}
+
+	queued, err := o.Enqueue([]byte(testMessage), temporaryError)
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if _, err := o.Enqueue([]byte(testMessage), errors.New("rejected")); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	items, err := o.List()
+	if err != nil || len(items) != 2 {
+		t.Fatalf("Expected two queued messages, got %v (%v)", items, err)
+	}
+	item := items[0]
+	if item.ID != queued.ID || item.Subject != "Café" || len(item.To) != 1 || item.To[0] != "Bob <bob@example.org>" {
+		t.Errorf("Expected the first message with its subject and recipients, got %+v", item)
+	}
+	if item.Failed || item.Attempts != 1 || item.LastError != temporaryError.Error() {
+		t.Errorf("Expected a pending message with its error, got %+v", item)
+	}
+	if delay := item.NextAttempt.Sub(item.LastAttempt); delay != minRetryDelay {
+		t.Errorf("Expected a retry after %v, got %v", minRetryDelay, delay)
+	}
+	if !items[1].Failed {
+		t.Errorf("Expected a permanent error to mark the message failed")
+	}
+
+	if err := o.Remove(items[1].ID); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if items, _ := o.List(); len(items) != 1 {
+		t.Errorf("Expected one message after Remove, got %d", len(items))
+	}
+}
+
+func TestFlush(t *testing.T) {
+	o := New(t.TempDir())
+	if _, err := o.Enqueue([]byte(testMessage), temporaryError); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	var sent []string
+	send := func(ctx context.Context, message []byte) error {
+		sent = append(sent, string(message))
+		return nil
+	}
+
+	// Not due yet
+	if n, err := o.Flush(ctx, send, FlushDue, ""); err != nil || n != 0 || len(sent) != 0 {
+		t.Fatalf("Expected nothing sent before the retry delay, got %d (%v)", n, err)
+	}
+
+	// Failing again doubles the delay
+	fail := func(ctx context.Context, message []byte) error { return temporaryError }
+	if n, err := o.Flush(ctx, fail, FlushPending, ""); err != nil || n != 0 {
+		t.Fatalf("Expected a failed flush to keep the message, got %d (%v)", n, err)
+	}
+	items, _ := o.List()
+	if len(items) != 1 || items[0].Attempts != 2 || items[0].NextAttempt.Sub(items[0].LastAttempt) != 2*minRetryDelay {
+		t.Fatalf("Expected a second attempt with a doubled delay, got %+v", items)
+	}
+
+	// A forced flush of another ID leaves the message alone
+	if n, _ := o.Flush(ctx, send, FlushAll, "other"); n != 0 {
+		t.Errorf("Expected no message sent for another ID, got %d", n)
+	}
+
+	if n, err := o.Flush(ctx, send, FlushAll, items[0].ID); err != nil || n != 1 {
+		t.Fatalf("Expected the message to be sent, got %d (%v)", n, err)
+	}
+	if len(sent) != 1 || sent[0] != testMessage {
+		t.Errorf("Expected the queued message to be sent as is, got %q", sent)
+	}
+	if items, _ := o.List(); len(items) != 0 {
+		t.Errorf("Expected an empty outbox after sending, got %d messages", len(items))
+	}
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	item := &Item{Attempts: 20}
+	now := time.Now()
+	item.recordFailure(temporaryError, now)
+	if item.NextAttempt.Sub(now) != maxRetryDelay {
+		t.Errorf("Expected the delay capped at %v, got %v", maxRetryDelay, item.NextAttempt.Sub(now))
+	}
+}
+
+func TestFlushPendingSkipsFailed(t *testing.T) {
+	o := New(t.TempDir())
+	pending, _ := o.Enqueue([]byte(testMessage), temporaryError)
+	if _, err := o.Enqueue([]byte(testMessage), errors.New("rejected")); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := 0
+	send := func(context.Context, []byte) error { sent++; return nil }
+	if n, err := o.Flush(context.Background(), send, FlushPending, ""); err != nil || n != 1 || sent != 1 {
+		t.Fatalf("Expected only the pending message sent despite its delay, got %d (%v)", n, err)
+	}
+	items, _ := o.List()
+	if len(items) != 1 || items[0].ID == pending.ID || !items[0].Failed {
+		t.Errorf("Expected the failed message left, got %+v", items)
+	}
+}
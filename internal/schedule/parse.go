@@ -0,0 +1,149 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHour is the time of day used when only a day is given
+const defaultHour = 9
+
+// weekdays maps day names and their abbreviations to weekdays
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// ParseTime reads a send time relative to now, such as "tomorrow 9:00",
+// "Monday 8am", "in 2h", "17:30" or "2025-03-01 14:00". A day without a
+// time means 9:00; a time without a day means its next occurrence. The
+// result must be in the future.
+func ParseTime(text string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(strings.ToLower(text))
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("no send time given")
+	}
+
+	if fields[0] == "in" {
+		d, err := parseDelay(strings.Join(fields[1:], ""))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid delay %q: %w", text, err)
+		}
+		return now.Add(d), nil
+	}
+
+	var day time.Time
+	dayGiven := true
+	weekday, isWeekday := weekdays[fields[0]]
+	switch {
+	case fields[0] == "today":
+		day = now
+	case fields[0] == "tomorrow":
+		day = now.AddDate(0, 0, 1)
+	case isWeekday:
+		day = now.AddDate(0, 0, (int(weekday)-int(now.Weekday())+7)%7)
+	default:
+		if date, err := time.ParseInLocation("2006-01-02", fields[0], now.Location()); err == nil {
+			day = date
+		} else {
+			day, dayGiven = now, false
+		}
+	}
+	if dayGiven {
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && fields[0] == "at" {
+		fields = fields[1:]
+	}
+
+	hour, minute := defaultHour, 0
+	if len(fields) > 0 {
+		var err error
+		hour, minute, err = parseClock(strings.Join(fields, ""))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid send time %q: %w", text, err)
+		}
+	} else if !dayGiven {
+		return time.Time{}, fmt.Errorf("invalid send time %q", text)
+	}
+
+	at := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location())
+	if !at.After(now) {
+		switch {
+		case !dayGiven:
+			// A bare time that has passed today means tomorrow
+			at = at.AddDate(0, 0, 1)
+		case isWeekday:
+			// Today's name with a past time means next week
+			at = at.AddDate(0, 0, 7)
+		default:
+			return time.Time{}, fmt.Errorf("send time %s is in the past", at.Format("Mon 2 Jan 15:04"))
+		}
+	}
+	return at, nil
+}
+
+// parseDelay reads a delay such as "2h", "90m", "1h30m" or "3d"
+func parseDelay(text string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("expected a number of days")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("delay must be positive")
+	}
+	return d, nil
+}
+
+// parseClock reads a time of day such as "9", "09:30", "8am" or "8:30pm"
+func parseClock(text string) (int, int, error) {
+	suffix := ""
+	for _, s := range []string{"am", "pm"} {
+		if rest, ok := strings.CutSuffix(text, s); ok {
+			text, suffix = rest, s
+			break
+		}
+	}
+
+	hourText, minuteText, hasMinutes := strings.Cut(text, ":")
+	hour, err := strconv.Atoi(hourText)
+	if err != nil || hour < 0 {
+		return 0, 0, fmt.Errorf("expected a time such as 9:00 or 8am")
+	}
+	minute := 0
+	if hasMinutes {
+		if minute, err = strconv.Atoi(minuteText); err != nil || len(minuteText) != 2 || minute > 59 {
+			return 0, 0, fmt.Errorf("expected minutes between 00 and 59")
+		}
+	}
+
+	switch suffix {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, fmt.Errorf("expected an hour between 1 and 12")
+		}
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return 0, 0, fmt.Errorf("expected an hour between 0 and 23")
+		}
+	}
+	return hour, minute, nil
+}
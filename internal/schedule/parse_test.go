@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	// A Wednesday afternoon
+	now := time.Date(2025, 1, 15, 14, 30, 0, 0, time.UTC)
+
+	tests := map[string]time.Time{
+		"tomorrow 9:00":    time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC),
+		"tomorrow":         time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC),
+		"Monday 8am":       time.Date(2025, 1, 20, 8, 0, 0, 0, time.UTC),
+		"fri at 5:15pm":    time.Date(2025, 1, 17, 17, 15, 0, 0, time.UTC),
+		"wednesday 16:00":  time.Date(2025, 1, 15, 16, 0, 0, 0, time.UTC),
+		"wednesday 9:00":   time.Date(2025, 1, 22, 9, 0, 0, 0, time.UTC),
+		"today 12pm":       time.Time{},
+		"17:30":            time.Date(2025, 1, 15, 17, 30, 0, 0, time.UTC),
+		"8am":              time.Date(2025, 1, 16, 8, 0, 0, 0, time.UTC),
+		"12am":             time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC),
+		"2025-03-01 14:00": time.Date(2025, 3, 1, 14, 0, 0, 0, time.UTC),
+		"2025-03-01":       time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+		"in 2h":            now.Add(2 * time.Hour),
+		"in 1h 30m":        now.Add(90 * time.Minute),
+		"in 3d":            now.Add(72 * time.Hour),
+		"2024-12-31 10:00": time.Time{},
+		"":                 time.Time{},
+		"soon":             time.Time{},
+		"tomorrow 25:00":   time.Time{},
+		"tomorrow 9:5":     time.Time{},
+		"13pm":             time.Time{},
+		"in -2h":           time.Time{},
+	}
+
+	for text, want := range tests {
+		got, err := ParseTime(text, now)
+		if want.IsZero() {
+			if err == nil {
+				t.Errorf("Expected an error for %q, got %v", text, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected %q to parse, got %v", text, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("Expected %q to be %v, got %v", text, want, got)
+		}
+	}
+}
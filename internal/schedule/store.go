@@ -0,0 +1,238 @@
+// Package schedule keeps messages to be sent at a later time. They are
+// dispatched by mel while it runs, or by `mel send-queued` from a timer.
+//
+// Scheduled messages are kept in a spool directory with their schedule as
+// state. While a message is being sent its schedule is renamed to ID.json.sending, so that mel and
+// `mel send-queued` never send the same message twice. A claim left behind
+// by a process killed mid-send is taken back once it is older than
+// claimTimeout.
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+	"github.com/romaintb/mel/internal/spool"
+)
+
+// claimTimeout is how long a message may stay claimed before the claim is
+// considered abandoned: a delivery, then saving its Sent copy
+const claimTimeout = 2*email.SendTimeout + time.Minute
+
+// Entry is a scheduled message
+type Entry struct {
+	ID        string    `json:"-"`
+	Subject   string    `json:"subject"`
+	To        []string  `json:"to"`
+	SendAt    time.Time `json:"send_at"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// SendFunc delivers a scheduled message; an error keeps it scheduled
+type SendFunc func(ctx context.Context, message []byte) error
+
+// Store is a directory of scheduled messages
+type Store struct {
+	dir spool.Dir
+}
+
+// New creates a store in dir, which is created on first use
+func New(dir string) *Store {
+	return &Store{dir: spool.Dir(dir)}
+}
+
+// Add schedules a message to be sent at a given time
+func (s *Store) Add(message []byte, sendAt time.Time) (*Entry, error) {
+	if err := os.MkdirAll(string(s.dir), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create schedule directory: %w", err)
+	}
+
+	entry := &Entry{ID: spool.NewID(time.Now()), SendAt: sendAt}
+	entry.Subject, entry.To = spool.Describe(message)
+
+	if err := spool.WriteFile(s.dir.MessagePath(entry.ID), message); err != nil {
+		return nil, fmt.Errorf("failed to schedule message: %w", err)
+	}
+	if err := s.save(entry, s.dir.StatePath(entry.ID)); err != nil {
+		os.Remove(s.dir.MessagePath(entry.ID))
+		return nil, err
+	}
+	return entry, nil
+}
+
+// List returns the scheduled messages, soonest first. Messages being sent
+// are left out, unless their claim was abandoned, in which case they are
+// scheduled again.
+func (s *Store) List() ([]*Entry, error) {
+	entries, err := os.ReadDir(string(s.dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedule directory: %w", err)
+	}
+	if s.reclaim(entries, time.Now()) {
+		if entries, err = os.ReadDir(string(s.dir)); err != nil {
+			return nil, fmt.Errorf("failed to read schedule directory: %w", err)
+		}
+	}
+
+	var result []*Entry
+	for _, dirEntry := range entries {
+		id, ok := strings.CutSuffix(dirEntry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(s.dir.StatePath(id))
+		if os.IsNotExist(err) {
+			// Claimed for sending meanwhile
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read scheduled message: %w", err)
+		}
+		entry := &Entry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			return nil, fmt.Errorf("failed to parse scheduled message %s: %w", id, err)
+		}
+		entry.ID = id
+		result = append(result, entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SendAt.Before(result[j].SendAt)
+	})
+	return result, nil
+}
+
+// Cancel unschedules a message, handing it to keep first, for instance to
+// save it as a draft. If keep fails the message stays scheduled. Messages
+// being sent cannot be cancelled.
+func (s *Store) Cancel(id string, keep func(message []byte) error) error {
+	claimed, err := s.claim(id)
+	if err != nil {
+		return err
+	}
+	message, err := os.ReadFile(s.dir.MessagePath(id))
+	if err == nil {
+		err = keep(message)
+	}
+	if err != nil {
+		os.Rename(claimed, s.dir.StatePath(id))
+		return err
+	}
+	s.remove(id)
+	return nil
+}
+
+// Dispatch sends the messages that are due and returns how many were sent.
+// A message that fails keeps its schedule with the error and is not tried
+// again until it is edited.
+func (s *Store) Dispatch(ctx context.Context, send SendFunc, now time.Time) (int, error) {
+	entries, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, entry := range entries {
+		if entry.SendAt.After(now) {
+			break
+		}
+		if entry.LastError != "" {
+			// Failed messages wait to be edited or cancelled
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+
+		claimed, err := s.claim(entry.ID)
+		if err != nil {
+			// Taken by another process or edited meanwhile
+			continue
+		}
+		message, err := os.ReadFile(s.dir.MessagePath(entry.ID))
+		if err != nil {
+			os.Rename(claimed, s.dir.StatePath(entry.ID))
+			return sent, fmt.Errorf("failed to read scheduled message: %w", err)
+		}
+
+		if err := send(ctx, message); err != nil {
+			entry.LastError = err.Error()
+			if saveErr := s.save(entry, claimed); saveErr != nil {
+				return sent, saveErr
+			}
+			if err := os.Rename(claimed, s.dir.StatePath(entry.ID)); err != nil {
+				return sent, fmt.Errorf("failed to reschedule message: %w", err)
+			}
+			continue
+		}
+
+		sent++
+		s.remove(entry.ID)
+	}
+	return sent, nil
+}
+
+// claim marks a message as being handled by renaming its schedule, which
+// only one process can do, and returns the new path of the schedule
+func (s *Store) claim(id string) (string, error) {
+	claimed := s.dir.StatePath(id) + ".sending"
+	if err := os.Rename(s.dir.StatePath(id), claimed); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("scheduled message %s is being sent or no longer exists", id)
+		}
+		return "", fmt.Errorf("failed to claim scheduled message: %w", err)
+	}
+	// Renaming keeps the modification time; the claim's age starts now
+	now := time.Now()
+	os.Chtimes(claimed, now, now)
+	return claimed, nil
+}
+
+// reclaim schedules again the messages whose claim is older than
+// claimTimeout, left behind by a process killed while sending them. Such a
+// message may have gone out already, but sending it twice beats losing it.
+// It reports whether any claim was taken back.
+func (s *Store) reclaim(entries []os.DirEntry, now time.Time) bool {
+	reclaimed := false
+	for _, dirEntry := range entries {
+		id, ok := strings.CutSuffix(dirEntry.Name(), ".json.sending")
+		if !ok {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil || now.Sub(info.ModTime()) < claimTimeout {
+			continue
+		}
+		if os.Rename(s.dir.StatePath(id)+".sending", s.dir.StatePath(id)) == nil {
+			reclaimed = true
+		}
+	}
+	return reclaimed
+}
+
+// remove deletes the files of a claimed message
+func (s *Store) remove(id string) {
+	os.Remove(s.dir.MessagePath(id))
+	os.Remove(s.dir.StatePath(id) + ".sending")
+}
+
+// save writes the schedule of a message to path
+func (s *Store) save(entry *Entry, path string) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode scheduled message: %w", err)
+	}
+	if err := spool.WriteFile(path, data); err != nil {
+		return fmt.Errorf("failed to save scheduled message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testMessage = "From: alice@example.com\r\nTo: bob@example.org\r\nSubject: Report\r\n\r\nAttached.\r\n"
+
+func TestAddListAndCancel(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now()
+
+	later, err := s.Add([]byte(testMessage), now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	sooner, err := s.Add([]byte(testMessage), now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	entries, err := s.List()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected two scheduled messages, got %v (%v)", entries, err)
+	}
+	if entries[0].ID != sooner.ID || entries[1].ID != later.ID {
+		t.Errorf("Expected the soonest message first, got %v", entries)
+	}
+	if entries[0].Subject != "Report" || len(entries[0].To) != 1 || entries[0].To[0] != "bob@example.org" {
+		t.Errorf("Expected the subject and recipients, got %+v", entries[0])
+	}
+
+	// A failing keep leaves the message scheduled
+	if err := s.Cancel(sooner.ID, func([]byte) error { return errors.New("disk full") }); err == nil {
+		t.Errorf("Expected the keep error to be returned")
+	}
+	var kept string
+	if err := s.Cancel(sooner.ID, func(message []byte) error { kept = string(message); return nil }); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if kept != testMessage {
+		t.Errorf("Expected the message to be handed over, got %q", kept)
+	}
+	if entries, _ := s.List(); len(entries) != 1 || entries[0].ID != later.ID {
+		t.Errorf("Expected only the later message left, got %v", entries)
+	}
+	if err := s.Cancel(sooner.ID, func([]byte) error { return nil }); err == nil {
+		t.Errorf("Expected an error cancelling a message twice")
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now()
+	due, _ := s.Add([]byte(testMessage), now.Add(-time.Minute))
+	if _, err := s.Add([]byte(testMessage), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	fail := func(context.Context, []byte) error { return errors.New("no recipients") }
+	if n, err := s.Dispatch(ctx, fail, now); err != nil || n != 0 {
+		t.Fatalf("Expected nothing sent, got %d (%v)", n, err)
+	}
+	entries, _ := s.List()
+	if len(entries) != 2 || entries[0].ID != due.ID || entries[0].LastError != "no recipients" {
+		t.Fatalf("Expected the failed message kept with its error, got %+v", entries)
+	}
+
+	// Failed messages are not retried until edited
+	sent := 0
+	send := func(context.Context, []byte) error { sent++; return nil }
+	if n, _ := s.Dispatch(ctx, send, now); n != 0 || sent != 0 {
+		t.Errorf("Expected a failed message not to be retried, got %d", n)
+	}
+
+	if n, err := s.Dispatch(ctx, send, now.Add(2*time.Hour)); err != nil || n != 1 {
+		t.Fatalf("Expected the later message sent once due, got %d (%v)", n, err)
+	}
+	if entries, _ := s.List(); len(entries) != 1 || entries[0].ID != due.ID {
+		t.Errorf("Expected only the failed message left, got %v", entries)
+	}
+}
+
+func TestReclaimAbandonedClaim(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	entry, err := s.Add([]byte(testMessage), time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A process claims the message, then dies before sending it
+	claimed, err := s.claim(entry.ID)
+	if err != nil {
+		t.Fatalf("claim failed: %v", err)
+	}
+	if entries, _ := s.List(); len(entries) != 0 {
+		t.Fatalf("Expected a claimed message to be hidden, got %v", entries)
+	}
+
+	old := time.Now().Add(-claimTimeout - time.Minute)
+	if err := os.Chtimes(claimed, old, old); err != nil {
+		t.Fatal(err)
+	}
+	sent := 0
+	send := func(context.Context, []byte) error { sent++; return nil }
+	if n, err := s.Dispatch(context.Background(), send, time.Now()); err != nil || n != 1 || sent != 1 {
+		t.Fatalf("Expected the abandoned message sent, got %d (%v)", n, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Errorf("Expected no files left, got %v", files)
+	}
+}
@@ -1,7 +1,9 @@
 package search
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -24,49 +26,69 @@ type SearchQuery struct {
 	Filters   map[string]string
 	SortBy    string
 	SortOrder string
+	Offset    int // Number of threads to skip
+	Limit     int // Maximum number of threads to fetch (0 for no limit)
+}
+
+// Paged reports whether the query asks for one page of threads. Pages keep
+// date order so they line up; unpaged results are sorted by relevance.
+func (q SearchQuery) Paged() bool {
+	return q.Offset > 0 || q.Limit > 0
+}
+
+// Results holds the results of a search
+type Results struct {
+	Items []*SearchResult
+	Total int // Threads matching in every account, beyond the page
 }
 
 // SearchResult represents a search result with preview
 type SearchResult struct {
 	Thread    *email.Thread
+	Backend   email.Backend // Backend of the account holding the thread
 	MatchType string
 	MatchText string
 	Context   string
 	Relevance float64
 }
 
-// SearchService handles all search operations
+// SearchService handles all search operations, across every account
 type SearchService struct {
-	emailManager *email.Manager
+	backends []email.Backend
 }
 
-// NewSearchService creates a new search service
-func NewSearchService(emailManager *email.Manager) *SearchService {
-	return &SearchService{
-		emailManager: emailManager,
+// NewSearchService creates a new search service over the backends of the
+// accounts; nil backends are skipped
+func NewSearchService(backends ...email.Backend) *SearchService {
+	s := &SearchService{}
+	for _, backend := range backends {
+		if backend != nil {
+			s.backends = append(s.backends, backend)
+		}
 	}
+	return s
 }
 
 // Search performs a search based on the query type
-func (s *SearchService) Search(query SearchQuery) ([]*SearchResult, error) {
-	if s.emailManager == nil {
-		return nil, fmt.Errorf("search service not initialized: email manager is nil")
+func (s *SearchService) Search(ctx context.Context, query SearchQuery) (*Results, error) {
+	if len(s.backends) == 0 {
+		return nil, fmt.Errorf("search service not initialized: no mail backend")
 	}
 
 	switch query.Type {
 	case SearchContent:
-		return s.searchContent(query)
+		return s.searchContent(ctx, query)
 	case SearchSender:
-		return s.searchSender(query)
+		return s.searchSender(ctx, query)
 	case SearchGlobal:
-		return s.searchGlobal(query)
+		return s.searchGlobal(ctx, query)
 	default:
 		return nil, fmt.Errorf("unknown search type: %v", query.Type)
 	}
 }
 
 // searchContent performs full-text content search
-func (s *SearchService) searchContent(query SearchQuery) ([]*SearchResult, error) {
+func (s *SearchService) searchContent(ctx context.Context, query SearchQuery) (*Results, error) {
 	// Use notmuch for content search
 	notmuchQuery := fmt.Sprintf("body:%s", query.Query)
 	if query.Filters["folder"] != "" {
@@ -77,31 +99,27 @@ func (s *SearchService) searchContent(query SearchQuery) ([]*SearchResult, error
 	}
 
 	// Perform the search
-	results, err := s.emailManager.SearchEmails(notmuchQuery)
+	results, err := s.searchBackends(ctx, notmuchQuery, query)
 	if err != nil {
 		return nil, fmt.Errorf("content search failed: %w", err)
 	}
 
-	// Convert to search results with context
-	var searchResults []*SearchResult
-	for _, thread := range results.Threads {
-		result := &SearchResult{
-			Thread:    thread,
-			MatchType: "content",
-			MatchText: query.Query,
-			Context:   s.generateContext(thread, query.Query),
-			Relevance: s.calculateRelevance(thread, query.Query),
-		}
-		searchResults = append(searchResults, result)
+	// Fill in the match details
+	for _, result := range results.Items {
+		result.MatchType = "content"
+		result.MatchText = query.Query
+		result.Context = s.generateContext(result.Thread, query.Query)
+		result.Relevance = s.calculateRelevance(result.Thread, query.Query)
 	}
 
-	// Sort by relevance
-	s.sortByRelevance(searchResults)
-	return searchResults, nil
+	if !query.Paged() {
+		s.sortByRelevance(results.Items)
+	}
+	return results, nil
 }
 
 // searchSender performs sender-based search
-func (s *SearchService) searchSender(query SearchQuery) ([]*SearchResult, error) {
+func (s *SearchService) searchSender(ctx context.Context, query SearchQuery) (*Results, error) {
 	// Use notmuch for sender search
 	notmuchQuery := fmt.Sprintf("from:%s", query.Query)
 	if query.Filters["folder"] != "" {
@@ -109,31 +127,27 @@ func (s *SearchService) searchSender(query SearchQuery) ([]*SearchResult, error)
 	}
 
 	// Perform the search
-	results, err := s.emailManager.SearchEmails(notmuchQuery)
+	results, err := s.searchBackends(ctx, notmuchQuery, query)
 	if err != nil {
 		return nil, fmt.Errorf("sender search failed: %w", err)
 	}
 
-	// Convert to search results
-	var searchResults []*SearchResult
-	for _, thread := range results.Threads {
-		result := &SearchResult{
-			Thread:    thread,
-			MatchType: "sender",
-			MatchText: query.Query,
-			Context:   s.generateSenderContext(thread),
-			Relevance: s.calculateSenderRelevance(thread, query.Query),
-		}
-		searchResults = append(searchResults, result)
+	// Fill in the match details
+	for _, result := range results.Items {
+		result.MatchType = "sender"
+		result.MatchText = query.Query
+		result.Context = s.generateSenderContext(result.Thread)
+		result.Relevance = s.calculateSenderRelevance(result.Thread, query.Query)
 	}
 
-	// Sort by relevance
-	s.sortByRelevance(searchResults)
-	return searchResults, nil
+	if !query.Paged() {
+		s.sortByRelevance(results.Items)
+	}
+	return results, nil
 }
 
 // searchGlobal performs global search across all fields
-func (s *SearchService) searchGlobal(query SearchQuery) ([]*SearchResult, error) {
+func (s *SearchService) searchGlobal(ctx context.Context, query SearchQuery) (*Results, error) {
 	// Use notmuch for global search
 	notmuchQuery := query.Query
 	if query.Filters["folder"] != "" {
@@ -144,27 +158,75 @@ func (s *SearchService) searchGlobal(query SearchQuery) ([]*SearchResult, error)
 	}
 
 	// Perform the search
-	results, err := s.emailManager.SearchEmails(notmuchQuery)
+	results, err := s.searchBackends(ctx, notmuchQuery, query)
 	if err != nil {
 		return nil, fmt.Errorf("global search failed: %w", err)
 	}
 
-	// Convert to search results
-	var searchResults []*SearchResult
-	for _, thread := range results.Threads {
-		result := &SearchResult{
-			Thread:    thread,
-			MatchType: "global",
-			MatchText: query.Query,
-			Context:   s.generateGlobalContext(thread, query.Query),
-			Relevance: s.calculateGlobalRelevance(thread, query.Query),
+	// Fill in the match details
+	for _, result := range results.Items {
+		result.MatchType = "global"
+		result.MatchText = query.Query
+		result.Context = s.generateGlobalContext(result.Thread, query.Query)
+		result.Relevance = s.calculateGlobalRelevance(result.Thread, query.Query)
+	}
+
+	if !query.Paged() {
+		s.sortByRelevance(results.Items)
+	}
+	return results, nil
+}
+
+// searchBackends runs a query on every backend. Threads from several
+// backends are merged in date order before the paging of the query is
+// applied, so each backend is asked for every thread up to the page end.
+func (s *SearchService) searchBackends(ctx context.Context, notmuchQuery string, query SearchQuery) (*Results, error) {
+	opts := s.searchOptions(query)
+	merged := len(s.backends) > 1
+	if merged {
+		opts.Offset = 0
+		if opts.Limit > 0 {
+			opts.Limit += query.Offset
+		}
+	}
+
+	results := &Results{}
+	for _, backend := range s.backends {
+		found, err := backend.SearchEmails(ctx, notmuchQuery, opts)
+		if err != nil {
+			return nil, err
+		}
+		results.Total += found.Total
+		for _, thread := range found.Threads {
+			results.Items = append(results.Items, &SearchResult{Thread: thread, Backend: backend})
+		}
+	}
+	if !merged {
+		return results, nil
+	}
+
+	items := results.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		if opts.OldestFirst {
+			return items[i].Thread.Timestamp.Before(items[j].Thread.Timestamp)
 		}
-		searchResults = append(searchResults, result)
+		return items[i].Thread.Timestamp.After(items[j].Thread.Timestamp)
+	})
+	items = items[min(query.Offset, len(items)):]
+	if query.Limit > 0 && query.Limit < len(items) {
+		items = items[:query.Limit]
 	}
+	results.Items = items
+	return results, nil
+}
 
-	// Sort by relevance
-	s.sortByRelevance(searchResults)
-	return searchResults, nil
+// searchOptions converts the paging and ordering of a query to email search options
+func (s *SearchService) searchOptions(query SearchQuery) email.SearchOptions {
+	return email.SearchOptions{
+		Offset:      query.Offset,
+		Limit:       query.Limit,
+		OldestFirst: strings.EqualFold(query.SortOrder, "asc"),
+	}
 }
 
 // generateContext generates context for content search results
@@ -0,0 +1,124 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+// fakeBackend is an in-memory email.Backend used to test the search service
+type fakeBackend struct {
+	threads   []*email.Thread
+	lastQuery string
+	lastOpts  email.SearchOptions
+}
+
+func (f *fakeBackend) GetMailFolders(context.Context) ([]*email.MailFolder, error) { return nil, nil }
+func (f *fakeBackend) StreamMailFolders(context.Context) <-chan email.FolderUpdate { return nil }
+func (f *fakeBackend) GetThreadsFromFolder(context.Context, string) ([]*email.Thread, error) {
+	return f.threads, nil
+}
+func (f *fakeBackend) GetThread(context.Context, string) (*email.Thread, error)     { return nil, nil }
+func (f *fakeBackend) TagThread(context.Context, string, []string, []string) error  { return nil }
+func (f *fakeBackend) MarkThreadRead(context.Context, string) error                 { return nil }
+func (f *fakeBackend) ArchiveThread(context.Context, string) error                  { return nil }
+func (f *fakeBackend) DeleteThread(context.Context, string) error                   { return nil }
+func (f *fakeBackend) StarThread(context.Context, string, bool) error               { return nil }
+func (f *fakeBackend) GetUnreadCount(context.Context) (int, error)                  { return 0, nil }
+func (f *fakeBackend) CountMessages(context.Context, string) (int, error)           { return 0, nil }
+func (f *fakeBackend) CountThreads(context.Context, string) (int, error)            { return len(f.threads), nil }
+func (f *fakeBackend) StoreMessage(context.Context, string, []byte, []string) error { return nil }
+func (f *fakeBackend) RemoveMessage(context.Context, string) error                  { return nil }
+func (f *fakeBackend) SearchEmails(_ context.Context, query string, opts email.SearchOptions) (*email.SearchResult, error) {
+	f.lastQuery = query
+	f.lastOpts = opts
+	return &email.SearchResult{Threads: f.threads, Query: query, Total: len(f.threads)}, nil
+}
+
+func TestSearchSenderRanksExactMatchFirst(t *testing.T) {
+	now := time.Now()
+	backend := &fakeBackend{threads: []*email.Thread{
+		{ID: "1", Subject: "Hello", Timestamp: now, LatestMessage: &email.Message{From: "alice@example.org"}},
+		{ID: "2", Subject: "Hi", Timestamp: now, LatestMessage: &email.Message{From: "alice@example.com"}},
+	}}
+	service := NewSearchService(backend)
+
+	results, err := service.Search(context.Background(), SearchQuery{
+		Type:    SearchSender,
+		Query:   "alice@example.com",
+		Filters: map[string]string{"folder": "INBOX"},
+	})
+	if err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+
+	if backend.lastQuery != "from:alice@example.com folder:INBOX" {
+		t.Errorf("Unexpected backend query '%s'", backend.lastQuery)
+	}
+	if len(results.Items) != 2 || results.Items[0].Thread.ID != "2" {
+		t.Errorf("Expected exact sender match to rank first, got %+v", results.Items)
+	}
+}
+
+func TestSearchPageKeepsDateOrder(t *testing.T) {
+	now := time.Now()
+	backend := &fakeBackend{threads: []*email.Thread{
+		{ID: "1", Subject: "Hello", Timestamp: now, LatestMessage: &email.Message{From: "alice@example.org"}},
+		{ID: "2", Subject: "Hi", Timestamp: now, LatestMessage: &email.Message{From: "alice@example.com"}},
+	}}
+	service := NewSearchService(backend)
+
+	results, err := service.Search(context.Background(), SearchQuery{Type: SearchSender, Query: "alice@example.com", Limit: 20})
+	if err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+
+	if backend.lastOpts.Limit != 20 {
+		t.Errorf("Expected limit to be passed to the backend, got %d", backend.lastOpts.Limit)
+	}
+	if len(results.Items) != 2 || results.Items[0].Thread.ID != "1" {
+		t.Errorf("Expected a page to keep the backend order, got %+v", results.Items)
+	}
+	if results.Total != 2 {
+		t.Errorf("Expected the backend total, got %d", results.Total)
+	}
+}
+
+func TestSearchMergesAccounts(t *testing.T) {
+	now := time.Now()
+	work := &fakeBackend{threads: []*email.Thread{
+		{ID: "w1", Subject: "Report", Timestamp: now.Add(-2 * time.Hour)},
+	}}
+	personal := &fakeBackend{threads: []*email.Thread{
+		{ID: "p1", Subject: "Report", Timestamp: now.Add(-time.Hour)},
+		{ID: "p2", Subject: "Report", Timestamp: now.Add(-3 * time.Hour)},
+	}}
+	service := NewSearchService(work, personal)
+
+	results, err := service.Search(context.Background(), SearchQuery{Type: SearchGlobal, Query: "report", Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("Search() failed: %v", err)
+	}
+
+	if work.lastOpts.Offset != 0 || work.lastOpts.Limit != 3 {
+		t.Errorf("Expected every backend to be asked up to the page end, got %+v", work.lastOpts)
+	}
+	if len(results.Items) != 2 || results.Items[0].Thread.ID != "w1" || results.Items[1].Thread.ID != "p2" {
+		t.Fatalf("Expected the second page of the merged threads, got %+v", results.Items)
+	}
+	if results.Items[0].Backend != work || results.Items[1].Backend != personal {
+		t.Error("Expected each result to keep the backend of its account")
+	}
+	if results.Total != 3 {
+		t.Errorf("Expected the totals of both accounts, got %d", results.Total)
+	}
+}
+
+func TestSearchWithoutBackend(t *testing.T) {
+	service := NewSearchService(nil)
+	if _, err := service.Search(context.Background(), SearchQuery{Type: SearchGlobal, Query: "x"}); err == nil {
+		t.Error("Expected error when no backend is configured")
+	}
+}
@@ -0,0 +1,65 @@
+// Package spool keeps messages waiting in a local directory, such as the
+// outbox and the scheduled messages.
+//
+// Each message is stored as two files named after its ID: the raw message
+// (ID.eml) and its state as JSON (ID.json). Files are replaced atomically,
+// so a crash never leaves half a message behind.
+package spool
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+// Dir is a spool directory
+type Dir string
+
+// MessagePath returns the path of a spooled message
+func (d Dir) MessagePath(id string) string {
+	return filepath.Join(string(d), id+".eml")
+}
+
+// StatePath returns the path of a spooled message's state
+func (d Dir) StatePath(id string) string {
+	return filepath.Join(string(d), id+".json")
+}
+
+// WriteFile replaces a file atomically, so a crash never leaves half of it
+func WriteFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// NewID returns a unique ID that sorts by creation time
+func NewID(now time.Time) string {
+	random := make([]byte, 4)
+	rand.Read(random)
+	return fmt.Sprintf("%d-%x", now.UnixNano(), random)
+}
+
+// Describe returns the subject and recipients of a message for listings;
+// both are empty when the message cannot be parsed
+func Describe(message []byte) (subject string, recipients []string) {
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		return "", nil
+	}
+	for _, field := range []string{"To", "Cc", "Bcc"} {
+		recipients = append(recipients, email.DecodeAddressList(msg.Header.Get(field))...)
+	}
+	return email.DecodeHeader(msg.Header.Get("Subject")), recipients
+}
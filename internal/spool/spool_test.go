@@ -0,0 +1,38 @@
+package spool
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAndIDs(t *testing.T) {
+	dir := Dir(t.TempDir())
+	first := NewID(time.Unix(1, 0))
+	second := NewID(time.Unix(2, 0))
+	if first >= second || first == NewID(time.Unix(1, 0)) {
+		t.Errorf("Expected unique IDs sorting by time, got %s and %s", first, second)
+	}
+
+	if err := WriteFile(dir.MessagePath(first), []byte("hello")); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if data, _ := os.ReadFile(dir.MessagePath(first)); string(data) != "hello" {
+		t.Errorf("Expected the file written, got %q", data)
+	}
+	if files, _ := filepath.Glob(filepath.Join(string(dir), "*.tmp")); len(files) != 0 {
+		t.Errorf("Expected no temporary file left, got %v", files)
+	}
+	if !strings.HasSuffix(dir.StatePath(first), first+".json") {
+		t.Errorf("Unexpected state path %s", dir.StatePath(first))
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	subject, to := Describe([]byte("To: bob@example.org\r\nCc: carol@example.org\r\nSubject: =?UTF-8?Q?R=C3=A9sum=C3=A9?=\r\n\r\nHi\r\n"))
+	if subject != "Résumé" || len(to) != 2 || to[1] != "carol@example.org" {
+		t.Errorf("Expected the subject and recipients, got %q %v", subject, to)
+	}
+}
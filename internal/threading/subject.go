@@ -0,0 +1,93 @@
+package threading
+
+import (
+	"strings"
+	"unicode"
+)
+
+// replyPrefixes are the localized reply and forward markers stripped from subjects
+var replyPrefixes = []string{
+	"re", "fw", "fwd", // English
+	"aw", "wg", // German
+	"sv", "vs", // Nordic
+	"tr", "réf", // French
+	"rif", "r", // Italian
+	"antw", // Dutch
+	"odp",  // Polish
+}
+
+// NormalizeSubject strips reply/forward prefixes, list tags and extra whitespace,
+// so that "Re: [golang-nuts] Fwd: Hello" and "hello" compare equal.
+func NormalizeSubject(subject string) string {
+	base, _ := splitSubject(subject)
+	return strings.ToLower(base)
+}
+
+// BaseSubject strips reply/forward prefixes and list tags but keeps the case
+func BaseSubject(subject string) string {
+	base, _ := splitSubject(subject)
+	return base
+}
+
+// IsReplySubject reports whether a subject carries a reply or forward prefix
+func IsReplySubject(subject string) bool {
+	_, prefixed := splitSubject(subject)
+	return prefixed
+}
+
+// splitSubject removes all leading prefixes and reports whether any reply
+// or forward marker was found.
+func splitSubject(subject string) (string, bool) {
+	s := strings.Join(strings.Fields(subject), " ")
+	prefixed := false
+
+	for {
+		trimmed := stripListTag(s)
+		if rest, ok := stripReplyPrefix(trimmed); ok {
+			s = rest
+			prefixed = true
+			continue
+		}
+		if trimmed == s {
+			return s, prefixed
+		}
+		s = trimmed
+	}
+}
+
+// stripListTag removes a leading "[list-name]" tag
+func stripListTag(s string) string {
+	if !strings.HasPrefix(s, "[") {
+		return s
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 || end == len(s)-1 {
+		return s
+	}
+	return strings.TrimSpace(s[end+1:])
+}
+
+// stripReplyPrefix removes a leading "Re:", "Re[2]:", "Re(3):" or "Fwd:" marker
+func stripReplyPrefix(s string) (string, bool) {
+	colon := strings.IndexAny(s, ":：")
+	if colon <= 0 {
+		return s, false
+	}
+
+	word := strings.ToLower(s[:colon])
+
+	// Drop a reply counter like "[2]" or "(2)"
+	if idx := strings.IndexAny(word, "[("); idx > 0 && strings.IndexFunc(word[idx:], unicode.IsLetter) < 0 {
+		word = word[:idx]
+	}
+	word = strings.TrimSpace(word)
+
+	for _, prefix := range replyPrefixes {
+		if word == prefix {
+			rest := s[colon:]
+			rest = strings.TrimLeft(rest, ":：")
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return s, false
+}
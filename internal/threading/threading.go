@@ -0,0 +1,363 @@
+// Package threading builds conversations from message headers using
+// Jamie Zawinski's threading algorithm (https://www.jwz.org/doc/threading.html).
+//
+// It lets backends without a threading index (plain maildir, imported mbox
+// files) present the same Gmail-style conversations as notmuch.
+package threading
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+// Options controls the threading behaviour
+type Options struct {
+	// SubjectFallback groups reply messages whose parents are unknown with
+	// the thread that has the same base subject. Two original messages with
+	// the same subject are never merged.
+	SubjectFallback bool
+}
+
+// DefaultOptions returns the options used by Thread
+func DefaultOptions() Options {
+	return Options{SubjectFallback: true}
+}
+
+// container is a node of the threading tree. Containers without a message
+// stand for parents that are referenced but missing from the mailbox.
+type container struct {
+	id       string
+	message  *email.Message
+	parent   *container
+	children []*container
+}
+
+// Thread groups messages into conversations with the default options
+func Thread(messages []*email.Message) []*email.Thread {
+	return BuildThreads(messages, DefaultOptions())
+}
+
+// BuildThreads groups messages into conversations.
+// Threads are returned newest first; each thread's Messages are in
+// depth-first order with siblings sorted by date, and Roots holds the
+// top-level messages of the reply tree.
+func BuildThreads(messages []*email.Message, opts Options) []*email.Thread {
+	roots := buildTree(messages)
+	roots = prune(roots, nil)
+	if opts.SubjectFallback {
+		roots = groupBySubject(roots)
+	}
+
+	threads := make([]*email.Thread, 0, len(roots))
+	for _, root := range roots {
+		if thread := toThread(root); thread != nil {
+			threads = append(threads, thread)
+		}
+	}
+
+	sort.SliceStable(threads, func(i, j int) bool {
+		return threads[i].Timestamp.After(threads[j].Timestamp)
+	})
+	return threads
+}
+
+// ThreadID derives a stable thread identifier from the root Message-ID
+func ThreadID(rootMessageID string) string {
+	sum := sha1.Sum([]byte(rootMessageID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// buildTree links messages to their parents (steps 1 and 2 of the algorithm)
+// and returns the root set.
+func buildTree(messages []*email.Message) []*container {
+	table := make(map[string]*container)
+	var order []*container
+
+	get := func(id string) *container {
+		if c, ok := table[id]; ok {
+			return c
+		}
+		c := &container{id: id}
+		table[id] = c
+		order = append(order, c)
+		return c
+	}
+
+	for i, msg := range messages {
+		id := email.ParseMessageID(msg.ID)
+		if id == "" {
+			id = fmt.Sprintf("mel-no-message-id-%d", i)
+		}
+
+		c := get(id)
+		if c.message != nil {
+			// Duplicate Message-ID: keep both messages as distinct nodes
+			c = get(fmt.Sprintf("%s-duplicate-%d", id, i))
+		}
+		c.message = msg
+
+		// Link the references chain, oldest first
+		var prev *container
+		for _, ref := range referenceChain(msg) {
+			rc := get(ref)
+			if prev != nil && rc.parent == nil && canLink(prev, rc) {
+				link(prev, rc)
+			}
+			prev = rc
+		}
+
+		// The message's own references are authoritative for its parent
+		if c.parent != nil {
+			unlink(c)
+		}
+		if prev != nil && canLink(prev, c) {
+			link(prev, c)
+		}
+	}
+
+	var roots []*container
+	for _, c := range order {
+		if c.parent == nil {
+			roots = append(roots, c)
+		}
+	}
+	return roots
+}
+
+// referenceChain returns the References of a message with In-Reply-To appended
+func referenceChain(msg *email.Message) []string {
+	var refs []string
+	seen := make(map[string]bool)
+	for _, ref := range msg.References {
+		if ref = email.ParseMessageID(ref); ref != "" && !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	inReplyTo := email.ParseMessageID(msg.InReplyTo)
+	if inReplyTo != "" && (len(refs) == 0 || refs[len(refs)-1] != inReplyTo) {
+		// In-Reply-To is more reliable than a truncated References header
+		if seen[inReplyTo] {
+			for i, ref := range refs {
+				if ref == inReplyTo {
+					refs = append(refs[:i], refs[i+1:]...)
+					break
+				}
+			}
+		}
+		refs = append(refs, inReplyTo)
+	}
+
+	// A message never references itself
+	self := email.ParseMessageID(msg.ID)
+	filtered := refs[:0]
+	for _, ref := range refs {
+		if ref != self {
+			filtered = append(filtered, ref)
+		}
+	}
+	return filtered
+}
+
+// canLink reports whether child can be attached under parent without a loop
+func canLink(parent, child *container) bool {
+	if parent == child {
+		return false
+	}
+	for p := parent; p != nil; p = p.parent {
+		if p == child {
+			return false
+		}
+	}
+	return true
+}
+
+// link attaches child under parent
+func link(parent, child *container) {
+	child.parent = parent
+	parent.children = append(parent.children, child)
+}
+
+// unlink detaches a container from its parent
+func unlink(c *container) {
+	parent := c.parent
+	for i, child := range parent.children {
+		if child == c {
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			break
+		}
+	}
+	c.parent = nil
+}
+
+// prune removes empty containers (step 4). Empty containers without
+// children are dropped; their children are promoted to the parent level,
+// except at the root level where an empty container with several children
+// is kept to hold the thread together.
+func prune(list []*container, parent *container) []*container {
+	var result []*container
+	for _, c := range list {
+		c.children = prune(c.children, c)
+
+		if c.message == nil {
+			if len(c.children) == 0 {
+				continue
+			}
+			if parent != nil || len(c.children) == 1 {
+				for _, child := range c.children {
+					child.parent = parent
+					result = append(result, child)
+				}
+				continue
+			}
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// groupBySubject attaches reply roots whose parents are missing to the
+// thread with the same base subject (step 5).
+func groupBySubject(roots []*container) []*container {
+	table := make(map[string]*container)
+	for _, root := range roots {
+		subject := NormalizeSubject(containerSubject(root))
+		if subject == "" {
+			continue
+		}
+		if best, ok := table[subject]; !ok || preferRoot(root, best) {
+			table[subject] = root
+		}
+	}
+
+	var result []*container
+	for _, root := range roots {
+		subject := NormalizeSubject(containerSubject(root))
+		best, ok := table[subject]
+		if subject == "" || !ok || best == root {
+			result = append(result, root)
+			continue
+		}
+
+		switch {
+		case best.message == nil && root.message == nil:
+			// Both are placeholders for missing parents: merge their children
+			for _, child := range root.children {
+				link(best, child)
+			}
+		case best.message == nil || isReply(root):
+			link(best, root)
+		default:
+			// Two original messages with the same subject stay separate
+			result = append(result, root)
+		}
+	}
+	return result
+}
+
+// preferRoot reports whether candidate is a better subject anchor than current:
+// placeholders first, then original (non-reply) messages, then the oldest.
+func preferRoot(candidate, current *container) bool {
+	if (candidate.message == nil) != (current.message == nil) {
+		return candidate.message == nil
+	}
+	if isReply(candidate) != isReply(current) {
+		return !isReply(candidate)
+	}
+	return containerDate(candidate).Before(containerDate(current))
+}
+
+// isReply reports whether a container holds a message with a reply subject
+func isReply(c *container) bool {
+	return c.message != nil && IsReplySubject(c.message.Subject)
+}
+
+// containerSubject returns the subject of a container or of its first message child
+func containerSubject(c *container) string {
+	if c.message != nil {
+		return c.message.Subject
+	}
+	for _, child := range c.children {
+		if subject := containerSubject(child); subject != "" {
+			return subject
+		}
+	}
+	return ""
+}
+
+// containerDate returns the date of a container, or its earliest descendant's
+func containerDate(c *container) time.Time {
+	if c.message != nil {
+		return c.message.Timestamp
+	}
+	var earliest time.Time
+	for _, child := range c.children {
+		date := containerDate(child)
+		if earliest.IsZero() || (!date.IsZero() && date.Before(earliest)) {
+			earliest = date
+		}
+	}
+	return earliest
+}
+
+// toThread converts a root container into an email.Thread
+func toThread(root *container) *email.Thread {
+	thread := &email.Thread{ID: ThreadID(root.id)}
+
+	var walk func(c *container, parent *email.Message, depth int)
+	walk = func(c *container, parent *email.Message, depth int) {
+		sort.SliceStable(c.children, func(i, j int) bool {
+			return containerDate(c.children[i]).Before(containerDate(c.children[j]))
+		})
+
+		if c.message == nil {
+			// Placeholder for a missing parent: its children move up one level
+			for _, child := range c.children {
+				walk(child, parent, depth)
+			}
+			return
+		}
+
+		msg := c.message
+		msg.ThreadID = thread.ID
+		msg.Depth = depth
+		msg.Replies = nil
+		msg.ParentID = ""
+		if parent != nil {
+			msg.ParentID = parent.ID
+			parent.Replies = append(parent.Replies, msg)
+		} else {
+			thread.Roots = append(thread.Roots, msg)
+		}
+		thread.Messages = append(thread.Messages, msg)
+
+		for _, child := range c.children {
+			walk(child, msg, depth+1)
+		}
+	}
+	walk(root, nil, 0)
+
+	if len(thread.Messages) == 0 {
+		return nil
+	}
+
+	thread.Summarize()
+	thread.Subject = threadSubject(thread)
+	return thread
+}
+
+// threadSubject prefers the subject of the first original message in the thread
+func threadSubject(thread *email.Thread) string {
+	for _, msg := range thread.Messages {
+		if !IsReplySubject(msg.Subject) {
+			return msg.Subject
+		}
+	}
+	return thread.Messages[0].Subject
+}
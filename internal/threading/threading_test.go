@@ -0,0 +1,145 @@
+package threading
+
+import (
+	"testing"
+	"time"
+
+	"github.com/romaintb/mel/internal/email"
+)
+
+var base = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// msg builds a message with threading headers, minutes after the base time
+func msg(id, subject string, minutes int, inReplyTo string, references ...string) *email.Message {
+	return &email.Message{
+		ID:         id,
+		Subject:    subject,
+		From:       id + "@example.com",
+		Timestamp:  base.Add(time.Duration(minutes) * time.Minute),
+		InReplyTo:  inReplyTo,
+		References: references,
+	}
+}
+
+func TestThreadReplyTree(t *testing.T) {
+	threads := Thread([]*email.Message{
+		msg("c", "Re: Plan", 20, "b", "a", "b"),
+		msg("a", "Plan", 0, ""),
+		msg("b", "Re: Plan", 10, "a", "a"),
+		msg("d", "Re: Plan", 30, "a", "a"),
+	})
+
+	if len(threads) != 1 {
+		t.Fatalf("Expected 1 thread, got %d", len(threads))
+	}
+
+	thread := threads[0]
+	if thread.Subject != "Plan" || thread.MessageCount != 4 {
+		t.Errorf("Unexpected thread summary %q with %d messages", thread.Subject, thread.MessageCount)
+	}
+	if thread.ID != ThreadID("a") {
+		t.Errorf("Expected thread ID derived from root, got %s", thread.ID)
+	}
+
+	order := ""
+	for _, m := range thread.Messages {
+		order += m.ID
+	}
+	if order != "abcd" {
+		t.Errorf("Expected depth-first date order abcd, got %s", order)
+	}
+
+	root := thread.Roots[0]
+	if len(root.Replies) != 2 || root.Replies[0].ID != "b" || root.Replies[0].Replies[0].ID != "c" {
+		t.Errorf("Unexpected reply tree under root")
+	}
+	if thread.Messages[2].Depth != 2 || thread.Messages[2].ParentID != "b" {
+		t.Errorf("Expected c at depth 2 under b, got depth %d parent %s", thread.Messages[2].Depth, thread.Messages[2].ParentID)
+	}
+}
+
+func TestThreadMissingParent(t *testing.T) {
+	// Both replies reference a root that is not in the mailbox
+	threads := Thread([]*email.Message{
+		msg("b", "Re: Lost", 10, "a", "a"),
+		msg("c", "Re: Lost", 20, "a", "a"),
+	})
+
+	if len(threads) != 1 {
+		t.Fatalf("Expected siblings to stay together, got %d threads", len(threads))
+	}
+	if len(threads[0].Roots) != 2 {
+		t.Errorf("Expected both replies promoted to roots, got %d", len(threads[0].Roots))
+	}
+	if threads[0].ID != ThreadID("a") {
+		t.Error("Expected thread ID derived from the missing root")
+	}
+}
+
+func TestThreadSubjectFallback(t *testing.T) {
+	messages := []*email.Message{
+		msg("a", "Quarterly report", 0, ""),
+		msg("b", "RE: [team] Quarterly report", 10, ""),
+		msg("c", "Quarterly report", 20, ""),
+	}
+
+	threads := Thread(messages)
+	if len(threads) != 2 {
+		t.Fatalf("Expected reply grouped by subject but originals kept apart, got %d threads", len(threads))
+	}
+
+	var grouped *email.Thread
+	for _, thread := range threads {
+		if thread.MessageCount == 2 {
+			grouped = thread
+		}
+	}
+	if grouped == nil || grouped.Roots[0].ID != "a" || grouped.Roots[0].Replies[0].ID != "b" {
+		t.Error("Expected reply b to be attached to original a")
+	}
+
+	threads = BuildThreads(messages, Options{SubjectFallback: false})
+	if len(threads) != 3 {
+		t.Errorf("Expected 3 threads without subject fallback, got %d", len(threads))
+	}
+}
+
+func TestThreadLoopsAndDuplicates(t *testing.T) {
+	threads := Thread([]*email.Message{
+		msg("a", "Loop", 0, "b", "b"),
+		msg("b", "Re: Loop", 10, "a", "a"),
+		msg("b", "Re: Loop", 10, "a", "a"),
+		msg("", "No id", 5, ""),
+	})
+
+	total := 0
+	for _, thread := range threads {
+		total += thread.MessageCount
+	}
+	if total != 4 {
+		t.Errorf("Expected every message to appear exactly once, got %d", total)
+	}
+}
+
+func TestNormalizeSubject(t *testing.T) {
+	tests := map[string]string{
+		"Re: Hello":                   "hello",
+		"RE: Re[2]: Fwd: Hello":       "hello",
+		"[golang-nuts] Re: Hello":     "hello",
+		"AW:  WG: Hello   world":      "hello world",
+		"Réf: Hello":                  "hello",
+		"Re: [list] Re: [list] Hello": "hello",
+		"Regarding: Hello":            "regarding: hello",
+		"[]":                          "[]",
+	}
+
+	for input, want := range tests {
+		if got := NormalizeSubject(input); got != want {
+			t.Errorf("NormalizeSubject(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+	if !IsReplySubject("Fwd: x") || IsReplySubject("x") {
+		t.Error("IsReplySubject() misclassified a subject")
+	}
+}
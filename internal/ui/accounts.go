@@ -0,0 +1,62 @@
+package ui
+
+import (
+	"context"
+
+	"github.com/romaintb/mel/internal/composer"
+	"github.com/romaintb/mel/internal/config"
+	"github.com/romaintb/mel/internal/email"
+)
+
+// allInboxesName is the name of the pseudo-folder listing the inboxes of
+// every account together
+const allInboxesName = "All inboxes"
+
+// Account is a mail account with the backend, sender and syncer serving it
+type Account struct {
+	Config  config.AccountConfig
+	Backend email.Backend
+	Sender  *email.Sender
+	Syncer  *email.Syncer
+}
+
+// Send hands a message to the account's mail transfer agent
+func (a *Account) Send(ctx context.Context, message []byte) error {
+	return a.Sender.Send(ctx, message, email.SendOptions{Account: a.Config.MsmtpAccount})
+}
+
+// identity returns the user's addresses on the account
+func (a *Account) identity() composer.Identity {
+	var aliases []string
+	if len(a.Config.Identities) > 1 {
+		aliases = a.Config.Identities[1:]
+	}
+	return composer.Identity{From: a.Config.From(), Aliases: aliases}
+}
+
+// defaultAccount returns the account named in email.default_account, or the
+// first one
+func defaultAccount(cfg *config.Config, accounts []*Account) *Account {
+	for _, account := range accounts {
+		if account.Config.Name == cfg.Email.DefaultAccount {
+			return account
+		}
+	}
+	return accounts[0]
+}
+
+// AccountFor returns the account a message is sent from, matched on its
+// From address, or fallback when no account claims it. Queued messages only
+// carry their headers, so this is how they find their way back.
+func AccountFor(accounts []*Account, fallback *Account, message []byte) *Account {
+	address, err := email.SenderAddress(message)
+	if err != nil {
+		return fallback
+	}
+	for _, account := range accounts {
+		if account.Config.HasAddress(address) {
+			return account
+		}
+	}
+	return fallback
+}
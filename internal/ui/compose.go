@@ -0,0 +1,636 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/romaintb/mel/internal/composer"
+	"github.com/romaintb/mel/internal/config"
+	"github.com/romaintb/mel/internal/email"
+	"github.com/romaintb/mel/internal/outbox"
+	"github.com/romaintb/mel/internal/schedule"
+)
+
+// editorFinishedMsg is sent when the external editor exits
+type editorFinishedMsg struct {
+	draft *composer.Message
+	path  string
+	err   error
+}
+
+// sentMsg reports the outcome of sending a message
+type sentMsg struct {
+	account *Account
+	draft   *composer.Message
+	draftID string // Saved copy to remove once the message is gone
+	err     error
+	queued  bool  // Sending failed and the message waits in the outbox
+	saveErr error // Failure to save the copy in the Sent folder
+}
+
+// draftSavedMsg reports the outcome of saving a draft to the Drafts folder
+type draftSavedMsg struct {
+	account *Account
+	draft   *composer.Message
+	id      string // Message-ID of the saved copy
+	err     error
+}
+
+// composeClosedMsg is sent when the review screen is left
+type composeClosedMsg struct {
+	status string
+}
+
+// scheduledMsg reports the outcome of scheduling a message to be sent later
+type scheduledMsg struct {
+	draft *composer.Message
+	at    time.Time
+	err   error
+}
+
+// composeStatusMsg shows the progress of a send that outlives the review
+// screen, such as the undo countdown
+type composeStatusMsg struct {
+	status string
+}
+
+// sendCountdownMsg ticks while a sent message can still be taken back
+type sendCountdownMsg struct {
+	pending *pendingSend
+}
+
+// pendingSend is a message held back for the undo-send delay
+type pendingSend struct {
+	account  *Account
+	draft    *composer.Message
+	draftID  string
+	raw      []byte
+	deadline time.Time
+}
+
+// Questions asked on the review screen
+const (
+	promptAttach = "Attach file"
+	promptSendAt = "Send at (e.g. tomorrow 9:00, Monday 8am, in 2h)"
+)
+
+// ComposeView is the review screen shown after a draft was edited: it
+// previews the message and offers to send, edit, attach, postpone or discard
+type ComposeView struct {
+	config     *config.Config
+	outbox     *outbox.Outbox
+	schedule   *schedule.Store
+	operations *Operations
+	width      int
+	height     int
+
+	account *Account // Account the draft is sent from
+	draft   *composer.Message
+	draftID string       // Message-ID of the copy saved in the Drafts folder
+	pending *pendingSend // Sent message waiting for the undo delay, if any
+	sending bool
+	saving  bool   // A copy of the draft is being saved
+	resave  bool   // The draft changed while it was being saved
+	closing bool   // Leave once the draft is saved
+	prompt  string // Question being answered, such as "Attach file"
+	input   string // Answer typed so far
+}
+
+// NewComposeView creates a new compose view instance
+func NewComposeView(cfg *config.Config, queue *outbox.Outbox, scheduled *schedule.Store, operations *Operations) (*ComposeView, error) {
+	return &ComposeView{
+		config:     cfg,
+		outbox:     queue,
+		schedule:   scheduled,
+		operations: operations,
+	}, nil
+}
+
+// NewDraft returns an empty message from the account's sender address
+func (c *ComposeView) NewDraft(account *Account) *composer.Message {
+	return &composer.Message{From: account.Config.From()}
+}
+
+// Open starts editing a draft sent from an account. draftID is the
+// Message-ID of its saved copy when a draft is resumed from the Drafts
+// folder, replaced on the next save.
+func (c *ComposeView) Open(account *Account, draft *composer.Message, draftID string) tea.Cmd {
+	c.account = account
+	c.draftID = draftID
+	return c.Edit(draft)
+}
+
+// Edit writes the draft to a temporary file and suspends the program to
+// run the user's editor on it
+func (c *ComposeView) Edit(draft *composer.Message) tea.Cmd {
+	file, err := os.CreateTemp("", "mel-draft-*.eml")
+	if err != nil {
+		return reportError(fmt.Errorf("failed to create draft file: %w", err))
+	}
+	path := file.Name()
+	_, err = file.Write(composer.FormatDraft(draft))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return reportError(fmt.Errorf("failed to write draft file: %w", err))
+	}
+
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editorFinishedMsg{draft: draft, path: path, err: err}
+	})
+}
+
+// Init initializes the compose view
+func (c *ComposeView) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles compose view updates
+func (c *ComposeView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		return c, c.handleKeyPress(msg)
+	case editorFinishedMsg:
+		return c, c.handleEditorFinished(msg)
+	case draftSavedMsg:
+		return c, c.handleDraftSaved(msg)
+	case sendCountdownMsg:
+		return c, c.handleCountdown(msg)
+	case sentMsg:
+		return c, c.handleSent(msg)
+	case scheduledMsg:
+		if msg.draft != c.draft {
+			return c, nil
+		}
+		c.sending = false
+		if msg.err != nil {
+			c.draft.Date = time.Time{}
+			return c, reportError(msg.err)
+		}
+		account, draftID := c.account, c.draftID
+		c.reset()
+		return c, tea.Batch(c.removeDraft(account, draftID), closeCompose("Scheduled for "+msg.at.Format("Mon 2 Jan 15:04")))
+	}
+	return c, nil
+}
+
+// handleSent closes the review screen once a message is sent or queued.
+// Messages sent after the undo delay have left it already.
+func (c *ComposeView) handleSent(msg sentMsg) tea.Cmd {
+	reviewing := c.draft != nil && msg.draft == c.draft
+	if reviewing {
+		c.sending = false
+	}
+	if msg.err != nil && !msg.queued {
+		// The saved draft is kept so the message can be fixed and sent again
+		return reportError(msg.err)
+	}
+
+	status := "Message sent"
+	switch {
+	case msg.queued:
+		status = "Sending failed, message queued in " + outboxName + ": " + msg.err.Error()
+	case msg.saveErr != nil:
+		status = "Message sent, but not saved to " + msg.account.Config.Folders.Sent
+	}
+
+	if !reviewing {
+		return tea.Batch(c.removeDraft(msg.account, msg.draftID), showComposeStatus(status), reportError(msg.saveErr))
+	}
+	c.reset()
+	return tea.Batch(c.removeDraft(msg.account, msg.draftID), closeCompose(status), reportError(msg.saveErr))
+}
+
+// handleCountdown updates the undo countdown and sends the message once the
+// delay is over
+func (c *ComposeView) handleCountdown(msg sendCountdownMsg) tea.Cmd {
+	if msg.pending != c.pending {
+		// Undone
+		return nil
+	}
+
+	remaining := time.Until(c.pending.deadline)
+	if remaining <= 0 {
+		pending := c.pending
+		c.pending = nil
+		return tea.Batch(showComposeStatus("Sending…"), c.deliver(pending.account, pending.draft, pending.draftID, pending.raw))
+	}
+	return tea.Batch(showComposeStatus(countdownStatus(remaining)), countdownTick(c.pending, remaining))
+}
+
+// Pending reports whether a sent message can still be taken back
+func (c *ComposeView) Pending() bool {
+	return c.pending != nil
+}
+
+// SendPendingAndQuit sends the message waiting for the undo delay at once,
+// then quits: quitting must not drop a message the user already sent. When
+// the transfer agent fails, the message goes to the outbox as usual; when it
+// can be neither sent nor queued, mel stays open to report it.
+func (c *ComposeView) SendPendingAndQuit() tea.Cmd {
+	pending := c.pending
+	c.pending = nil
+	deliver := c.deliver(pending.account, pending.draft, pending.draftID, pending.raw)
+	return func() tea.Msg {
+		sent := deliver().(sentMsg)
+		if sent.err != nil && !sent.queued {
+			return errorMsg{err: fmt.Errorf("failed to send before quitting: %w", sent.err)}
+		}
+		if sent.draftID != "" {
+			// Nothing runs after quitting to remove the saved draft later
+			if err := pending.account.Backend.RemoveMessage(context.Background(), sent.draftID); err != nil {
+				return errorMsg{err: fmt.Errorf("failed to remove draft: %w", err)}
+			}
+		}
+		return tea.Quit()
+	}
+}
+
+// Undo takes back the message waiting for the undo delay and returns to its
+// review screen
+func (c *ComposeView) Undo() {
+	if c.pending == nil || c.draft != nil {
+		return
+	}
+	c.account = c.pending.account
+	c.draft = c.pending.draft
+	c.draftID = c.pending.draftID
+	c.pending = nil
+
+	// It was never sent: it gets a new date and Message-ID when it is
+	c.draft.Date = time.Time{}
+	c.draft.MessageID = ""
+}
+
+// Active reports whether a draft is being reviewed
+func (c *ComposeView) Active() bool {
+	return c.draft != nil
+}
+
+// View renders the review screen
+func (c *ComposeView) View() string {
+	if c.draft == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	field := func(name, value string) {
+		if value != "" {
+			b.WriteString(fmt.Sprintf("%-9s %s\n", name+":", value))
+		}
+	}
+	field("From", c.draft.From)
+	field("To", strings.Join(c.draft.To, ", "))
+	field("Cc", strings.Join(c.draft.Cc, ", "))
+	field("Bcc", strings.Join(c.draft.Bcc, ", "))
+	field("Subject", c.draft.Subject)
+	for _, attachment := range c.draft.Attachments {
+		field("Attach", fmt.Sprintf("%s (%s)", attachment.Filename, formatSize(len(attachment.Data))))
+	}
+	b.WriteString(strings.Repeat("─", max(c.width, 1)) + "\n")
+
+	// Leave room for the header block and the key help
+	lines := strings.Split(strings.TrimRight(c.draft.TextBody, "\n"), "\n")
+	if room := c.height - strings.Count(b.String(), "\n") - 3; room > 0 && len(lines) > room {
+		lines = append(lines[:room-1], "…")
+	}
+	b.WriteString(strings.Join(lines, "\n") + "\n\n")
+
+	switch {
+	case c.sending:
+		b.WriteString("Sending…")
+	case c.closing:
+		b.WriteString("Saving draft…")
+	case c.prompt != "":
+		b.WriteString(c.prompt + ": " + c.input + "█")
+	default:
+		b.WriteString("y:send  l:send later  e:edit  a:attach  p:postpone  D:discard")
+	}
+	return b.String()
+}
+
+// Resize resizes the compose view
+func (c *ComposeView) Resize(width, height int) tea.Cmd {
+	c.width = width
+	c.height = height
+	return nil
+}
+
+// handleEditorFinished reads the edited draft back and shows the review screen
+func (c *ComposeView) handleEditorFinished(msg editorFinishedMsg) tea.Cmd {
+	defer os.Remove(msg.path)
+
+	if msg.err != nil {
+		c.draft = msg.draft
+		return reportError(fmt.Errorf("editor failed: %w", msg.err))
+	}
+
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		c.draft = msg.draft
+		return reportError(fmt.Errorf("failed to read draft file: %w", err))
+	}
+	c.draft = msg.draft
+	// Invalid addresses are reported but kept, to be fixed with another edit
+	if err := composer.ParseDraft(data, msg.draft); err != nil {
+		return reportError(err)
+	}
+	return c.requestSave()
+}
+
+// handleDraftSaved records the saved copy of the draft, saving again if the
+// draft changed meanwhile and leaving if it was postponed
+func (c *ComposeView) handleDraftSaved(msg draftSavedMsg) tea.Cmd {
+	if c.pending != nil && msg.draft == c.pending.draft {
+		// Sent while it was being saved: the copy goes once the message does
+		if msg.id != "" {
+			c.pending.draftID = msg.id
+		}
+		return nil
+	}
+	if msg.draft != c.draft {
+		// The draft was sent or discarded while it was being saved
+		return c.removeDraft(msg.account, msg.id)
+	}
+
+	c.saving = false
+	if msg.id != "" {
+		c.draftID = msg.id
+	}
+	if msg.err != nil {
+		c.closing = false
+		c.resave = false
+		return reportError(msg.err)
+	}
+	if c.resave {
+		c.resave = false
+		return c.saveDraft()
+	}
+	if c.closing {
+		status := "Draft saved to " + c.account.Config.Folders.Drafts
+		c.reset()
+		return closeCompose(status)
+	}
+	return nil
+}
+
+// handleKeyPress handles the review screen keys and the attachment prompt
+func (c *ComposeView) handleKeyPress(msg tea.KeyMsg) tea.Cmd {
+	if c.draft == nil || c.sending || c.closing {
+		return nil
+	}
+
+	if c.prompt != "" {
+		switch msg.Type {
+		case tea.KeyEnter:
+			prompt, answer := c.prompt, strings.TrimSpace(c.input)
+			c.prompt, c.input = "", ""
+			if answer == "" {
+				return nil
+			}
+			if prompt == promptSendAt {
+				return c.sendLater(answer)
+			}
+			if err := c.draft.AttachFile(answer); err != nil {
+				return reportError(err)
+			}
+			return c.requestSave()
+		case tea.KeyEsc:
+			c.prompt, c.input = "", ""
+		case tea.KeyBackspace:
+			if runes := []rune(c.input); len(runes) > 0 {
+				c.input = string(runes[:len(runes)-1])
+			}
+		case tea.KeySpace:
+			c.input += " "
+		case tea.KeyRunes:
+			c.input += string(msg.Runes)
+		}
+		return nil
+	}
+
+	switch msg.String() {
+	case "y":
+		return c.send()
+	case "l":
+		c.prompt = promptSendAt
+	case "e":
+		return c.Edit(c.draft)
+	case "a":
+		c.prompt = promptAttach
+	case "p", "esc":
+		c.closing = true
+		return c.requestSave()
+	case "D":
+		account, draftID := c.account, c.draftID
+		c.reset()
+		return tea.Batch(c.removeDraft(account, draftID), closeCompose("Draft discarded"))
+	}
+	return nil
+}
+
+// requestSave saves the draft, or marks it to be saved again once the save
+// in progress is done so that copies never overlap
+func (c *ComposeView) requestSave() tea.Cmd {
+	if c.saving {
+		c.resave = true
+		return nil
+	}
+	return c.saveDraft()
+}
+
+// saveDraft stores a copy of the draft in the Drafts folder, tagged draft,
+// then removes the copy it replaces. Every copy gets a new Message-ID so the
+// old one can be told apart.
+func (c *ComposeView) saveDraft() tea.Cmd {
+	draft := c.draft
+	saved := *draft
+	saved.Date = time.Time{}
+	saved.MessageID = ""
+	raw, err := composer.Compose(&saved)
+	if err != nil {
+		c.closing = false
+		return reportError(fmt.Errorf("failed to save draft: %w", err))
+	}
+
+	c.saving = true
+	account := c.account
+	previous := c.draftID
+	ctx, done := c.operations.Start("save draft")
+	return func() tea.Msg {
+		defer done()
+		if err := account.Backend.StoreMessage(ctx, account.Config.Folders.Drafts, raw, []string{"draft"}); err != nil {
+			return draftSavedMsg{account: account, draft: draft, err: fmt.Errorf("failed to save draft: %w", err)}
+		}
+		if previous != "" {
+			if err := account.Backend.RemoveMessage(ctx, previous); err != nil {
+				return draftSavedMsg{account: account, draft: draft, id: saved.MessageID, err: fmt.Errorf("failed to remove previous draft: %w", err)}
+			}
+		}
+		return draftSavedMsg{account: account, draft: draft, id: saved.MessageID}
+	}
+}
+
+// removeDraft deletes the saved copy of a draft that was sent or discarded
+func (c *ComposeView) removeDraft(account *Account, id string) tea.Cmd {
+	if id == "" {
+		return nil
+	}
+	ctx, done := c.operations.Start("remove draft " + id)
+	return func() tea.Msg {
+		defer done()
+		if err := account.Backend.RemoveMessage(ctx, id); err != nil {
+			return errorMsg{err: fmt.Errorf("failed to remove draft: %w", err)}
+		}
+		return nil
+	}
+}
+
+// reset forgets the current draft
+func (c *ComposeView) reset() {
+	c.account = nil
+	c.draft = nil
+	c.draftID = ""
+	c.saving = false
+	c.resave = false
+	c.closing = false
+}
+
+// send composes the draft and delivers it, after the configured undo delay
+// if there is one. The review screen is left during the delay and the
+// status bar counts down.
+func (c *ComposeView) send() tea.Cmd {
+	draft := c.draft
+	raw, err := composer.Compose(draft)
+	if err != nil {
+		return reportError(err)
+	}
+
+	delay := time.Duration(c.config.Email.SendDelay) * time.Second
+	if delay <= 0 {
+		c.sending = true
+		return c.deliver(c.account, draft, c.draftID, raw)
+	}
+
+	// Only the latest message can be taken back: an earlier one goes now
+	var previous tea.Cmd
+	if c.pending != nil {
+		previous = c.deliver(c.pending.account, c.pending.draft, c.pending.draftID, c.pending.raw)
+	}
+
+	c.pending = &pendingSend{account: c.account, draft: draft, draftID: c.draftID, raw: raw, deadline: time.Now().Add(delay)}
+	c.reset()
+	return tea.Batch(previous, closeCompose(countdownStatus(delay)), countdownTick(c.pending, delay))
+}
+
+// deliver hands a message to the mail transfer agent and saves a copy in
+// the Sent folder. When the transfer agent fails, for instance while
+// offline, the message is queued in the outbox to be retried. A delivery is
+// never cancelled halfway, so it does not run as an operation.
+func (c *ComposeView) deliver(account *Account, draft *composer.Message, draftID string, raw []byte) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		if err := account.Send(ctx, raw); err != nil {
+			// Only failures of the transfer agent itself are queued; a
+			// message it cannot accept stays here to be fixed
+			var sendErr *email.SendError
+			if !errors.As(err, &sendErr) {
+				return sentMsg{account: account, draft: draft, err: err}
+			}
+			if _, queueErr := c.outbox.Enqueue(raw, err); queueErr != nil {
+				return sentMsg{account: account, draft: draft, err: errors.Join(err, queueErr)}
+			}
+			return sentMsg{account: account, draft: draft, draftID: draftID, err: err, queued: true}
+		}
+		return sentMsg{account: account, draft: draft, draftID: draftID, saveErr: saveSent(account, raw)}
+	}
+}
+
+// sendLater schedules the draft to be sent at the time typed by the user.
+// The message is dated with that time.
+func (c *ComposeView) sendLater(when string) tea.Cmd {
+	at, err := schedule.ParseTime(when, time.Now())
+	if err != nil {
+		return reportError(err)
+	}
+
+	draft := c.draft
+	draft.Date = at
+	raw, err := composer.Compose(draft)
+	if err != nil {
+		draft.Date = time.Time{}
+		return reportError(err)
+	}
+
+	c.sending = true
+	return func() tea.Msg {
+		if _, err := c.schedule.Add(raw, at); err != nil {
+			return scheduledMsg{draft: draft, err: err}
+		}
+		return scheduledMsg{draft: draft, at: at}
+	}
+}
+
+// countdownTick schedules the next step of the undo countdown, on the
+// second boundary so the displayed count stays exact
+func countdownTick(pending *pendingSend, remaining time.Duration) tea.Cmd {
+	next := remaining % time.Second
+	if next == 0 {
+		next = time.Second
+	}
+	return tea.Tick(next, func(time.Time) tea.Msg {
+		return sendCountdownMsg{pending: pending}
+	})
+}
+
+// countdownStatus describes a message waiting for the undo delay
+func countdownStatus(remaining time.Duration) string {
+	seconds := int((remaining + time.Second - 1) / time.Second)
+	return fmt.Sprintf("Sending in %ds, press u to undo", seconds)
+}
+
+// showComposeStatus returns a command showing the progress of a send
+func showComposeStatus(status string) tea.Cmd {
+	return func() tea.Msg {
+		return composeStatusMsg{status: status}
+	}
+}
+
+// closeCompose returns a command leaving the review screen with a status message
+func closeCompose(status string) tea.Cmd {
+	return func() tea.Msg {
+		return composeClosedMsg{status: status}
+	}
+}
+
+// editorCommand returns the command line of the user's editor
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// formatSize renders a byte count for display
+func formatSize(size int) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"context"
+	"errors"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/romaintb/mel/internal/email"
+)
+
+// errorMsg reports a failed backend call so it can be shown in the status bar
+type errorMsg struct {
+	err error
+}
+
+// reportError returns a command that shows err in the status bar.
+// Cancellations are requested by the user and are not reported.
+func reportError(err error) tea.Cmd {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return func() tea.Msg {
+		return errorMsg{err: err}
+	}
+}
+
+// formatError renders an error for the status bar, with a fix when one is known
+func formatError(err error) string {
+	text := "Error: " + err.Error()
+	if hint := email.Hint(err); hint != "" {
+		text += " (" + hint + ")"
+	}
+	return text
+}
@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"context"
+	"sync"
+)
+
+// Operations tracks the backend calls the UI has in flight so they can be
+// cancelled, either by starting a newer call of the same kind or by the user
+type Operations struct {
+	mu      sync.Mutex
+	running map[string]*operation
+}
+
+// operation is a single in-flight call
+type operation struct {
+	cancel context.CancelFunc
+}
+
+// NewOperations creates an empty operation tracker
+func NewOperations() *Operations {
+	return &Operations{running: make(map[string]*operation)}
+}
+
+// Start begins a named operation, cancelling any previous one with the same
+// name. The returned done function must be called once the call returns.
+func (o *Operations) Start(name string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	op := &operation{cancel: cancel}
+
+	o.mu.Lock()
+	if previous, ok := o.running[name]; ok {
+		previous.cancel()
+	}
+	o.running[name] = op
+	o.mu.Unlock()
+
+	done := func() {
+		cancel()
+		o.mu.Lock()
+		// A newer operation may have replaced this one already
+		if o.running[name] == op {
+			delete(o.running, name)
+		}
+		o.mu.Unlock()
+	}
+	return ctx, done
+}
+
+// CancelAll cancels every in-flight operation and returns how many there were
+func (o *Operations) CancelAll() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	count := len(o.running)
+	for name, op := range o.running {
+		op.cancel()
+		delete(o.running, name)
+	}
+	return count
+}
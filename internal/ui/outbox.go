@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/romaintb/mel/internal/outbox"
+)
+
+// outboxName is the name of the Outbox pseudo-folder
+const outboxName = "Outbox"
+
+// outboxRetryInterval is how often queued messages are checked; each one
+// is retried only once its own backoff delay is over
+const outboxRetryInterval = 30 * time.Second
+
+// outboxTickMsg wakes up the outbox worker
+type outboxTickMsg struct{}
+
+// outboxLoadedMsg carries the messages waiting in the outbox
+type outboxLoadedMsg struct {
+	items []*outbox.Item
+	err   error
+}
+
+// outboxFlushedMsg reports how many queued messages were sent
+type outboxFlushedMsg struct {
+	sent int
+	err  error
+}
+
+// outboxTick schedules the next run of the outbox worker
+func outboxTick() tea.Cmd {
+	return tea.Tick(outboxRetryInterval, func(time.Time) tea.Msg {
+		return outboxTickMsg{}
+	})
+}
+
+// loadOutbox lists the queued messages
+func (u *UI) loadOutbox() tea.Cmd {
+	return func() tea.Msg {
+		items, err := u.outbox.List()
+		return outboxLoadedMsg{items: items, err: err}
+	}
+}
+
+// flushOutbox sends the queued messages the mode selects; with an id, only
+// that message. Each goes through the account its
+// sender address belongs to and is saved to that account's Sent folder like
+// any other. Flushes are not tied to an operation, so cancelling the UI's
+// slow operations never interrupts a delivery.
+func (u *UI) flushOutbox(mode outbox.FlushMode, id string) tea.Cmd {
+	fallback := u.current
+	return func() tea.Msg {
+		var saveErrs []error
+		send := func(ctx context.Context, message []byte) error {
+			account := AccountFor(u.accounts, fallback, message)
+			if err := account.Send(ctx, message); err != nil {
+				return err
+			}
+			saveErrs = append(saveErrs, saveSent(account, message))
+			return nil
+		}
+		sent, err := u.outbox.Flush(context.Background(), send, mode, id)
+		return outboxFlushedMsg{sent: sent, err: errors.Join(append([]error{err}, saveErrs...)...)}
+	}
+}
+
+// removeFromOutbox drops a queued message without sending it
+func (u *UI) removeFromOutbox(id string) tea.Cmd {
+	return func() tea.Msg {
+		if err := u.outbox.Remove(id); err != nil {
+			return errorMsg{err: err}
+		}
+		return outboxFlushedMsg{}
+	}
+}
+
+// handleOutboxLoaded refreshes the sidebar entry and the open outbox listing
+func (u *UI) handleOutboxLoaded(msg outboxLoadedMsg) tea.Cmd {
+	if msg.err != nil {
+		return reportError(msg.err)
+	}
+	u.outboxItems = msg.items
+	u.sidebar.SetPseudoFolder(outboxName, "outbox", outboxLabel(msg.items))
+	if u.threadList.Pseudo() == outboxName {
+		u.threadList.ShowItems(outboxName, outboxItems(msg.items))
+	}
+	return nil
+}
+
+// openOutboxItem retries the selected queued message now, failed or not
+func (u *UI) openOutboxItem() tea.Cmd {
+	item := u.threadList.Current()
+	if item == nil {
+		return nil
+	}
+	u.statusBar.SetMessage("Retrying " + item.Subject + "…")
+	return u.flushOutbox(outbox.FlushAll, item.ID)
+}
+
+// outboxLabel formats the Outbox pseudo-folder with its pending and failed
+// counts; it is empty, hiding the entry, when nothing is waiting
+func outboxLabel(items []*outbox.Item) string {
+	if len(items) == 0 {
+		return ""
+	}
+	failed := 0
+	for _, item := range items {
+		if item.Failed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Sprintf("%s (%d pending, %d failed)", outboxName, len(items)-failed, failed)
+	}
+	return fmt.Sprintf("%s (%d pending)", outboxName, len(items))
+}
+
+// outboxItems converts queued messages to thread list entries showing their
+// delivery state and last error
+func outboxItems(items []*outbox.Item) []ThreadItem {
+	result := make([]ThreadItem, 0, len(items))
+	for _, item := range items {
+		state := "retry at " + item.NextAttempt.Format("15:04")
+		if item.Failed {
+			state = "failed"
+		}
+		if item.LastError != "" {
+			state += ": " + truncate(item.LastError, 60)
+		}
+		result = append(result, ThreadItem{
+			ID:         item.ID,
+			Subject:    item.Subject,
+			Recipients: strings.Join(item.To, ", "),
+			Date:       state,
+		})
+	}
+	return result
+}
+
+// saveSent stores a copy of a sent message in the account's Sent folder,
+// tagged sent, so replies show up in their threads. It does nothing when no
+// Sent folder is configured.
+func saveSent(account *Account, message []byte) error {
+	if account.Config.Folders.Sent == "" {
+		return nil
+	}
+	// The message is gone already: this must not be cancelled
+	if err := account.Backend.StoreMessage(context.Background(), account.Config.Folders.Sent, message, []string{"sent"}); err != nil {
+		return fmt.Errorf("failed to save sent message: %w", err)
+	}
+	return nil
+}
+
+// truncate shortens text to at most n runes, marking the cut
+func truncate(text string, n int) string {
+	if runes := []rune(text); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,202 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/romaintb/mel/internal/composer"
+	"github.com/romaintb/mel/internal/email"
+	"github.com/romaintb/mel/internal/schedule"
+)
+
+// scheduledName is the name of the Scheduled pseudo-folder
+const scheduledName = "Scheduled"
+
+// scheduleCheckInterval is how often scheduled messages are checked
+const scheduleCheckInterval = 30 * time.Second
+
+// scheduleTickMsg wakes up the scheduled send dispatcher
+type scheduleTickMsg struct{}
+
+// scheduleLoadedMsg carries the scheduled messages
+type scheduleLoadedMsg struct {
+	entries []*schedule.Entry
+	err     error
+}
+
+// scheduleDispatchedMsg reports scheduled messages that were sent or
+// cancelled
+type scheduleDispatchedMsg struct {
+	status string
+	err    error
+}
+
+// scheduleTick schedules the next check for messages due
+func scheduleTick() tea.Cmd {
+	return tea.Tick(scheduleCheckInterval, func(time.Time) tea.Msg {
+		return scheduleTickMsg{}
+	})
+}
+
+// loadSchedule lists the scheduled messages
+func (u *UI) loadSchedule() tea.Cmd {
+	return func() tea.Msg {
+		entries, err := u.schedule.List()
+		return scheduleLoadedMsg{entries: entries, err: err}
+	}
+}
+
+// dispatchScheduled sends the scheduled messages that are due. Messages the
+// transfer agent fails to send go to the outbox to be retried from there.
+func (u *UI) dispatchScheduled() tea.Cmd {
+	fallback := u.current
+	return func() tea.Msg {
+		var errs []error
+		queued := 0
+		send := func(ctx context.Context, message []byte) error {
+			account := AccountFor(u.accounts, fallback, message)
+			if err := account.Send(ctx, message); err != nil {
+				var sendErr *email.SendError
+				if !errors.As(err, &sendErr) {
+					return err
+				}
+				if _, err := u.outbox.Enqueue(message, err); err != nil {
+					return err
+				}
+				queued++
+				return nil
+			}
+			errs = append(errs, saveSent(account, message))
+			return nil
+		}
+
+		sent, err := u.schedule.Dispatch(context.Background(), send, time.Now())
+		msg := scheduleDispatchedMsg{err: errors.Join(append([]error{err}, errs...)...)}
+		switch {
+		case queued > 0:
+			msg.status = fmt.Sprintf("%d scheduled message(s) could not be sent and wait in %s", queued, outboxName)
+		case sent > 0:
+			msg.status = fmt.Sprintf("Sent %d scheduled message(s)", sent)
+		}
+		return msg
+	}
+}
+
+// handleScheduleLoaded refreshes the sidebar entry and the open listing
+func (u *UI) handleScheduleLoaded(msg scheduleLoadedMsg) tea.Cmd {
+	if msg.err != nil {
+		return reportError(msg.err)
+	}
+	u.scheduled = msg.entries
+	label := ""
+	if len(msg.entries) > 0 {
+		label = fmt.Sprintf("%s (%d)", scheduledName, len(msg.entries))
+	}
+	u.sidebar.SetPseudoFolder(scheduledName, "scheduled", label)
+	if u.threadList.Pseudo() == scheduledName {
+		u.threadList.ShowItems(scheduledName, scheduledItems(msg.entries))
+	}
+	return nil
+}
+
+// editScheduled unschedules the selected message and reopens it in the
+// compose flow. It is saved to the Drafts folder first, so it is not lost if
+// the edit is abandoned.
+func (u *UI) editScheduled() tea.Cmd {
+	item := u.threadList.Current()
+	if item == nil {
+		return nil
+	}
+	fallback := u.current
+	return func() tea.Msg {
+		var result composeMsg
+		err := u.schedule.Cancel(item.ID, func(message []byte) error {
+			account := AccountFor(u.accounts, fallback, message)
+			draft, draftID, err := keepAsDraft(account, message)
+			result = composeMsg{account: account, draft: draft, draftID: draftID}
+			return err
+		})
+		if err != nil {
+			return composeMsg{err: err}
+		}
+		return result
+	}
+}
+
+// cancelScheduled unschedules the selected message, keeping it as a draft
+func (u *UI) cancelScheduled() tea.Cmd {
+	item := u.threadList.Current()
+	if item == nil {
+		return nil
+	}
+	fallback := u.current
+	return func() tea.Msg {
+		var account *Account
+		err := u.schedule.Cancel(item.ID, func(message []byte) error {
+			account = AccountFor(u.accounts, fallback, message)
+			_, _, err := keepAsDraft(account, message)
+			return err
+		})
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		return scheduleDispatchedMsg{status: "Scheduled message moved to " + account.Config.Folders.Drafts}
+	}
+}
+
+// keepAsDraft saves a scheduled message to the account's Drafts folder and
+// reads it back as a draft, returning the Message-ID of the saved copy
+func keepAsDraft(account *Account, message []byte) (*composer.Message, string, error) {
+	draft, err := composer.ReadDraft(bytes.NewReader(message))
+	if err != nil {
+		return nil, "", err
+	}
+	parsed, err := email.ParseMessage(bytes.NewReader(message))
+	if err != nil {
+		return nil, "", err
+	}
+	if err := account.Backend.StoreMessage(context.Background(), account.Config.Folders.Drafts, message, []string{"draft"}); err != nil {
+		return nil, "", fmt.Errorf("failed to save draft: %w", err)
+	}
+	return draft, parsed.ID, nil
+}
+
+// scheduledItems converts scheduled messages to thread list entries showing
+// when they go out
+func scheduledItems(entries []*schedule.Entry) []ThreadItem {
+	result := make([]ThreadItem, 0, len(entries))
+	for _, entry := range entries {
+		state := entry.SendAt.Format("Mon 2 Jan 15:04")
+		if entry.LastError != "" {
+			state += ", failed: " + truncate(entry.LastError, 60)
+		}
+		result = append(result, ThreadItem{
+			ID:         entry.ID,
+			Subject:    entry.Subject,
+			Recipients: strings.Join(entry.To, ", "),
+			Date:       state,
+		})
+	}
+	return result
+}
+
+// showPseudoFolder lists the entries of a pseudo-folder in the thread list
+func (u *UI) showPseudoFolder(name string) tea.Cmd {
+	switch name {
+	case allInboxesName:
+		u.statusBar.SetMessage("Loading all inboxes…")
+		return u.threadList.LoadInboxes(u.accounts)
+	case outboxName:
+		u.threadList.ShowItems(outboxName, outboxItems(u.outboxItems))
+		u.statusBar.SetMessage("Outbox: enter to retry now, d to remove")
+	case scheduledName:
+		u.threadList.ShowItems(scheduledName, scheduledItems(u.scheduled))
+		u.statusBar.SetMessage("Scheduled: enter to edit, d to cancel and keep as a draft")
+	}
+	return nil
+}
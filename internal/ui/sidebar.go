@@ -13,31 +13,56 @@ import (
 
 // Sidebar represents the left sidebar with account/folder tree
 type Sidebar struct {
-	config         *config.Config
-	emailManager   *email.Manager
-	iconService    *icons.Service
-	width          int
-	height         int
-	focused        bool
-	collapsed      bool
-	selectedIndex  int                 // Index of selected item
-	folders        []*email.MailFolder // Actual mail folders
-	selectedFolder string              // Currently selected folder
+	config          *config.Config
+	operations      *Operations
+	iconService     *icons.Service
+	width           int
+	height          int
+	focused         bool
+	collapsed       bool
+	selectedIndex   int            // Index of selected item
+	groups          []*folderGroup // Folders of each account
+	selectedAccount *Account       // Account of the selected folder
+	selectedFolder  string         // Currently selected folder
+	pseudoFolders   []pseudoFolder // Listed after the mail folders
+}
+
+// folderGroup holds the folders of one account
+type folderGroup struct {
+	account *Account
+	folders []*email.MailFolder
+
+	// Progressive folder listing in flight, if any
+	updates <-chan email.FolderUpdate
+	done    func()
+}
+
+// sidebarItem is one selectable line of the sidebar: an account header, a
+// folder or a pseudo-folder
+type sidebarItem struct {
+	group  *folderGroup      // Account of headers and folders
+	folder *email.MailFolder // Nil for account headers
+	pseudo *pseudoFolder
 }
 
 // NewSidebar creates a new sidebar instance
-func NewSidebar(cfg *config.Config, emailManager *email.Manager, iconService *icons.Service) (*Sidebar, error) {
+func NewSidebar(cfg *config.Config, accounts []*Account, current *Account, operations *Operations, iconService *icons.Service) (*Sidebar, error) {
+	groups := make([]*folderGroup, 0, len(accounts))
+	for _, account := range accounts {
+		groups = append(groups, &folderGroup{account: account, folders: []*email.MailFolder{}})
+	}
 	return &Sidebar{
-		config:         cfg,
-		emailManager:   emailManager,
-		iconService:    iconService,
-		width:          0, // Will be set by Resize
-		height:         0,
-		focused:        false,
-		collapsed:      false,
-		selectedIndex:  0, // Start with first item selected
-		folders:        []*email.MailFolder{},
-		selectedFolder: "",
+		config:          cfg,
+		operations:      operations,
+		iconService:     iconService,
+		width:           0, // Will be set by Resize
+		height:          0,
+		focused:         false,
+		collapsed:       false,
+		selectedIndex:   0, // Start with first item selected
+		groups:          groups,
+		selectedAccount: current,
+		selectedFolder:  current.Config.Folders.Inbox,
 	}, nil
 }
 
@@ -46,46 +71,160 @@ func (s *Sidebar) Init() tea.Cmd {
 	return s.refreshFolders()
 }
 
-// refreshFolders refreshes the folder list from the email manager
+// refreshFolders starts a progressive folder listing for every account.
+// Folders are shown as soon as a backend has listed them and counts fill in
+// as they arrive.
 func (s *Sidebar) refreshFolders() tea.Cmd {
-	return func() tea.Msg {
-		folders, err := s.emailManager.GetMailFolders()
-		if err != nil {
-			// Log error for debugging
-			fmt.Printf("Error refreshing folders: %v\n", err)
-			return foldersRefreshedMsg{folders: []*email.MailFolder{}, err: err}
+	var cmds []tea.Cmd
+	for _, group := range s.groups {
+		cmds = append(cmds, s.refreshGroup(group))
+	}
+	return tea.Batch(cmds...)
+}
+
+// RefreshAccount lists the folders of one account again, such as after it
+// was synced
+func (s *Sidebar) RefreshAccount(account *Account) tea.Cmd {
+	for _, group := range s.groups {
+		if group.account == account {
+			return s.refreshGroup(group)
 		}
-		return foldersRefreshedMsg{folders: folders, err: nil}
 	}
+	return nil
 }
 
-// foldersRefreshedMsg is sent when folders are refreshed
-type foldersRefreshedMsg struct {
-	folders []*email.MailFolder
-	err     error
+// refreshGroup starts a progressive folder listing for one account
+func (s *Sidebar) refreshGroup(group *folderGroup) tea.Cmd {
+	// Starting a new listing cancels the one in flight, if any
+	ctx, done := s.operations.Start("folders " + group.account.Config.Name)
+	group.updates = group.account.Backend.StreamMailFolders(ctx)
+	group.done = done
+	return waitForFolderUpdate(group, group.updates)
+}
+
+// waitForFolderUpdate waits for the next step of a folder listing
+func waitForFolderUpdate(group *folderGroup, updates <-chan email.FolderUpdate) tea.Cmd {
+	return func() tea.Msg {
+		update, ok := <-updates
+		return folderUpdateMsg{group: group, update: update, updates: updates, done: !ok}
+	}
+}
+
+// folderUpdateMsg is sent for each step of a folder listing
+type folderUpdateMsg struct {
+	group   *folderGroup
+	update  email.FolderUpdate
+	updates <-chan email.FolderUpdate // Listing the update belongs to
+	done    bool                      // Whether the listing is complete
 }
 
 // FolderSelectedMsg is sent when a folder is selected in the sidebar
 type FolderSelectedMsg struct {
+	Account    *Account
 	FolderName string
 }
 
+// pseudoFolder is a sidebar entry for one of mel's own queues, such as the
+// outbox, rather than a mail folder
+type pseudoFolder struct {
+	name  string
+	icon  string // Name of the icon
+	label string // Name and counts as displayed
+}
+
+// pseudoFolderSelectedMsg is sent when a pseudo-folder is selected
+type pseudoFolderSelectedMsg struct {
+	name string
+}
+
 // Update handles sidebar updates
 func (s *Sidebar) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		return s.handleKeyPress(msg)
-	case foldersRefreshedMsg:
-		s.folders = s.filterMasterFolders(msg.folders)
-		// Set default selection to first folder if available
-		if len(s.folders) > 0 && s.selectedFolder == "" {
-			s.selectedFolder = s.folders[0].Name
-		}
-		return s, nil
+	case folderUpdateMsg:
+		return s, s.applyFolderUpdate(msg)
 	}
 	return s, nil
 }
 
+// applyFolderUpdate applies one step of a folder listing and waits for the next
+func (s *Sidebar) applyFolderUpdate(msg folderUpdateMsg) tea.Cmd {
+	group := msg.group
+	if msg.done {
+		if msg.updates == group.updates {
+			group.done()
+			group.updates = nil
+		}
+		return nil
+	}
+
+	// Drain listings superseded by a refresh without applying them
+	if msg.updates != group.updates {
+		return waitForFolderUpdate(group, msg.updates)
+	}
+
+	// Keep listing what we can; errors go to the status bar
+	next := tea.Batch(waitForFolderUpdate(group, msg.updates), reportError(msg.update.Err))
+
+	if msg.update.Folders != nil {
+		group.folders = s.filterMasterFolders(msg.update.Folders)
+		// Keep the selection on the same folder after a refresh
+		s.keepSelection()
+	}
+
+	if counts := msg.update.Counts; counts != nil {
+		for _, folder := range group.folders {
+			if folder.Name == counts.Name {
+				counts.ApplyTo(folder)
+				break
+			}
+		}
+	}
+
+	return next
+}
+
+// items returns the selectable lines of the sidebar. Folders are grouped
+// under a header per account when there are several.
+func (s *Sidebar) items() []sidebarItem {
+	var items []sidebarItem
+	for _, group := range s.groups {
+		if len(s.groups) > 1 {
+			items = append(items, sidebarItem{group: group})
+		}
+		for _, folder := range group.folders {
+			items = append(items, sidebarItem{group: group, folder: folder})
+		}
+	}
+	for i := range s.pseudoFolders {
+		items = append(items, sidebarItem{pseudo: &s.pseudoFolders[i]})
+	}
+	return items
+}
+
+// keepSelection moves the selection back to the selected folder after the
+// list changed
+func (s *Sidebar) keepSelection() {
+	items := s.items()
+	for i, item := range items {
+		if item.folder != nil && item.group.account == s.selectedAccount && item.folder.Name == s.selectedFolder {
+			s.selectedIndex = i
+			return
+		}
+	}
+	if s.selectedIndex >= len(items) {
+		s.selectedIndex = max(len(items)-1, 0)
+	}
+}
+
+// SelectAccount moves the selection to the inbox of an account
+func (s *Sidebar) SelectAccount(account *Account) {
+	s.selectedAccount = account
+	s.selectedFolder = account.Config.Folders.Inbox
+	s.keepSelection()
+}
+
 // View renders the sidebar
 func (s *Sidebar) View() string {
 	if s.width == 0 {
@@ -101,7 +240,8 @@ func (s *Sidebar) View() string {
 	result += s.iconService.Get("email") + " Mail Folders\n"
 	result += "──────────────\n"
 
-	if len(s.folders) == 0 {
+	items := s.items()
+	if len(items) == 0 {
 		result += "├── No folders found\n"
 		result += "└── Check your mail directory\n"
 		return result
@@ -117,15 +257,16 @@ func (s *Sidebar) View() string {
 	}
 
 	// Determine which folders to display based on available height
+	itemCount := len(items)
 	startIndex := 0
-	endIndex := len(s.folders)
+	endIndex := itemCount
 
 	// Always limit to available height
 	if endIndex > availableHeight {
 		endIndex = availableHeight
 	}
 
-	if availableHeight < len(s.folders) {
+	if availableHeight < itemCount {
 		// Need to scroll - show subset of folders
 		// Center the selection
 		startIndex = s.selectedIndex - (availableHeight / 2)
@@ -133,23 +274,22 @@ func (s *Sidebar) View() string {
 			startIndex = 0
 		}
 		endIndex = startIndex + availableHeight
-		if endIndex > len(s.folders) {
-			endIndex = len(s.folders)
+		if endIndex > itemCount {
+			endIndex = itemCount
 			startIndex = endIndex - availableHeight
 		}
 	}
 
 	// Display folders within the available height
 	for i := startIndex; i < endIndex; i++ {
-		folder := s.folders[i]
 		var prefix string
 
 		// Show scroll indicators
 		if i == startIndex && startIndex > 0 {
 			prefix = s.iconService.Get("scrollUp") + "── "
-		} else if i == endIndex-1 && endIndex < len(s.folders) {
+		} else if i == endIndex-1 && endIndex < itemCount {
 			prefix = s.iconService.Get("scrollDown") + "── "
-		} else if i == len(s.folders)-1 {
+		} else if i == itemCount-1 {
 			prefix = "└── "
 		} else {
 			prefix = "├── "
@@ -158,11 +298,20 @@ func (s *Sidebar) View() string {
 		// Check if this folder is selected
 		isSelected := s.selectedIndex == i
 
-		// Get appropriate icon for the folder
-		icon := s.getFolderIcon(folder)
-
-		// Format the folder name with counts
-		folderDisplay := s.formatFolderDisplay(folder)
+		var icon, folderDisplay string
+		switch item := items[i]; {
+		case item.pseudo != nil:
+			icon = s.iconService.Get(item.pseudo.icon)
+			folderDisplay = item.pseudo.label
+		case item.folder != nil:
+			icon = s.getFolderIcon(item.folder)
+			folderDisplay = s.formatFolderDisplay(item.folder) + s.syncMark(item.group.account, item.folder.Name)
+		default:
+			// Account headers stand out of the folder tree
+			prefix = ""
+			icon = s.iconService.Get("account")
+			folderDisplay = item.group.account.Config.Name + s.syncMark(item.group.account, "")
+		}
 
 		// Build the complete line with width constraint
 		var line string
@@ -211,6 +360,22 @@ func (s *Sidebar) getFolderIcon(folder *email.MailFolder) string {
 	}
 }
 
+// syncMark returns the icon shown after an account or folder while it is
+// syncing, or when its last sync failed
+func (s *Sidebar) syncMark(account *Account, folderName string) string {
+	if account.Syncer == nil {
+		return ""
+	}
+	state := account.Syncer.State(folderName)
+	switch {
+	case state.Running:
+		return " " + s.iconService.Get("syncing")
+	case state.LastError != nil:
+		return " " + s.iconService.Get("syncError")
+	}
+	return ""
+}
+
 // formatFolderDisplay formats the folder display with counts
 // This function ensures that folder names never wrap to multiple lines by truncating
 // long names and adding ellipsis (...) when necessary.
@@ -225,6 +390,25 @@ func (s *Sidebar) formatFolderDisplay(folder *email.MailFolder) string {
 	return folderName
 }
 
+// SetPseudoFolder adds, updates or, with an empty label, removes a
+// pseudo-folder. Pseudo-folders are only listed while they hold something.
+func (s *Sidebar) SetPseudoFolder(name, icon, label string) {
+	var folders []pseudoFolder
+	for _, folder := range s.pseudoFolders {
+		if folder.name != name {
+			folders = append(folders, folder)
+		}
+	}
+	if label != "" {
+		folders = append(folders, pseudoFolder{name: name, icon: icon, label: label})
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		return folders[i].name < folders[j].name
+	})
+	s.pseudoFolders = folders
+	s.keepSelection()
+}
+
 // Focus focuses the sidebar
 func (s *Sidebar) Focus() tea.Cmd {
 	s.focused = true
@@ -284,7 +468,7 @@ func (s *Sidebar) GoToBottom() tea.Cmd {
 
 // getItemCount returns the total number of selectable items
 func (s *Sidebar) getItemCount() int {
-	return len(s.folders) // All folders are selectable
+	return len(s.items()) // All folders are selectable
 }
 
 // handleKeyPress handles key presses in the sidebar
@@ -313,28 +497,52 @@ func (s *Sidebar) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return s, nil
 }
 
-// selectCurrentItem selects the currently highlighted item
+// selectCurrentItem selects the currently highlighted item. Selecting an
+// account header opens the account's inbox.
 func (s *Sidebar) selectCurrentItem() tea.Cmd {
-	if s.selectedIndex < len(s.folders) {
-		// Select a folder
-		s.selectedFolder = s.folders[s.selectedIndex].Name
-		// Return message to notify that folder was selected
+	items := s.items()
+	if s.selectedIndex < 0 || s.selectedIndex >= len(items) {
+		return nil
+	}
+
+	item := items[s.selectedIndex]
+	if item.pseudo != nil {
+		name := item.pseudo.name
 		return func() tea.Msg {
-			return FolderSelectedMsg{FolderName: s.selectedFolder}
+			return pseudoFolderSelectedMsg{name: name}
 		}
+	}
+
+	account := item.group.account
+	s.selectedAccount = account
+	if item.folder != nil {
+		s.selectedFolder = item.folder.Name
 	} else {
-		// Handle quick actions
-		actionIndex := s.selectedIndex - len(s.folders)
-		switch actionIndex {
-		case 0: // Compose
-			// TODO: Trigger compose action
-		case 1: // Search
-			// TODO: Trigger search action
-		case 2: // Settings
-			// TODO: Trigger settings action
-		}
+		s.selectedFolder = account.Config.Folders.Inbox
+	}
+	folderName := s.selectedFolder
+	// Return message to notify that folder was selected
+	return func() tea.Msg {
+		return FolderSelectedMsg{Account: account, FolderName: folderName}
+	}
+}
+
+// SelectedTarget returns the account and folder under the cursor, with an
+// empty folder name on an account header. It returns nil on a pseudo-folder.
+func (s *Sidebar) SelectedTarget() (*Account, string) {
+	items := s.items()
+	if s.selectedIndex < 0 || s.selectedIndex >= len(items) {
+		return nil, ""
+	}
+	item := items[s.selectedIndex]
+	switch {
+	case item.pseudo != nil:
+		return nil, ""
+	case item.folder != nil:
+		return item.group.account, item.folder.Name
+	default:
+		return item.group.account, ""
 	}
-	return nil
 }
 
 // GetSelectedFolder returns the currently selected folder
@@ -13,6 +13,8 @@ type StatusBar struct {
 	message    string
 	mode       string
 	focusedBox string
+	account    string // Shown when there are several accounts
+	sync       string // Stage of the sync in progress, if any
 }
 
 // NewStatusBar creates a new status bar instance
@@ -48,9 +50,15 @@ func (s *StatusBar) View() string {
 
 	// Left side: mode, focused box, and message
 	left := "[" + s.mode + "][" + s.focusedBox + "] " + s.message
+	if s.account != "" {
+		left = "[" + s.mode + "][" + s.focusedBox + "][" + s.account + "] " + s.message
+	}
 
-	// Right side: shortcuts
-	right := "q:quit h:sidebar l:list i:insert v:visual /:search"
+	// Right side: sync progress and shortcuts
+	right := "q:quit h:sidebar l:list i:compose v:visual /:search"
+	if s.sync != "" {
+		right = "[" + s.sync + "] " + right
+	}
 
 	// Calculate spacing
 	spacing := s.width - len(left) - len(right)
@@ -78,6 +86,16 @@ func (s *StatusBar) SetMode(mode string) {
 	s.mode = mode
 }
 
+// SetAccount sets the name of the current account
+func (s *StatusBar) SetAccount(account string) {
+	s.account = account
+}
+
+// SetSync sets the stage of the sync in progress, or clears it
+func (s *StatusBar) SetSync(stage string) {
+	s.sync = stage
+}
+
 // SetFocusedBox sets the currently focused box
 func (s *StatusBar) SetFocusedBox(box string) {
 	s.focusedBox = box
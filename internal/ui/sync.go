@@ -0,0 +1,260 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/romaintb/mel/internal/email"
+	"github.com/romaintb/mel/internal/outbox"
+)
+
+// maxAutoSyncDelay caps the delay between background syncs as failures
+// back off
+const maxAutoSyncDelay = time.Hour
+
+// offlineCheckInterval is how often the background sync checks whether the
+// network is back
+const offlineCheckInterval = time.Minute
+
+// autoSyncTickMsg wakes up the background sync
+type autoSyncTickMsg struct{}
+
+// autoSyncedMsg reports a background sync of every account
+type autoSyncedMsg struct {
+	results []syncedMsg
+}
+
+// syncStageMsg reports the stage a sync in progress reached
+type syncStageMsg struct {
+	target  string // Account or folder synced
+	stage   email.SyncStage
+	updates <-chan tea.Msg // Progress of the sync, ending with its result
+}
+
+// autoSync schedules the background sync of every account
+type autoSync struct {
+	interval time.Duration // Zero when auto-sync is disabled
+	failures int           // Failed syncs in a row
+	offline  bool          // Whether the last sync found the network down
+}
+
+// newAutoSync creates the background sync schedule from the configured
+// interval in seconds
+func newAutoSync(seconds int) *autoSync {
+	return &autoSync{interval: time.Duration(max(seconds, 0)) * time.Second}
+}
+
+// delay returns how long to wait before the next background sync: the
+// interval, doubled after each failure in a row. While offline, syncs are
+// paused and only retried now and then to notice the network is back.
+func (a *autoSync) delay() time.Duration {
+	if a.offline {
+		return min(a.interval, offlineCheckInterval)
+	}
+	delay := a.interval
+	for i := 0; i < a.failures && delay < maxAutoSyncDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxAutoSyncDelay)
+}
+
+// tick schedules the next background sync, unless auto-sync is disabled
+func (a *autoSync) tick() tea.Cmd {
+	if a.interval == 0 {
+		return nil
+	}
+	return tea.Tick(a.delay(), func(time.Time) tea.Msg {
+		return autoSyncTickMsg{}
+	})
+}
+
+// syncedMsg reports the end of a sync
+type syncedMsg struct {
+	account *Account
+	folder  string // Empty when the whole account was synced
+	err     error
+}
+
+// syncAccount fetches new mail for an account, or only one of its folders,
+// and indexes it. Syncs run one at a time so the index is never updated
+// twice at once; esc cancels the one running.
+func (u *UI) syncAccount(account *Account, folderName string) tea.Cmd {
+	if u.syncing {
+		u.statusBar.SetMessage("A sync is already running")
+		return nil
+	}
+	// Some sync tools, or mbsync without a channel, only sync everything
+	if !account.Syncer.SyncsFolders() {
+		folderName = ""
+	}
+
+	u.statusBar.SetMessage("Syncing " + syncTargetName(account, folderName) + "…")
+	return u.startSync(func(ctx context.Context, report func(string, email.SyncStage)) tea.Msg {
+		err := account.Syncer.Sync(ctx, folderName, func(stage email.SyncStage) {
+			report(syncTargetName(account, folderName), stage)
+		})
+		return syncedMsg{account: account, folder: folderName, err: err}
+	})
+}
+
+// startSync runs syncs in the background as the "sync" operation. Each
+// stage they reach is reported to the status bar, then the message run
+// returns is delivered.
+func (u *UI) startSync(run func(ctx context.Context, report func(target string, stage email.SyncStage)) tea.Msg) tea.Cmd {
+	u.syncing = true
+	ctx, done := u.operations.Start("sync")
+	updates := make(chan tea.Msg)
+	go func() {
+		defer done()
+		report := func(target string, stage email.SyncStage) {
+			updates <- syncStageMsg{target: target, stage: stage, updates: updates}
+		}
+		updates <- run(ctx, report)
+	}()
+	return waitForSync(updates)
+}
+
+// waitForSync waits for the next stage or the result of a sync
+func waitForSync(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
+	}
+}
+
+// handleSyncStage shows the stage a sync reached and waits for the next
+func (u *UI) handleSyncStage(msg syncStageMsg) tea.Cmd {
+	u.statusBar.SetSync(msg.target + ": " + msg.stage.String())
+	return waitForSync(msg.updates)
+}
+
+// handleSynced reports a sync and shows what it brought: folder counts, the
+// threads listed and queued messages, which may go through now
+func (u *UI) handleSynced(msg syncedMsg) tea.Cmd {
+	u.syncing = false
+	u.statusBar.SetSync("")
+	name := syncTargetName(msg.account, msg.folder)
+	// A failed post-new hook still leaves new mail to show
+	refresh := tea.Batch(u.sidebar.RefreshAccount(msg.account), u.refreshThreads(msg.account))
+	if msg.err != nil {
+		return tea.Batch(reportError(fmt.Errorf("failed to sync %s: %w", name, msg.err)), refresh)
+	}
+
+	u.statusBar.SetMessage(fmt.Sprintf("Synced %s at %s", name, time.Now().Format("15:04")))
+	return tea.Batch(refresh, u.flushOutbox(outbox.FlushPending, ""))
+}
+
+// runAutoSync syncs every account in the background, one after the other.
+// The next sync is only scheduled once this one is done, so they never
+// overlap; a sync started by hand delays it instead.
+func (u *UI) runAutoSync() tea.Cmd {
+	if u.syncing {
+		return u.autoSync.tick()
+	}
+
+	accounts := u.accounts
+	return u.startSync(func(ctx context.Context, report func(string, email.SyncStage)) tea.Msg {
+		var results []syncedMsg
+		for _, account := range accounts {
+			err := account.Syncer.Sync(ctx, "", func(stage email.SyncStage) {
+				report(account.Config.Name, stage)
+			})
+			results = append(results, syncedMsg{account: account, err: err})
+			// Other accounts would fail the same way
+			if errors.Is(err, email.ErrOffline) || ctx.Err() != nil {
+				break
+			}
+		}
+		return autoSyncedMsg{results: results}
+	})
+}
+
+// handleAutoSynced refreshes what the background sync brought and
+// schedules the next one. Failures back off; network failures pause syncing
+// until the network is back and are only reported once.
+func (u *UI) handleAutoSynced(msg autoSyncedMsg) tea.Cmd {
+	u.syncing = false
+	u.statusBar.SetSync("")
+
+	var synced, refresh []*Account
+	var errs []error
+	offline := false
+	for _, result := range msg.results {
+		switch {
+		case result.err == nil:
+			synced = append(synced, result.account)
+			refresh = append(refresh, result.account)
+		case errors.Is(result.err, context.Canceled):
+		case errors.Is(result.err, email.ErrOffline):
+			offline = true
+		default:
+			// A failed post-new hook still leaves new mail to show
+			refresh = append(refresh, result.account)
+			errs = append(errs, fmt.Errorf("failed to sync %s: %w", result.account.Config.Name, result.err))
+		}
+	}
+
+	var cmds []tea.Cmd
+	switch {
+	case offline:
+		if !u.autoSync.offline {
+			u.statusBar.SetMessage("Offline: background sync paused until the network is back")
+		}
+		u.autoSync.offline = true
+	case len(errs) > 0:
+		u.autoSync.offline = false
+		u.autoSync.failures++
+		cmds = append(cmds, reportError(errors.Join(errs...)))
+	case len(synced) > 0:
+		if u.autoSync.offline {
+			u.statusBar.SetMessage("Back online")
+		}
+		u.autoSync.offline = false
+		u.autoSync.failures = 0
+	}
+
+	for _, account := range refresh {
+		cmds = append(cmds, u.sidebar.RefreshAccount(account))
+	}
+	if len(refresh) > 0 {
+		cmds = append(cmds, u.refreshThreads(refresh...))
+	}
+	if len(synced) > 0 {
+		cmds = append(cmds, u.flushOutbox(outbox.FlushPending, ""))
+	}
+	return tea.Batch(append(cmds, u.autoSync.tick())...)
+}
+
+// syncSelected syncs the folder or account selected in the sidebar, or the
+// current account elsewhere
+func (u *UI) syncSelected() tea.Cmd {
+	if u.focusedBox == FocusedSidebar {
+		if account, folderName := u.sidebar.SelectedTarget(); account != nil {
+			return u.syncAccount(account, folderName)
+		}
+	}
+	return u.syncAccount(u.current, "")
+}
+
+// refreshThreads reloads the thread list when it shows mail of the accounts
+func (u *UI) refreshThreads(accounts ...*Account) tea.Cmd {
+	if u.threadList.Pseudo() == allInboxesName {
+		return u.threadList.LoadInboxes(u.accounts)
+	}
+	for _, account := range accounts {
+		if u.threadList.Account() == account {
+			return u.threadList.LoadThreads(account, u.threadList.Folder())
+		}
+	}
+	return nil
+}
+
+// syncTargetName names an account, or one of its folders, for the status bar
+func syncTargetName(account *Account, folderName string) string {
+	if folderName == "" {
+		return account.Config.Name
+	}
+	return account.Config.Name + "/" + folderName
+}
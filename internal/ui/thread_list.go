@@ -1,6 +1,10 @@
 package ui
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/romaintb/mel/internal/config"
 	"github.com/romaintb/mel/internal/email"
@@ -10,7 +14,7 @@ import (
 // ThreadList represents the list of email threads
 type ThreadList struct {
 	config       *config.Config
-	emailManager *email.Manager
+	operations   *Operations
 	iconService  *icons.Service
 	width        int
 	height       int
@@ -18,42 +22,50 @@ type ThreadList struct {
 	selected     int
 	scrollOffset int // How many items are scrolled up
 	threads      []ThreadItem
+	account      *Account // Account the folder belongs to
+	folder       string   // Folder the threads were loaded from
+	pseudo       string   // Pseudo-folder shown instead, such as the outbox
 }
 
 // Thread represents an email thread
 type ThreadItem struct {
-	ID      string
-	Subject string
-	From    string
-	Date    string
-	Unread  bool
-	Starred bool
+	ID         string
+	Subject    string
+	From       string
+	Recipients string // Shown instead of the sender for outgoing messages
+	Date       string
+	Unread     bool
+	Starred    bool
+	Account    *Account // Account holding the thread, nil for queued messages
 }
 
 // NewThreadList creates a new thread list instance
-func NewThreadList(cfg *config.Config, emailManager *email.Manager, iconService *icons.Service) (*ThreadList, error) {
+func NewThreadList(cfg *config.Config, account *Account, operations *Operations, iconService *icons.Service) (*ThreadList, error) {
 	return &ThreadList{
-		config:       cfg,
-		emailManager: emailManager,
-		iconService:  iconService,
-		width:        0, // Will be set by Resize
-		height:       0,
-		focused:      false,
-		selected:     0,
-		threads:      []ThreadItem{}, // Start empty, will be populated by LoadThreads
+		config:      cfg,
+		account:     account,
+		operations:  operations,
+		iconService: iconService,
+		width:       0, // Will be set by Resize
+		height:      0,
+		focused:     false,
+		selected:    0,
+		threads:     []ThreadItem{}, // Start empty, will be populated by LoadThreads
 	}, nil
 }
 
 // Init initializes the thread list
 func (t *ThreadList) Init() tea.Cmd {
-	// Load threads from INBOX by default
-	return t.LoadThreads("INBOX")
+	// Load threads from the inbox of the default account
+	return t.LoadThreads(t.account, t.account.Config.Folders.Inbox)
 }
 
 // threadsLoadedMsg is sent when threads are loaded
 type threadsLoadedMsg struct {
-	threads []*email.Thread
+	items   []ThreadItem
+	account *Account
 	folder  string
+	pseudo  string // Set when the threads make up a pseudo-folder
 	err     error
 }
 
@@ -68,15 +80,50 @@ func (t *ThreadList) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return t, nil
 }
 
-// LoadThreads loads threads from a specific folder
-func (t *ThreadList) LoadThreads(folderName string) tea.Cmd {
+// LoadThreads loads threads from a specific folder of an account
+// Loading another folder cancels the load still in flight.
+func (t *ThreadList) LoadThreads(account *Account, folderName string) tea.Cmd {
+	ctx, done := t.operations.Start("threads")
 	return func() tea.Msg {
-		threads, err := t.emailManager.GetThreadsFromFolder(folderName)
+		defer done()
+		threads, err := account.Backend.GetThreadsFromFolder(ctx, folderName)
 		if err != nil {
-			return threadsLoadedMsg{threads: nil, folder: folderName, err: err}
+			return threadsLoadedMsg{account: account, folder: folderName, err: err}
+		}
+
+		return threadsLoadedMsg{items: t.threadItems(account, threads), account: account, folder: folderName}
+	}
+}
+
+// LoadInboxes loads the inboxes of every account into a single list,
+// newest first. Accounts that fail are reported, the others still listed.
+func (t *ThreadList) LoadInboxes(accounts []*Account) tea.Cmd {
+	ctx, done := t.operations.Start("threads")
+	return func() tea.Msg {
+		defer done()
+		var threads []*email.Thread
+		var errs []error
+		owners := make(map[*email.Thread]*Account)
+		for _, account := range accounts {
+			inbox, err := account.Backend.GetThreadsFromFolder(ctx, account.Config.Folders.Inbox)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", account.Config.Name, err))
+				continue
+			}
+			for _, thread := range inbox {
+				owners[thread] = account
+			}
+			threads = append(threads, inbox...)
 		}
+		sort.SliceStable(threads, func(i, j int) bool {
+			return threads[i].Timestamp.After(threads[j].Timestamp)
+		})
 
-		return threadsLoadedMsg{threads: threads, folder: folderName, err: nil}
+		items := []ThreadItem{}
+		for _, thread := range threads {
+			items = append(items, t.threadItems(owners[thread], []*email.Thread{thread})...)
+		}
+		return threadsLoadedMsg{items: items, pseudo: allInboxesName, err: errors.Join(errs...)}
 	}
 }
 
@@ -88,16 +135,10 @@ func (t *ThreadList) getPrimarySender(participants []string) string {
 	return "Unknown"
 }
 
-// handleThreadsLoaded handles when threads are loaded
-func (t *ThreadList) handleThreadsLoaded(msg threadsLoadedMsg) (tea.Model, tea.Cmd) {
-	if msg.err != nil {
-		// On error, keep existing threads but could show error message
-		return t, nil
-	}
-
-	// Convert threads to ThreadItems
+// threadItems converts the threads of an account to list entries
+func (t *ThreadList) threadItems(account *Account, threads []*email.Thread) []ThreadItem {
 	var threadItems []ThreadItem
-	for _, thread := range msg.threads {
+	for _, thread := range threads {
 		from := t.getPrimarySender(thread.Participants)
 		date := thread.Timestamp.Format("2006-01-02")
 		unread := thread.UnreadCount > 0
@@ -109,16 +150,49 @@ func (t *ThreadList) handleThreadsLoaded(msg threadsLoadedMsg) (tea.Model, tea.C
 			Date:    date,
 			Unread:  unread,
 			Starred: false, // TODO: Check if thread is starred
+			Account: account,
 		}
 
 		threadItems = append(threadItems, item)
 	}
+	return threadItems
+}
+
+// handleThreadsLoaded handles when threads are loaded
+func (t *ThreadList) handleThreadsLoaded(msg threadsLoadedMsg) (tea.Model, tea.Cmd) {
+	if msg.items == nil && msg.err != nil {
+		// On error, keep existing threads and report it in the status bar
+		return t, reportError(msg.err)
+	}
+
+	// Keep the selection on the same thread when a folder is reloaded, such
+	// as after a sync
+	selectedID := ""
+	if current := t.Current(); current != nil && t.account == msg.account && t.folder == msg.folder && t.pseudo == msg.pseudo {
+		selectedID = current.ID
+	}
 
-	t.threads = threadItems
-	t.selected = 0     // Reset selection to first thread
-	t.scrollOffset = 0 // Reset scroll offset
+	t.threads = msg.items
+	t.account = msg.account
+	t.folder = msg.folder
+	t.pseudo = msg.pseudo
+	t.selectID(selectedID)
 
-	return t, nil
+	return t, reportError(msg.err)
+}
+
+// selectID selects the thread with an id, or the first thread when it is
+// not listed, and scrolls to it
+func (t *ThreadList) selectID(id string) {
+	t.selected = 0
+	t.scrollOffset = 0
+	for i, item := range t.threads {
+		if id != "" && item.ID == id {
+			t.selected = i
+			t.adjustScrollForSelection()
+			return
+		}
+	}
 }
 
 // View renders the thread list
@@ -178,11 +252,18 @@ func (t *ThreadList) View() string {
 
 		// Build the line with truncation: [subject] from [sender] • [date]
 		subject := thread.Subject
+		if t.pseudo == allInboxesName && thread.Account != nil {
+			subject = "[" + thread.Account.Config.Name + "] " + subject
+		}
 		sender := thread.From
 		date := thread.Date
 
 		// Calculate the fixed parts: " from " + sender + " • " + date
-		fixedParts := " from " + sender + " • " + date
+		from := " from " + sender
+		if thread.Recipients != "" {
+			from = " to " + thread.Recipients
+		}
+		fixedParts := from + " • " + date
 		fixedLength := len(fixedParts)
 
 		// Truncate subject if needed to fit within available width
@@ -193,7 +274,7 @@ func (t *ThreadList) View() string {
 			}
 		}
 
-		line := prefix + unread + starred + subject + " from " + sender + " • " + date + "\n"
+		line := prefix + unread + starred + subject + from + " • " + date + "\n"
 		result += line
 	}
 
@@ -485,6 +566,46 @@ func (t *ThreadList) ToggleStar() tea.Cmd {
 	return nil
 }
 
+// Folder returns the folder the threads were loaded from, or an empty
+// string while a pseudo-folder is shown
+func (t *ThreadList) Folder() string {
+	return t.folder
+}
+
+// Account returns the account of the folder the threads were loaded from,
+// or nil while a pseudo-folder is shown
+func (t *ThreadList) Account() *Account {
+	return t.account
+}
+
+// Pseudo returns the name of the pseudo-folder shown, if any
+func (t *ThreadList) Pseudo() string {
+	return t.pseudo
+}
+
+// ShowItems lists the entries of a pseudo-folder, keeping the selection on
+// the same entry when the list is refreshed
+func (t *ThreadList) ShowItems(pseudo string, items []ThreadItem) {
+	selectedID := ""
+	if current := t.Current(); current != nil && t.pseudo == pseudo {
+		selectedID = current.ID
+	}
+
+	t.threads = items
+	t.account = nil
+	t.folder = ""
+	t.pseudo = pseudo
+	t.selectID(selectedID)
+}
+
+// Current returns the selected thread, or nil when the list is empty
+func (t *ThreadList) Current() *ThreadItem {
+	if t.selected >= 0 && t.selected < len(t.threads) {
+		return &t.threads[t.selected]
+	}
+	return nil
+}
+
 // MarkRead marks the current thread as read
 func (t *ThreadList) MarkRead() tea.Cmd {
 	if t.selected >= 0 && t.selected < len(t.threads) {
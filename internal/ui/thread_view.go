@@ -1,7 +1,13 @@
 package ui
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/romaintb/mel/internal/composer"
 	"github.com/romaintb/mel/internal/config"
 	"github.com/romaintb/mel/internal/email"
 	"github.com/romaintb/mel/internal/icons"
@@ -10,7 +16,7 @@ import (
 // ThreadView represents the view of an individual email thread
 type ThreadView struct {
 	config        *config.Config
-	emailManager  *email.Manager
+	operations    *Operations
 	iconService   *icons.Service
 	width         int
 	height        int
@@ -19,10 +25,10 @@ type ThreadView struct {
 }
 
 // NewThreadView creates a new thread view instance
-func NewThreadView(cfg *config.Config, emailManager *email.Manager, iconService *icons.Service) (*ThreadView, error) {
+func NewThreadView(cfg *config.Config, operations *Operations, iconService *icons.Service) (*ThreadView, error) {
 	return &ThreadView{
 		config:        cfg,
-		emailManager:  emailManager,
+		operations:    operations,
 		iconService:   iconService,
 		width:         0,
 		height:        0,
@@ -111,11 +117,140 @@ func (t *ThreadView) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Previous message in thread
 	case "o":
 		// Expand/collapse message
-	case "r":
-		// Reply to thread
-	case "f":
-		// Forward thread
 	}
 
 	return t, nil
 }
+
+// composeMsg carries a new draft to edit, or the error that prevented it
+type composeMsg struct {
+	account *Account // Account the draft is sent from
+	draft   *composer.Message
+	draftID string // Message-ID of the saved copy of a resumed draft
+	err     error
+}
+
+// Reply starts a reply to the latest message of the current thread
+func (t *ThreadView) Reply(all bool) tea.Cmd {
+	return t.draftFromLatest(func(account *Account, original *email.Message) composeMsg {
+		return composeMsg{draft: composer.Reply(original, account.identity(), all)}
+	})
+}
+
+// Forward starts a forward of the latest message of the current thread
+func (t *ThreadView) Forward(mode composer.ForwardMode) tea.Cmd {
+	return t.draftFromLatest(func(account *Account, original *email.Message) composeMsg {
+		draft, err := composer.Forward(original, account.identity(), mode)
+		return composeMsg{draft: draft, err: err}
+	})
+}
+
+// ResumeDraft reopens the saved draft shown in the current thread. Only a
+// message tagged as a draft or kept in the Drafts folder is resumed, never
+// a message received in the same conversation.
+func (t *ThreadView) ResumeDraft() tea.Cmd {
+	return t.draftFrom(savedDraft, func(_ *Account, original *email.Message) composeMsg {
+		if len(original.Filenames) == 0 {
+			return composeMsg{err: fmt.Errorf("draft %s has no message file", original.ID)}
+		}
+		draft, err := composer.LoadDraft(original.Filenames[0])
+		return composeMsg{draft: draft, draftID: original.ID, err: err}
+	})
+}
+
+// draftFromLatest loads the current thread and builds a draft, sent from the
+// thread's account, from its latest message
+func (t *ThreadView) draftFromLatest(build func(*Account, *email.Message) composeMsg) tea.Cmd {
+	return t.draftFrom(func(_ *Account, thread *email.Thread) (*email.Message, error) {
+		if len(thread.Messages) > 0 {
+			return latestMessage(thread.Messages), nil
+		}
+		if thread.LatestMessage == nil {
+			return nil, fmt.Errorf("thread %s has no messages", thread.ID)
+		}
+		return thread.LatestMessage, nil
+	}, build)
+}
+
+// draftFrom loads the current thread and builds a draft, sent from the
+// thread's account, from the message pick chooses
+func (t *ThreadView) draftFrom(pick func(*Account, *email.Thread) (*email.Message, error), build func(*Account, *email.Message) composeMsg) tea.Cmd {
+	if t.currentThread == nil || t.currentThread.Account == nil {
+		return nil
+	}
+
+	threadID := t.currentThread.ID
+	account := t.currentThread.Account
+	ctx, done := t.operations.Start("compose")
+	return func() tea.Msg {
+		defer done()
+		thread, err := account.Backend.GetThread(ctx, threadID)
+		if err != nil {
+			return composeMsg{err: fmt.Errorf("failed to load thread: %w", err)}
+		}
+
+		original, err := pick(account, thread)
+		if err != nil {
+			return composeMsg{err: err}
+		}
+
+		msg := build(account, original)
+		msg.account = account
+		return msg
+	}
+}
+
+// latestMessage returns the most recent message, preferring the last one in
+// display order when timestamps are equal
+func latestMessage(messages []*email.Message) *email.Message {
+	latest := messages[0]
+	for _, msg := range messages[1:] {
+		if !msg.Timestamp.Before(latest.Timestamp) {
+			latest = msg
+		}
+	}
+	return latest
+}
+
+// savedDraft returns the latest message of a thread tagged as a draft or
+// stored in the account's Drafts folder. The thread may hold replies
+// received after the draft was saved, which must never be taken for it,
+// and copies removed when the draft was replaced or sent, which are left
+// in the index until the next sync.
+func savedDraft(account *Account, thread *email.Thread) (*email.Message, error) {
+	draftsDir := filepath.Join(account.Config.Maildir, email.ResolveFolderDir(account.Config.Maildir, account.Config.Folders.Drafts))
+	var drafts []*email.Message
+	for _, msg := range thread.Messages {
+		if msg.Excluded || slices.Contains(msg.Labels, "deleted") || !fileExists(msg.Filenames) {
+			continue
+		}
+		if slices.Contains(msg.Labels, "draft") || inDir(msg.Filenames, draftsDir) {
+			drafts = append(drafts, msg)
+		}
+	}
+	if len(drafts) == 0 {
+		return nil, fmt.Errorf("thread %s has no saved draft", thread.ID)
+	}
+	return latestMessage(drafts), nil
+}
+
+// fileExists reports whether the first file of a message, which drafts are
+// loaded from, is still on disk
+func fileExists(filenames []string) bool {
+	if len(filenames) == 0 {
+		return false
+	}
+	_, err := os.Stat(filenames[0])
+	return err == nil
+}
+
+// inDir reports whether any of the files is in a maildir folder directory
+func inDir(filenames []string, dir string) bool {
+	for _, filename := range filenames {
+		// Messages live in the folder's cur or new subdirectory
+		if filepath.Dir(filepath.Dir(filename)) == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/romaintb/mel/internal/config"
+	"github.com/romaintb/mel/internal/email"
+)
+
+func TestSavedDraftSkipsReplacedCopies(t *testing.T) {
+	root := t.TempDir()
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(root, "Drafts", sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	current := filepath.Join(root, "Drafts", "cur", "2.host:2,DS")
+	if err := os.WriteFile(current, []byte("Subject: Re: Lunch?\n\nSee you\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	account := &Account{Config: config.AccountConfig{Maildir: root, Folders: config.FolderMapping{Drafts: "Drafts"}}}
+	now := time.Now()
+	thread := &email.Thread{ID: "1", Messages: []*email.Message{
+		{ID: "received", Timestamp: now.Add(-time.Hour), Filenames: []string{filepath.Join(root, "INBOX", "cur", "1.host:2,S")}},
+		{ID: "current", Timestamp: now.Add(-time.Minute), Labels: []string{"draft"}, Filenames: []string{current}},
+		// Copies replaced since, still indexed until the next sync
+		{ID: "tagged", Timestamp: now, Labels: []string{"draft", "deleted"}, Filenames: []string{current}},
+		{ID: "unlinked", Timestamp: now, Labels: []string{"draft"}, Filenames: []string{filepath.Join(root, "Drafts", "cur", "3.host:2,DS")}},
+		{ID: "excluded", Timestamp: now, Labels: []string{"draft"}, Excluded: true, Filenames: []string{current}},
+	}}
+
+	draft, err := savedDraft(account, thread)
+	if err != nil {
+		t.Fatalf("savedDraft() failed: %v", err)
+	}
+	if draft.ID != "current" {
+		t.Errorf("Expected the draft still on disk, got %s", draft.ID)
+	}
+
+	thread.Messages = thread.Messages[2:]
+	if _, err := savedDraft(account, thread); err == nil {
+		t.Error("Expected an error when every copy was removed")
+	}
+}
@@ -5,9 +5,11 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/romaintb/mel/internal/composer"
 	"github.com/romaintb/mel/internal/config"
-	"github.com/romaintb/mel/internal/email"
 	"github.com/romaintb/mel/internal/icons"
+	"github.com/romaintb/mel/internal/outbox"
+	"github.com/romaintb/mel/internal/schedule"
 	"github.com/romaintb/mel/internal/search"
 )
 
@@ -16,10 +18,24 @@ type UI struct {
 	// Configuration
 	config *config.Config
 
+	// Mail accounts, and the one browsed or composed from by default
+	accounts []*Account
+	current  *Account
+
 	// Services
-	emailManager  *email.Manager
+	outbox        *outbox.Outbox
+	schedule      *schedule.Store
 	searchService *search.SearchService
 	iconService   *icons.Service
+	operations    *Operations
+
+	// Pseudo-folder contents
+	outboxItems []*outbox.Item
+	scheduled   []*schedule.Entry
+
+	// Whether a sync is running, and the background sync schedule
+	syncing  bool
+	autoSync *autoSync
 
 	// Current view/mode
 	currentView ViewType
@@ -31,10 +47,11 @@ type UI struct {
 	focusedBox FocusedBox
 
 	// UI components
-	sidebar    *Sidebar
-	threadList *ThreadList
-	threadView *ThreadView
-	statusBar  *StatusBar
+	sidebar     *Sidebar
+	threadList  *ThreadList
+	threadView  *ThreadView
+	composeView *ComposeView
+	statusBar   *StatusBar
 
 	// Dimensions
 	width  int
@@ -72,22 +89,33 @@ const (
 )
 
 // New creates a new UI instance
-func New(cfg *config.Config, emailManager *email.Manager, searchService *search.SearchService, iconService *icons.Service) (*UI, error) {
-	sidebar, err := NewSidebar(cfg, emailManager, iconService)
+func New(cfg *config.Config, accounts []*Account, queue *outbox.Outbox, scheduled *schedule.Store, searchService *search.SearchService, iconService *icons.Service) (*UI, error) {
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("no mail account configured")
+	}
+	current := defaultAccount(cfg, accounts)
+	operations := NewOperations()
+
+	sidebar, err := NewSidebar(cfg, accounts, current, operations, iconService)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create sidebar: %w", err)
 	}
 
-	threadList, err := NewThreadList(cfg, emailManager, iconService)
+	threadList, err := NewThreadList(cfg, current, operations, iconService)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create thread list: %w", err)
 	}
 
-	threadView, err := NewThreadView(cfg, emailManager, iconService)
+	threadView, err := NewThreadView(cfg, operations, iconService)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create thread view: %w", err)
 	}
 
+	composeView, err := NewComposeView(cfg, queue, scheduled, operations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compose view: %w", err)
+	}
+
 	statusBar, err := NewStatusBar(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create status bar: %w", err)
@@ -116,18 +144,30 @@ func New(cfg *config.Config, emailManager *email.Manager, searchService *search.
 	// Initialize status bar with default focus
 	statusBar.SetFocusedBox("SIDEBAR")
 
+	// Several accounts share the sidebar and can be read together
+	if len(accounts) > 1 {
+		statusBar.SetAccount(current.Config.Name)
+		sidebar.SetPseudoFolder(allInboxesName, "inbox", allInboxesName)
+	}
+
 	return &UI{
 		config:        cfg,
-		emailManager:  emailManager,
+		accounts:      accounts,
+		current:       current,
+		outbox:        queue,
+		schedule:      scheduled,
 		searchService: searchService,
 		iconService:   iconService,
+		operations:    operations,
 		currentView:   ViewNormal,
 		leaderPressed: false,
 		focusedBox:    FocusedSidebar, // Default focus to sidebar
 		sidebar:       sidebar,
 		threadList:    threadList,
 		threadView:    threadView,
+		composeView:   composeView,
 		statusBar:     statusBar,
+		autoSync:      newAutoSync(cfg.Email.AutoSyncInterval),
 		styles:        styles,
 	}, nil
 }
@@ -139,6 +179,11 @@ func (u *UI) Init() tea.Cmd {
 		u.threadList.Init(),
 		u.threadView.Init(),
 		u.statusBar.Init(),
+		u.loadOutbox(),
+		outboxTick(),
+		u.loadSchedule(),
+		scheduleTick(),
+		u.autoSync.tick(),
 	)
 }
 
@@ -155,7 +200,63 @@ func (u *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, u.handleResize(msg)...)
 	case FolderSelectedMsg:
 		// Handle folder selection - load threads from selected folder
-		cmds = append(cmds, u.threadList.LoadThreads(msg.FolderName))
+		u.setAccount(msg.Account)
+		cmds = append(cmds, u.threadList.LoadThreads(msg.Account, msg.FolderName))
+	case errorMsg:
+		u.statusBar.SetMessage(formatError(msg.err))
+	case composeMsg:
+		if msg.err != nil {
+			cmds = append(cmds, reportError(msg.err))
+		} else {
+			cmds = append(cmds, u.startCompose(msg.account, msg.draft, msg.draftID))
+		}
+	case composeClosedMsg:
+		u.currentView = ViewNormal
+		u.statusBar.SetMode("NORMAL")
+		u.statusBar.SetMessage(msg.status)
+	case composeStatusMsg:
+		u.statusBar.SetMessage(msg.status)
+	case sentMsg:
+		if msg.queued {
+			cmds = append(cmds, u.loadOutbox())
+		}
+	case outboxTickMsg:
+		cmds = append(cmds, u.flushOutbox(outbox.FlushDue, ""), outboxTick())
+	case outboxFlushedMsg:
+		if msg.sent > 0 {
+			u.statusBar.SetMessage(fmt.Sprintf("Sent %d queued message(s)", msg.sent))
+		}
+		cmds = append(cmds, reportError(msg.err), u.loadOutbox())
+	case outboxLoadedMsg:
+		cmds = append(cmds, u.handleOutboxLoaded(msg))
+	case scheduledMsg:
+		if msg.err == nil {
+			cmds = append(cmds, u.loadSchedule())
+		}
+	case scheduleTickMsg:
+		cmds = append(cmds, u.dispatchScheduled(), scheduleTick())
+	case scheduleDispatchedMsg:
+		if msg.status != "" {
+			u.statusBar.SetMessage(msg.status)
+		}
+		cmds = append(cmds, reportError(msg.err), u.loadSchedule(), u.loadOutbox())
+	case scheduleLoadedMsg:
+		cmds = append(cmds, u.handleScheduleLoaded(msg))
+	case pseudoFolderSelectedMsg:
+		cmds = append(cmds, u.showPseudoFolder(msg.name))
+	case syncStageMsg:
+		cmds = append(cmds, u.handleSyncStage(msg))
+	case syncedMsg:
+		cmds = append(cmds, u.handleSynced(msg))
+	case autoSyncTickMsg:
+		cmds = append(cmds, u.runAutoSync())
+	case autoSyncedMsg:
+		cmds = append(cmds, u.handleAutoSynced(msg))
+	}
+
+	// Keys belong to the review screen while composing
+	if _, isKey := msg.(tea.KeyMsg); isKey && u.currentView == ViewInsert {
+		return u, tea.Batch(cmds...)
 	}
 
 	// Update child components
@@ -168,6 +269,9 @@ func (u *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if cmd := u.updateThreadView(msg); cmd != nil {
 		cmds = append(cmds, cmd)
 	}
+	if cmd := u.updateComposeView(msg); cmd != nil {
+		cmds = append(cmds, cmd)
+	}
 	if cmd := u.updateStatusBar(msg); cmd != nil {
 		cmds = append(cmds, cmd)
 	}
@@ -225,6 +329,10 @@ func (u *UI) View() string {
 
 // renderContent renders the main content area
 func (u *UI) renderContent() string {
+	if u.currentView == ViewInsert && u.composeView.Active() {
+		return u.composeView.View()
+	}
+
 	// For now, just show thread list
 	// TODO: Implement proper view switching
 	return u.threadList.View()
@@ -253,10 +361,20 @@ func (u *UI) handleNormalMode(msg tea.KeyMsg) []tea.Cmd {
 	var cmds []tea.Cmd
 
 	switch {
-	case msg.String() == "q":
-		return []tea.Cmd{tea.Quit}
-	case msg.Type == tea.KeyCtrlC:
+	case msg.String() == "q", msg.Type == tea.KeyCtrlC:
+		// Don't leave external tools running after exit
+		u.operations.CancelAll()
+		if u.composeView.Pending() {
+			// The message was sent; only the undo delay is cut short
+			u.statusBar.SetMessage("Sending before quitting…")
+			return []tea.Cmd{u.composeView.SendPendingAndQuit()}
+		}
 		return []tea.Cmd{tea.Quit}
+	case msg.Type == tea.KeyEsc:
+		// Cancel slow backend calls such as a folder load or a search
+		if count := u.operations.CancelAll(); count > 0 {
+			u.statusBar.SetMessage(fmt.Sprintf("Cancelled %d operation(s)", count))
+		}
 	case msg.String() == "h":
 		// Toggle sidebar visibility
 		cmds = append(cmds, u.sidebar.Toggle())
@@ -267,9 +385,8 @@ func (u *UI) handleNormalMode(msg tea.KeyMsg) []tea.Cmd {
 		cmds = append(cmds, u.threadList.Focus())
 		u.leaderPressed = false
 	case msg.String() == "i":
-		// Enter insert mode
-		u.currentView = ViewInsert
-		u.statusBar.SetMode("INSERT")
+		// Compose a new message; saved drafts are resumed from the Drafts folder
+		cmds = append(cmds, u.startCompose(u.current, u.composeView.NewDraft(u.current), ""))
 	case msg.String() == "v":
 		// Enter visual mode
 		u.currentView = ViewVisual
@@ -280,7 +397,7 @@ func (u *UI) handleNormalMode(msg tea.KeyMsg) []tea.Cmd {
 		u.statusBar.SetMode("SEARCH")
 	case msg.String() == " ":
 		// Leader key - show available commands
-		u.statusBar.SetMessage("Leader key pressed - use: fg (content), fs (sender), fe (global), i (toggle icons)")
+		u.statusBar.SetMessage("Leader key pressed - use: fg (content search), fs (sender search), fe (global search), i (toggle icons)")
 		u.leaderPressed = true
 	case msg.Type == tea.KeyTab:
 		// Switch focus between sidebar and content
@@ -348,8 +465,7 @@ func (u *UI) handleNormalMode(msg tea.KeyMsg) []tea.Cmd {
 			cmds = append(cmds, u.sidebar.selectCurrentItem())
 			u.statusBar.SetMessage("Folder selected")
 		} else {
-			// Expand/collapse thread
-			cmds = append(cmds, u.threadList.ToggleThread())
+			cmds = append(cmds, u.openCurrentThread())
 		}
 	case msg.String() == "o":
 		// Enter/select in focused box (alternative key)
@@ -358,24 +474,68 @@ func (u *UI) handleNormalMode(msg tea.KeyMsg) []tea.Cmd {
 			cmds = append(cmds, u.sidebar.selectCurrentItem())
 			u.statusBar.SetMessage("Folder selected")
 		} else {
-			// Expand/collapse thread
-			cmds = append(cmds, u.threadList.ToggleThread())
+			cmds = append(cmds, u.openCurrentThread())
 		}
+	case msg.String() == "c":
+		// Switch to the next account and open its inbox
+		cmds = append(cmds, u.switchAccount())
 	case msg.String() == "a":
 		// Archive thread
 		cmds = append(cmds, u.threadList.ArchiveCurrent())
 	case msg.String() == "d":
+		if thread := u.threadList.Current(); thread != nil && u.threadList.Pseudo() == outboxName {
+			// Remove the message from the outbox without sending it
+			cmds = append(cmds, u.removeFromOutbox(thread.ID))
+			break
+		}
+		if u.threadList.Pseudo() == scheduledName {
+			cmds = append(cmds, u.cancelScheduled())
+			break
+		}
 		// Delete thread
 		cmds = append(cmds, u.threadList.DeleteCurrent())
 	case msg.String() == "s":
 		// Star/unread thread
 		cmds = append(cmds, u.threadList.ToggleStar())
+	case msg.String() == "S":
+		// Sync the selected folder or account
+		cmds = append(cmds, u.syncSelected())
 	case msg.String() == "r":
 		// Mark thread as read
 		cmds = append(cmds, u.threadList.MarkRead())
 	case msg.String() == "u":
+		if u.composeView.Pending() && !u.composeView.Active() {
+			// Take back the message being sent and review it again
+			u.composeView.Undo()
+			u.currentView = ViewInsert
+			u.statusBar.SetMode("INSERT")
+			u.statusBar.SetMessage("Sending cancelled")
+			break
+		}
 		// Mark thread as unread
 		cmds = append(cmds, u.threadList.MarkUnread())
+	case msg.String() == "R", msg.String() == "A":
+		// Reply (R) or reply to all (A) to the selected thread
+		if thread := u.threadList.Current(); thread != nil {
+			u.threadView.SetThread(thread)
+			cmds = append(cmds, u.threadView.Reply(msg.String() == "A"))
+		}
+	case msg.String() == "f" && u.leaderPressed:
+		// Start a search chord (leader+fg, leader+fs, leader+fe)
+		u.currentView = ViewSearch
+		u.statusBar.SetMode("SEARCH")
+		u.statusBar.SetMessage("Search type: g (content), s (sender), e (global)")
+		u.leaderPressed = false
+	case msg.String() == "f", msg.String() == "F":
+		// Forward the selected thread inline (f) or as an attachment (F)
+		if thread := u.threadList.Current(); thread != nil {
+			mode := composer.ForwardInline
+			if msg.String() == "F" {
+				mode = composer.ForwardAttachment
+			}
+			u.threadView.SetThread(thread)
+			cmds = append(cmds, u.threadView.Forward(mode))
+		}
 	case msg.String() == "e":
 		// Toggle sidebar (leader+e as specified in PRD)
 		cmds = append(cmds, u.sidebar.Toggle())
@@ -394,18 +554,75 @@ func (u *UI) handleNormalMode(msg tea.KeyMsg) []tea.Cmd {
 	return cmds
 }
 
-// handleInsertMode handles key presses in insert mode
+// handleInsertMode handles key presses in insert mode, where a draft is
+// edited in the external editor and then reviewed
 func (u *UI) handleInsertMode(msg tea.KeyMsg) []tea.Cmd {
-	var cmds []tea.Cmd
+	if !u.composeView.Active() {
+		// The editor could not be started; nothing to review
+		if msg.Type == tea.KeyEsc {
+			u.currentView = ViewNormal
+			u.statusBar.SetMode("NORMAL")
+		}
+		return nil
+	}
 
-	switch msg.String() {
-	case "esc":
-		// Exit insert mode
-		u.currentView = ViewNormal
-		u.statusBar.SetMode("NORMAL")
+	_, cmd := u.composeView.Update(msg)
+	return []tea.Cmd{cmd}
+}
+
+// openCurrentThread expands or collapses the selected thread, resumes it in
+// the compose flow when it is a saved or scheduled draft, or retries a
+// queued message
+func (u *UI) openCurrentThread() tea.Cmd {
+	switch u.threadList.Pseudo() {
+	case outboxName:
+		return u.openOutboxItem()
+	case scheduledName:
+		return tea.Sequence(u.editScheduled(), u.loadSchedule())
+	}
+	if account := u.threadList.Account(); account != nil && u.threadList.Folder() == account.Config.Folders.Drafts {
+		if thread := u.threadList.Current(); thread != nil {
+			u.threadView.SetThread(thread)
+			return u.threadView.ResumeDraft()
+		}
 	}
+	return u.threadList.ToggleThread()
+}
 
-	return cmds
+// startCompose enters insert mode and opens a draft sent from an account in
+// the external editor. draftID is the Message-ID of the saved copy of a
+// resumed draft.
+func (u *UI) startCompose(account *Account, draft *composer.Message, draftID string) tea.Cmd {
+	u.currentView = ViewInsert
+	u.statusBar.SetMode("INSERT")
+	u.statusBar.SetMessage("Editing draft: " + draft.Subject)
+	return u.composeView.Open(account, draft, draftID)
+}
+
+// switchAccount moves to the next account and lists its inbox
+func (u *UI) switchAccount() tea.Cmd {
+	if len(u.accounts) < 2 {
+		u.statusBar.SetMessage("No other account configured")
+		return nil
+	}
+	next := u.accounts[0]
+	for i, account := range u.accounts {
+		if account == u.current && i+1 < len(u.accounts) {
+			next = u.accounts[i+1]
+		}
+	}
+	u.setAccount(next)
+	u.sidebar.SelectAccount(next)
+	u.statusBar.SetMessage("Switched to " + next.Config.Name)
+	return u.threadList.LoadThreads(next, next.Config.Folders.Inbox)
+}
+
+// setAccount makes an account the one browsed and composed from
+func (u *UI) setAccount(account *Account) {
+	u.current = account
+	if len(u.accounts) > 1 {
+		u.statusBar.SetAccount(account.Config.Name)
+	}
 }
 
 // handleVisualMode handles key presses in visual mode
@@ -501,6 +718,9 @@ func (u *UI) handleResize(msg tea.WindowSizeMsg) []tea.Cmd {
 	if cmd := u.threadView.Resize(contentWidth-4, contentHeight-4); cmd != nil {
 		cmds = append(cmds, cmd)
 	}
+	if cmd := u.composeView.Resize(contentWidth-4, contentHeight-4); cmd != nil {
+		cmds = append(cmds, cmd)
+	}
 	if cmd := u.statusBar.Resize(u.width, 1); cmd != nil {
 		cmds = append(cmds, cmd)
 	}
@@ -524,6 +744,15 @@ func (u *UI) updateThreadView(msg tea.Msg) tea.Cmd {
 	return cmd
 }
 
+func (u *UI) updateComposeView(msg tea.Msg) tea.Cmd {
+	// Keys reach the compose view through handleInsertMode only
+	if _, isKey := msg.(tea.KeyMsg); isKey {
+		return nil
+	}
+	_, cmd := u.composeView.Update(msg)
+	return cmd
+}
+
 func (u *UI) updateStatusBar(msg tea.Msg) tea.Cmd {
 	_, cmd := u.statusBar.Update(msg)
 	return cmd